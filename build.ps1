@@ -49,7 +49,7 @@ Write-Host
 Write-Host "[3/8] Setting Linux environment and building data-server..." -ForegroundColor Green
 & go env -w GOOS=linux
 Set-Location "data-server"
-$result = & go build -o "../release/data-server-linux" "./main.go"
+$result = & go build -o "../release/data-server-linux" "."
 if ($LASTEXITCODE -ne 0) {
     Write-Host "ERROR: Linux data-server build failed" -ForegroundColor Red
     Set-Location ".."
@@ -63,7 +63,7 @@ Write-Host "SUCCESS: Linux data-server built" -ForegroundColor Green
 Write-Host
 Write-Host "[4/8] Building Linux monitor-agent..." -ForegroundColor Green
 Set-Location "monitor-agent"
-$result = & go build -o "../release/monitor-agent-linux" "./main.go"
+$result = & go build -o "../release/monitor-agent-linux" "."
 if ($LASTEXITCODE -ne 0) {
     Write-Host "ERROR: Linux monitor-agent build failed" -ForegroundColor Red
     Set-Location ".."
@@ -78,7 +78,7 @@ Write-Host
 Write-Host "[5/8] Setting macOS environment and building data-server (amd64)..." -ForegroundColor Green
 & go env -w GOOS=darwin GOARCH=amd64
 Set-Location "data-server"
-$result = & go build -o "../release/data-server-darwin" "./main.go"
+$result = & go build -o "../release/data-server-darwin" "."
 if ($LASTEXITCODE -ne 0) {
     Write-Host "ERROR: macOS data-server build failed" -ForegroundColor Red
     Set-Location ".."
@@ -92,7 +92,7 @@ Write-Host "SUCCESS: macOS data-server built" -ForegroundColor Green
 Write-Host
 Write-Host "[6/8] Building macOS monitor-agent (amd64)..." -ForegroundColor Green
 Set-Location "monitor-agent"
-$result = & go build -o "../release/monitor-agent-darwin" "./main.go"
+$result = & go build -o "../release/monitor-agent-darwin" "."
 if ($LASTEXITCODE -ne 0) {
     Write-Host "ERROR: macOS monitor-agent build failed" -ForegroundColor Red
     Set-Location ".."
@@ -107,7 +107,7 @@ Write-Host
 Write-Host "[7/8] Building macOS data-server (arm64)..." -ForegroundColor Green
 & go env -w GOOS=darwin GOARCH=arm64
 Set-Location "data-server"
-$result = & go build -o "../release/data-server-darwin-arm64" "./main.go"
+$result = & go build -o "../release/data-server-darwin-arm64" "."
 if ($LASTEXITCODE -ne 0) {
     Write-Host "ERROR: macOS ARM64 data-server build failed" -ForegroundColor Red
     Set-Location ".."
@@ -121,7 +121,7 @@ Write-Host "SUCCESS: macOS ARM64 data-server built" -ForegroundColor Green
 Write-Host
 Write-Host "[8/8] Building macOS monitor-agent (arm64)..." -ForegroundColor Green
 Set-Location "monitor-agent"
-$result = & go build -o "../release/monitor-agent-darwin-arm64" "./main.go"
+$result = & go build -o "../release/monitor-agent-darwin-arm64" "."
 if ($LASTEXITCODE -ne 0) {
     Write-Host "ERROR: macOS ARM64 monitor-agent build failed" -ForegroundColor Red
     Set-Location ".."
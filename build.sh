@@ -17,7 +17,7 @@ mkdir -p release
 echo
 echo "[1/4] 编译 Linux data-server..."
 cd data-server
-go build -o ../release/data-server-linux ./main.go
+go build -o ../release/data-server-linux .
 if [ $? -ne 0 ]; then
     echo "错误: Linux data-server 编译失败"
     cd ..
@@ -29,7 +29,7 @@ echo "✓ Linux data-server 编译完成"
 echo
 echo "[2/4] 编译 Linux monitor-agent..."
 cd monitor-agent
-go build -o ../release/monitor-agent-linux ./main.go
+go build -o ../release/monitor-agent-linux .
 if [ $? -ne 0 ]; then
     echo "错误: Linux monitor-agent 编译失败"
     cd ..
@@ -42,7 +42,7 @@ echo
 echo "[3/4] 设置Windows环境并编译 data-server..."
 export GOOS=windows
 cd data-server
-go build -o ../release/data-server.exe ./main.go
+go build -o ../release/data-server.exe .
 if [ $? -ne 0 ]; then
     echo "错误: Windows data-server 编译失败"
     cd ..
@@ -55,7 +55,7 @@ echo "✓ Windows data-server 编译完成"
 echo
 echo "[4/4] 编译 Windows monitor-agent..."
 cd monitor-agent
-go build -o ../release/monitor-agent.exe ./main.go
+go build -o ../release/monitor-agent.exe .
 if [ $? -ne 0 ]; then
     echo "错误: Windows monitor-agent 编译失败"
     cd ..
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const maxAlertEvents = 200 // 保留的最近告警事件数
+
+// 告警状态
+const (
+	alertStatePending = "pending" // 条件已满足，等待持续时间
+	alertStateFiring  = "firing"
+)
+
+// 告警事件类型
+const (
+	alertEventFiring   = "firing"
+	alertEventResolved = "resolved"
+	alertEventRemoved  = "removed" // 离线服务器超过保留时长被清理
+)
+
+// AlertRule 阈值告警规则
+type AlertRule struct {
+	Name      string  `json:"name"`
+	Metric    string  `json:"metric"`             // 指标路径，同历史查询，如 cpu.usage_percent
+	Op        string  `json:"op"`                 // 比较方式: > >= < <= == !=
+	Threshold float64 `json:"threshold"`          // 阈值
+	For       int     `json:"for,omitempty"`      // 条件持续多少秒后触发
+	Project   string  `json:"project,omitempty"`  // 只对该项目生效，空为全部
+	Host      string  `json:"host,omitempty"`     // 只对该主机名或sessionID生效，空为全部
+	Severity  string  `json:"severity,omitempty"` // 级别: info / warning / critical
+}
+
+// AlertState 规则在某台服务器上的当前状态
+type AlertState struct {
+	Rule       string    `json:"rule"`
+	Metric     string    `json:"metric"`
+	Op         string    `json:"op,omitempty"`
+	Threshold  float64   `json:"threshold"`
+	Severity   string    `json:"severity"`
+	State      string    `json:"state"`
+	Value      float64   `json:"value"`
+	Hostname   string    `json:"hostname"`
+	SessionID  string    `json:"session_id,omitempty"`
+	ProjectKey string    `json:"project_key"`
+	Since      time.Time `json:"since"`              // 条件开始满足的时间
+	FiredAt    time.Time `json:"fired_at,omitempty"` // 进入firing的时间
+}
+
+// AlertEvent 告警触发/恢复事件
+type AlertEvent struct {
+	Type       string    `json:"type"` // firing / resolved / removed
+	Rule       string    `json:"rule"`
+	Metric     string    `json:"metric"`
+	Severity   string    `json:"severity"`
+	Value      float64   `json:"value"`
+	Threshold  float64   `json:"threshold"`
+	Hostname   string    `json:"hostname"`
+	SessionID  string    `json:"session_id,omitempty"`
+	ProjectKey string    `json:"project_key"`
+	Message    string    `json:"message"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// AlertManager 维护规则状态和最近事件
+type AlertManager struct {
+	mu        sync.RWMutex
+	rules     []AlertRule
+	states    map[string]map[string]*AlertState // key: serverKey -> 阈值规则为 "rule:规则名"，内置告警为指标路径或规则名
+	events    []AlertEvent
+	listeners []func(AlertEvent)
+}
+
+var alertManager = &AlertManager{
+	states: make(map[string]map[string]*AlertState),
+}
+
+// setRules 校验并加载告警规则，无效规则跳过
+func (m *AlertManager) setRules(rules []AlertRule) {
+	var valid []AlertRule
+	names := make(map[string]bool)
+	for _, rule := range rules {
+		if err := validateAlertRule(rule); err != nil {
+			log.Printf("[告警] 忽略无效规则 %q: %v", rule.Name, err)
+			continue
+		}
+		if names[rule.Name] {
+			log.Printf("[告警] 忽略重名规则 %q", rule.Name)
+			continue
+		}
+		names[rule.Name] = true
+		if rule.Severity == "" {
+			rule.Severity = "warning"
+		}
+		valid = append(valid, rule)
+	}
+
+	m.mu.Lock()
+	m.rules = valid
+	m.mu.Unlock()
+}
+
+func validateAlertRule(rule AlertRule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("缺少name")
+	}
+	if rule.Metric == "" {
+		return fmt.Errorf("缺少metric")
+	}
+	if _, ok := compareOps[rule.Op]; !ok {
+		return fmt.Errorf("不支持的op: %s", rule.Op)
+	}
+	if rule.For < 0 {
+		return fmt.Errorf("for不能为负数")
+	}
+	return nil
+}
+
+var compareOps = map[string]func(a, b float64) bool{
+	">":  func(a, b float64) bool { return a > b },
+	">=": func(a, b float64) bool { return a >= b },
+	"<":  func(a, b float64) bool { return a < b },
+	"<=": func(a, b float64) bool { return a <= b },
+	"==": func(a, b float64) bool { return a == b },
+	"!=": func(a, b float64) bool { return a != b },
+}
+
+// subscribe 注册告警事件监听（如通知渠道）
+func (m *AlertManager) subscribe(listener func(AlertEvent)) {
+	m.mu.Lock()
+	m.listeners = append(m.listeners, listener)
+	m.mu.Unlock()
+}
+
+// evaluate 用新样本评估全部规则（在handleData中调用）
+func (m *AlertManager) evaluate(key string, info *SystemInfo, ts time.Time) {
+	m.mu.Lock()
+	if len(m.rules) == 0 {
+		m.mu.Unlock()
+		return
+	}
+
+	metrics := flattenMetrics(info)
+	var fired []AlertEvent
+	for _, rule := range m.rules {
+		if rule.Project != "" && rule.Project != info.ProjectKey {
+			continue
+		}
+		if rule.Host != "" && rule.Host != info.Hostname && rule.Host != key {
+			continue
+		}
+		states := m.states[key]
+		stateKey := ruleStateKey(rule.Name)
+		state := states[stateKey]
+
+		// 容器、磁盘或网卡消失后指标不再上报，丢弃等待中的状态，已触发的按无数据恢复
+		value, ok := metrics[rule.Metric]
+		if !ok {
+			if state != nil {
+				delete(states, stateKey)
+				if state.State == alertStateFiring {
+					event := newAlertEvent(alertEventResolved, state, ts)
+					event.Message = fmt.Sprintf("[%s] %s 告警恢复（无数据）: %s %s 已不再上报",
+						state.Severity, state.Rule, state.Hostname, state.Metric)
+					fired = append(fired, event)
+				}
+			}
+			continue
+		}
+
+		if !compareOps[rule.Op](value, rule.Threshold) {
+			if state != nil {
+				delete(states, stateKey)
+				if state.State == alertStateFiring {
+					state.Value = value
+					fired = append(fired, newAlertEvent(alertEventResolved, state, ts))
+				}
+			}
+			continue
+		}
+
+		if state == nil {
+			state = &AlertState{
+				Rule:       rule.Name,
+				Metric:     rule.Metric,
+				Op:         rule.Op,
+				Threshold:  rule.Threshold,
+				Severity:   rule.Severity,
+				State:      alertStatePending,
+				Hostname:   info.Hostname,
+				SessionID:  info.SessionID,
+				ProjectKey: info.ProjectKey,
+				Since:      ts,
+			}
+			if states == nil {
+				states = make(map[string]*AlertState)
+				m.states[key] = states
+			}
+			states[stateKey] = state
+		}
+		state.Value = value
+
+		if state.State == alertStatePending && ts.Sub(state.Since) >= time.Duration(rule.For)*time.Second {
+			state.State = alertStateFiring
+			state.FiredAt = ts
+			fired = append(fired, newAlertEvent(alertEventFiring, state, ts))
+		}
+	}
+	m.mu.Unlock()
+
+	for _, event := range fired {
+		m.emit(event)
+	}
+}
+
+// ruleStateKey 阈值规则的状态key，与内置告警使用的指标路径区分
+func ruleStateKey(name string) string {
+	return "rule:" + name
+}
+
+// forget 服务器被清理时丢弃其规则状态
+func (m *AlertManager) forget(key string) {
+	m.mu.Lock()
+	delete(m.states, key)
+	m.mu.Unlock()
+}
+
+// emit 记录事件并通知监听者
+func (m *AlertManager) emit(event AlertEvent) {
+	m.mu.Lock()
+	m.events = append(m.events, event)
+	if len(m.events) > maxAlertEvents {
+		m.events = m.events[len(m.events)-maxAlertEvents:]
+	}
+	listeners := append([]func(AlertEvent){}, m.listeners...)
+	m.mu.Unlock()
+
+	log.Printf("[告警] %s", event.Message)
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+func newAlertEvent(eventType string, state *AlertState, ts time.Time) AlertEvent {
+	event := AlertEvent{
+		Type:       eventType,
+		Rule:       state.Rule,
+		Metric:     state.Metric,
+		Severity:   state.Severity,
+		Value:      state.Value,
+		Threshold:  state.Threshold,
+		Hostname:   state.Hostname,
+		SessionID:  state.SessionID,
+		ProjectKey: state.ProjectKey,
+		Timestamp:  ts,
+	}
+	if eventType == alertEventFiring {
+		event.Message = fmt.Sprintf("[%s] %s 告警触发: %s %s = %.2f %s %g",
+			state.Severity, state.Rule, state.Hostname, state.Metric, state.Value, state.Op, state.Threshold)
+	} else {
+		event.Message = fmt.Sprintf("[%s] %s 告警恢复: %s %s = %.2f",
+			state.Severity, state.Rule, state.Hostname, state.Metric, state.Value)
+	}
+	return event
+}
+
+// AlertsResponse 告警查询响应
+type AlertsResponse struct {
+	Active []AlertState `json:"active"`
+	Events []AlertEvent `json:"events"`
+}
+
+// snapshot 返回匹配项目的当前告警和最近事件（按时间倒序）
+func (m *AlertManager) snapshot(match func(projectKey string) bool, stateFilter string) AlertsResponse {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	response := AlertsResponse{
+		Active: []AlertState{},
+		Events: []AlertEvent{},
+	}
+	for _, states := range m.states {
+		for _, state := range states {
+			if !match(state.ProjectKey) {
+				continue
+			}
+			if stateFilter != "" && state.State != stateFilter {
+				continue
+			}
+			response.Active = append(response.Active, *state)
+		}
+	}
+	sort.Slice(response.Active, func(i, j int) bool {
+		return response.Active[i].Since.After(response.Active[j].Since)
+	})
+
+	for i := len(m.events) - 1; i >= 0; i-- {
+		if match(m.events[i].ProjectKey) {
+			response.Events = append(response.Events, m.events[i])
+		}
+	}
+	return response
+}
+
+// handleGetAlerts 获取公开项目的告警
+func handleGetAlerts(w http.ResponseWriter, r *http.Request) {
+	writeAlerts(w, alertManager.snapshot(isPublicProject, r.URL.Query().Get("state")))
+}
+
+// handleGetAlertsByAccessKey 根据访问密钥获取项目告警
+func handleGetAlertsByAccessKey(w http.ResponseWriter, r *http.Request) {
+	accessKey := mux.Vars(r)["accessKey"]
+	if accessKey == "" {
+		http.Error(w, "无效的访问密钥", http.StatusUnauthorized)
+		return
+	}
+	writeAlerts(w, alertManager.snapshot(accessKeyMatcher(accessKey), r.URL.Query().Get("state")))
+}
+
+func writeAlerts(w http.ResponseWriter, response AlertsResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding alerts response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const checkFailedRule = "check_failed"
+
+// checkMetric 检查对应的指标路径，同时作为告警状态的key
+func checkMetric(name string) string {
+	return fmt.Sprintf("checks[%s].success", name)
+}
+
+// checksFailed 返回当前失败的检查名，不含网格探测
+func checksFailed(info *SystemInfo) []string {
+	var names []string
+	for _, c := range info.Checks {
+		if !c.Success && c.Peer == "" {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// evaluateChecks 检查连续失败达到check_failures次时发出check_failed事件，恢复时发出恢复事件
+func (m *AlertManager) evaluateChecks(key string, info *SystemInfo, ts time.Time) {
+	var events []AlertEvent
+	threshold := max(serverConfig.CheckFailures, 1)
+
+	m.mu.Lock()
+	states := m.states[key]
+	current := make(map[string]bool)
+	for _, c := range info.Checks {
+		// 网格探测的对端宕机由agent_down告警，这里不重复告警
+		if c.Peer != "" {
+			continue
+		}
+		metric := checkMetric(c.Name)
+		current[metric] = true
+		state := states[metric]
+
+		if c.Success {
+			if state != nil {
+				delete(states, metric)
+				if state.State == alertStateFiring {
+					state.Value = 1
+					event := newAlertEvent(alertEventResolved, state, ts)
+					event.Message = fmt.Sprintf("[critical] %s 检查恢复: %s %s (%s)，失败 %s",
+						checkFailedRule, info.Hostname, c.Name, c.Target, ts.Sub(state.FiredAt).Truncate(time.Second))
+					events = append(events, event)
+				}
+			}
+			continue
+		}
+
+		// 与阈值规则的for一样，先记为等待中，连续失败达到次数后才触发
+		if state == nil {
+			state = &AlertState{
+				Rule:       checkFailedRule,
+				Metric:     metric,
+				Severity:   "critical",
+				State:      alertStatePending,
+				Hostname:   info.Hostname,
+				SessionID:  info.SessionID,
+				ProjectKey: info.ProjectKey,
+				Since:      ts,
+			}
+			if states == nil {
+				states = make(map[string]*AlertState)
+				m.states[key] = states
+			}
+			states[metric] = state
+		}
+		if state.State == alertStatePending && c.Failures >= threshold {
+			state.State = alertStateFiring
+			state.FiredAt = ts
+			event := newAlertEvent(alertEventFiring, state, ts)
+			event.Message = fmt.Sprintf("[critical] %s 检查失败: %s %s %s (%s)，连续失败 %d 次",
+				checkFailedRule, info.Hostname, c.Type, c.Name, c.Error, c.Failures)
+			events = append(events, event)
+		}
+	}
+
+	// 从配置中移除的检查不再保留告警
+	for metric, state := range states {
+		if state.Rule == checkFailedRule && !current[metric] {
+			delete(states, metric)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, event := range events {
+		m.emit(event)
+	}
+}
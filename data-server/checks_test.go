@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func failedCheck(failures int) CheckResult {
+	return CheckResult{Name: "api", Type: "http", Target: "https://example.com", Error: "timeout", Failures: failures}
+}
+
+func TestCheckFailedWaitsForThreshold(t *testing.T) {
+	m := &AlertManager{states: make(map[string]map[string]*AlertState)}
+	saved := serverConfig.CheckFailures
+	serverConfig.CheckFailures = 3
+	t.Cleanup(func() { serverConfig.CheckFailures = saved })
+
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	info := &SystemInfo{Hostname: "web-01"}
+	for failures := 1; failures <= 2; failures++ {
+		info.Checks = []CheckResult{failedCheck(failures)}
+		m.evaluateChecks("web-01", info, ts.Add(time.Duration(failures)*time.Minute))
+	}
+	if len(m.events) != 0 {
+		t.Fatalf("fired before threshold: %+v", m.events)
+	}
+	if state := m.states["web-01"][checkMetric("api")]; state == nil || state.State != alertStatePending {
+		t.Fatalf("state = %+v, want pending", state)
+	}
+
+	info.Checks = []CheckResult{failedCheck(3)}
+	m.evaluateChecks("web-01", info, ts.Add(3*time.Minute))
+	if len(m.events) != 1 || m.events[0].Type != alertEventFiring || m.events[0].Rule != checkFailedRule {
+		t.Fatalf("events = %+v, want one check_failed firing", m.events)
+	}
+
+	// 已触发后继续失败不重复告警
+	info.Checks = []CheckResult{failedCheck(4)}
+	m.evaluateChecks("web-01", info, ts.Add(4*time.Minute))
+	if len(m.events) != 1 {
+		t.Fatalf("duplicate events: %+v", m.events)
+	}
+
+	info.Checks = []CheckResult{{Name: "api", Type: "http", Target: "https://example.com", Success: true}}
+	m.evaluateChecks("web-01", info, ts.Add(5*time.Minute))
+	if len(m.events) != 2 || m.events[1].Type != alertEventResolved {
+		t.Fatalf("events = %+v, want resolved", m.events)
+	}
+}
+
+func TestCheckRecoveredBeforeThresholdIsSilent(t *testing.T) {
+	m := &AlertManager{states: make(map[string]map[string]*AlertState)}
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	info := &SystemInfo{Hostname: "web-01", Checks: []CheckResult{failedCheck(1)}}
+	m.evaluateChecks("web-01", info, ts)
+
+	info.Checks = []CheckResult{{Name: "api", Success: true}}
+	m.evaluateChecks("web-01", info, ts.Add(time.Minute))
+	if len(m.events) != 0 {
+		t.Fatalf("events = %+v, want none for a single failure", m.events)
+	}
+	if len(m.states["web-01"]) != 0 {
+		t.Fatalf("pending state kept after recovery: %+v", m.states["web-01"])
+	}
+}
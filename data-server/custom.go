@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// customSeriesTTL 超过该时长未上报的自定义序列不再计入上限
+const customSeriesTTL = time.Hour
+
+// limitCustomSeries 限制每台服务器的自定义指标序列数（调用方需持有data.mu写锁）
+// 插件输出的标签（如请求ID、URL）会不断产生新序列，并随之进入聚合层、实时推送和 /metrics；
+// 已记录的序列继续保留，超出max_custom_series的新序列从样本中丢弃
+func limitCustomSeries(server *ServerInfo, info *SystemInfo, ts time.Time) {
+	limit := serverConfig.MaxCustomSeries
+	if limit <= 0 || len(info.Custom) == 0 {
+		return
+	}
+	if server.customSeries == nil {
+		server.customSeries = make(map[string]time.Time)
+	}
+	for series, seen := range server.customSeries {
+		if ts.Sub(seen) > customSeriesTTL {
+			delete(server.customSeries, series)
+		}
+	}
+
+	dropped := 0
+	custom := make([]PluginResult, len(info.Custom))
+	for i, plugin := range info.Custom {
+		var kept []CustomMetric
+		for _, metric := range plugin.Metrics {
+			series := customMetricKey(plugin.Name, metric)
+			seen, known := server.customSeries[series]
+			if !known && len(server.customSeries) >= limit {
+				dropped++
+				continue
+			}
+			if ts.After(seen) {
+				server.customSeries[series] = ts
+			}
+			kept = append(kept, metric)
+		}
+		plugin.Metrics = kept
+		custom[i] = plugin
+	}
+	info.Custom = custom
+
+	// 每次超限只提示一次，回落到上限以内后重置
+	if dropped > 0 && !server.customLimited {
+		log.Printf("[自定义指标] %s 的自定义序列超过上限 %d，新序列已丢弃（本次 %d 个）", info.Hostname, limit, dropped)
+	}
+	server.customLimited = dropped > 0
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// requestSample 插件按请求ID打标签，每条样本产生新的序列
+func requestSample(ts time.Time, ids ...int) *SystemInfo {
+	metrics := []CustomMetric{{Name: "queue_depth", Value: 3}}
+	for _, id := range ids {
+		metrics = append(metrics, CustomMetric{Name: "latency", Labels: map[string]string{"request": fmt.Sprint(id)}, Value: float64(id)})
+	}
+	return &SystemInfo{Hostname: "web-01", Timestamp: ts, Custom: []PluginResult{{Name: "app", Metrics: metrics}}}
+}
+
+func TestLimitCustomSeries(t *testing.T) {
+	saved := serverConfig.MaxCustomSeries
+	serverConfig.MaxCustomSeries = 3
+	t.Cleanup(func() { serverConfig.MaxCustomSeries = saved })
+
+	server := &ServerInfo{}
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	info := requestSample(ts, 1, 2, 3)
+	limitCustomSeries(server, info, ts)
+	if got := len(info.Custom[0].Metrics); got != 3 {
+		t.Fatalf("kept %d metrics, want 3", got)
+	}
+
+	// 已有序列继续上报，新序列被丢弃
+	info = requestSample(ts.Add(time.Minute), 1, 4, 5)
+	limitCustomSeries(server, info, ts.Add(time.Minute))
+	var kept []string
+	for _, m := range info.Custom[0].Metrics {
+		kept = append(kept, customMetricKey("app", m))
+	}
+	want := []string{"custom[app].queue_depth", `custom[app].latency{request="1"}`}
+	if fmt.Sprint(kept) != fmt.Sprint(want) {
+		t.Fatalf("kept %v, want %v", kept, want)
+	}
+	if !server.customLimited {
+		t.Error("customLimited not set")
+	}
+
+	// 超过一小时未上报的序列不再占用名额
+	later := ts.Add(time.Minute + customSeriesTTL + time.Second)
+	info = requestSample(later, 6)
+	limitCustomSeries(server, info, later)
+	if got := len(info.Custom[0].Metrics); got != 2 {
+		t.Fatalf("kept %d metrics after expiry, want 2", got)
+	}
+	if server.customLimited {
+		t.Error("customLimited not reset")
+	}
+}
+
+// rollupCustomSeries 返回聚合层中的自定义指标序列
+func rollupCustomSeries(server *ServerInfo) map[string]bool {
+	series := make(map[string]bool)
+	for _, point := range append(server.Rollup1m, server.Rollup1h...) {
+		for key := range point.Metrics {
+			if strings.HasPrefix(key, "custom[") {
+				series[key] = true
+			}
+		}
+	}
+	return series
+}
+
+func TestStoreSampleKeepsCustomSeriesOutOfRollups(t *testing.T) {
+	servers := make(map[string]*ServerInfo)
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		seen := ts.Add(time.Duration(i) * time.Second)
+		storeSample(servers, "web-01", requestSample(seen, i), seen)
+	}
+
+	server := servers["web-01"]
+	if series := rollupCustomSeries(server); len(series) != 0 {
+		t.Errorf("rollups hold custom series without rollup_custom: %v", series)
+	}
+	if _, ok := server.Rollup1m[0].Metrics["cpu.usage_percent"]; !ok {
+		t.Error("built-in metrics missing from rollups")
+	}
+	if got := len(server.Latest.Custom[0].Metrics); got != 2 {
+		t.Errorf("latest kept %d custom metrics, want 2", got)
+	}
+}
+
+func TestStoreSampleCapsAllowlistedCustomSeries(t *testing.T) {
+	saved, savedRollup := serverConfig.MaxCustomSeries, serverConfig.RollupCustom
+	serverConfig.MaxCustomSeries = 5
+	serverConfig.RollupCustom = []string{"custom[app].lat*"}
+	t.Cleanup(func() { serverConfig.MaxCustomSeries, serverConfig.RollupCustom = saved, savedRollup })
+
+	servers := make(map[string]*ServerInfo)
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		seen := ts.Add(time.Duration(i) * time.Second)
+		storeSample(servers, "web-01", requestSample(seen, i), seen)
+	}
+
+	server := servers["web-01"]
+	if got := len(server.Latest.Custom[0].Metrics); got != 1 {
+		t.Errorf("latest kept %d custom metrics, want only queue_depth", got)
+	}
+	// queue_depth不在列表中，4个latency序列进入聚合层
+	series := rollupCustomSeries(server)
+	if len(series) != 4 || series["custom[app].queue_depth"] {
+		t.Errorf("rollups hold %d custom series, want 4 latency series: %v", len(series), series)
+	}
+}
+
+func TestRollupCustomAllowed(t *testing.T) {
+	saved := serverConfig.RollupCustom
+	serverConfig.RollupCustom = []string{"custom[jobs].queue.depth", "custom[statsd].api.*"}
+	t.Cleanup(func() { serverConfig.RollupCustom = saved })
+
+	tests := map[string]bool{
+		"custom[jobs].queue.depth":                 true,
+		`custom[jobs].queue.depth{queue="mail"}`:   true,
+		"custom[jobs].queue.depth_max":             false,
+		"custom[statsd].api.latency.p99":           true,
+		`custom[statsd].api.requests.rate{env=""}`: true,
+		"custom[statsd].db.latency.p99":            false,
+	}
+	for series, want := range tests {
+		if got := rollupCustomAllowed(series); got != want {
+			t.Errorf("rollupCustomAllowed(%q) = %v, want %v", series, got, want)
+		}
+	}
+}
+
+func TestLimitCustomSeriesDisabled(t *testing.T) {
+	saved := serverConfig.MaxCustomSeries
+	serverConfig.MaxCustomSeries = -1
+	t.Cleanup(func() { serverConfig.MaxCustomSeries = saved })
+
+	server := &ServerInfo{}
+	ts := time.Now()
+	info := requestSample(ts, 1, 2, 3, 4, 5)
+	limitCustomSeries(server, info, ts)
+	if got := len(info.Custom[0].Metrics); got != 6 {
+		t.Errorf("kept %d metrics, want all 6", got)
+	}
+}
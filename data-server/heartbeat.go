@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	heartbeatCheckInterval = 10 * time.Second
+	agentDownRule          = "agent_down"
+	agentDownMetric        = "heartbeat"
+	serverRemovedRule      = "server_removed"
+)
+
+// isOffline 与列表中的status判断保持一致
+func isOffline(server *ServerInfo, now time.Time) bool {
+	return server.Latest != nil && now.Sub(server.Latest.Timestamp) > offlineThreshold
+}
+
+// heartbeatRoutine 定期检查心跳，超过offlineThreshold未上报时触发agent_down告警
+func heartbeatRoutine() {
+	ticker := time.NewTicker(heartbeatCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		checkHeartbeats(time.Now())
+	}
+}
+
+func checkHeartbeats(now time.Time) {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	// 代理重启会注册新session，同一项目下同名主机有新session在线时，旧session不算宕机
+	online := make(map[string]bool)
+	for _, server := range data.servers {
+		if server.Latest != nil && !isOffline(server, now) {
+			online[siblingKey(server.Latest)] = true
+		}
+	}
+
+	for key, server := range data.servers {
+		if !isOffline(server, now) {
+			continue
+		}
+		replaced := online[siblingKey(server.Latest)]
+
+		if replaced {
+			// 旧session不会再上报，从列表、推送状态、告警状态和存储中移除
+			if server.DownSince != nil {
+				alertManager.resolveAgentDown(key, server, now, "新会话已上线")
+			}
+			log.Printf("移除已被新会话替换的服务器: %s", key)
+			removeServer(key, server, "replaced")
+			continue
+		}
+		if server.DownSince != nil {
+			// 重启后从存储恢复的宕机状态，补回活动告警但不重复通知
+			alertManager.restoreAgentDown(key, server)
+			continue
+		}
+
+		server.DownSince = &now
+		alertManager.raiseAgentDown(key, server, now)
+		// 状态巡检可能已推送过不带down_since的离线状态，这里补推一次
+		streamHub.publishStatus(key, server, now)
+	}
+}
+
+func siblingKey(info *SystemInfo) string {
+	return info.ProjectKey + "|" + info.Hostname
+}
+
+func agentDownState(server *ServerInfo, now time.Time) *AlertState {
+	return &AlertState{
+		Rule:       agentDownRule,
+		Metric:     agentDownMetric,
+		Severity:   "critical",
+		State:      alertStateFiring,
+		Value:      now.Sub(server.Latest.Timestamp).Seconds(),
+		Hostname:   server.Latest.Hostname,
+		SessionID:  server.Latest.SessionID,
+		ProjectKey: server.Latest.ProjectKey,
+		Since:      server.Latest.Timestamp,
+		FiredAt:    *server.DownSince,
+	}
+}
+
+// raiseAgentDown 记录宕机状态并发出告警事件
+func (m *AlertManager) raiseAgentDown(key string, server *ServerInfo, now time.Time) {
+	state := agentDownState(server, now)
+	m.setState(key, state)
+
+	event := newAlertEvent(alertEventFiring, state, now)
+	event.Message = fmt.Sprintf("[critical] %s 代理离线: %s 已 %s 未上报数据",
+		agentDownRule, state.Hostname, now.Sub(server.Latest.Timestamp).Truncate(time.Second))
+	m.emit(event)
+}
+
+// restoreAgentDown 恢复活动告警状态（不发出事件）
+func (m *AlertManager) restoreAgentDown(key string, server *ServerInfo) {
+	m.mu.RLock()
+	_, exists := m.states[key][agentDownRule]
+	m.mu.RUnlock()
+	if !exists {
+		m.setState(key, agentDownState(server, *server.DownSince))
+	}
+}
+
+// resolveAgentDown 清除宕机状态并发出恢复事件
+func (m *AlertManager) resolveAgentDown(key string, server *ServerInfo, now time.Time, reason string) {
+	m.mu.Lock()
+	delete(m.states[key], agentDownRule)
+	m.mu.Unlock()
+
+	state := agentDownState(server, now)
+	state.Value = now.Sub(*server.DownSince).Seconds()
+	event := newAlertEvent(alertEventResolved, state, now)
+	event.Message = fmt.Sprintf("[critical] %s 代理恢复: %s %s，离线 %s",
+		agentDownRule, state.Hostname, reason, now.Sub(*server.DownSince).Truncate(time.Second))
+	m.emit(event)
+}
+
+// emitServerRemoved 服务器离线超过保留时长被清理时发出事件，其活动告警随之丢弃
+func (m *AlertManager) emitServerRemoved(key string, server *ServerInfo, now time.Time, retention time.Duration) {
+	state := agentDownState(server, now)
+	state.Rule = serverRemovedRule
+	state.Severity = "warning"
+	event := newAlertEvent(alertEventRemoved, state, now)
+	event.Message = fmt.Sprintf("[warning] %s 清理离线服务器: %s 已 %s 未上报数据，超过保留时长 %s",
+		serverRemovedRule, state.Hostname, now.Sub(server.Latest.Timestamp).Truncate(time.Second), retention)
+	m.emit(event)
+}
+
+func (m *AlertManager) setState(key string, state *AlertState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	states := m.states[key]
+	if states == nil {
+		states = make(map[string]*AlertState)
+		m.states[key] = states
+	}
+	states[state.Rule] = state
+}
+
+// cleanupRoutine 清理离线超过offline_retention的服务器，0或负数表示一直保留
+func cleanupRoutine() {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		retention := time.Duration(serverConfig.OfflineRetention) * time.Hour
+		if retention > 0 {
+			cleanupOfflineServers(time.Now(), retention)
+		}
+	}
+}
+
+func cleanupOfflineServers(now time.Time, retention time.Duration) {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+	for hostname, server := range data.servers {
+		if server.Latest != nil && now.Sub(server.Latest.Timestamp) > retention {
+			log.Printf("清理长时间离线的服务器: %s", hostname)
+			alertManager.emitServerRemoved(hostname, server, now, retention)
+			removeServer(hostname, server, "cleanup")
+		}
+	}
+}
+
+// removeServer 删除服务器及其推送状态、告警状态和存储的历史（调用方需持有data.mu写锁）
+func removeServer(key string, server *ServerInfo, reason string) {
+	streamHub.publishRemoval(key, server, reason)
+	alertManager.forget(key)
+	delete(data.servers, key)
+	if err := store.Delete(key); err != nil {
+		log.Printf("[存储] 删除 %s 失败: %v", key, err)
+	}
+}
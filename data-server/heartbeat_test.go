@@ -0,0 +1,133 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// deleteRecorder 记录被删除的服务器
+type deleteRecorder struct {
+	memoryStorage
+	deleted []string
+}
+
+func (s *deleteRecorder) Delete(key string) error {
+	s.deleted = append(s.deleted, key)
+	return nil
+}
+
+// useTestGlobals 替换测试中用到的全局状态
+func useTestGlobals(t *testing.T, servers map[string]*ServerInfo) *deleteRecorder {
+	t.Helper()
+	savedServers, savedStore, savedAlerts, savedHub := data.servers, store, alertManager, streamHub
+	t.Cleanup(func() {
+		data.servers, store, alertManager, streamHub = savedServers, savedStore, savedAlerts, savedHub
+	})
+	recorder := &deleteRecorder{}
+	data.servers = servers
+	store = recorder
+	alertManager = &AlertManager{states: make(map[string]map[string]*AlertState)}
+	streamHub = &StreamHub{
+		subscribers: make(map[*streamSubscriber]struct{}),
+		lastStatus:  make(map[string]string),
+	}
+	return recorder
+}
+
+func sessionSample(session string, ts time.Time) *ServerInfo {
+	return &ServerInfo{
+		Latest:   &SystemInfo{Hostname: "web-01", SessionID: session, ProjectKey: "public", Timestamp: ts},
+		LastSeen: ts,
+	}
+}
+
+func TestCheckHeartbeatsRemovesReplacedSession(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	down := now.Add(-time.Minute)
+	old := sessionSample("old", now.Add(-2*time.Minute))
+	old.DownSince = &down
+	recorder := useTestGlobals(t, map[string]*ServerInfo{
+		"old":     old,
+		"new":     sessionSample("new", now),
+		"db-01":   {Latest: &SystemInfo{Hostname: "db-01", SessionID: "db-01", ProjectKey: "public", Timestamp: now.Add(-time.Hour)}},
+		"private": {Latest: &SystemInfo{Hostname: "web-01", SessionID: "private", ProjectKey: "other", Timestamp: now.Add(-time.Hour)}},
+	})
+	alertManager.setState("old", agentDownState(old, down))
+	streamHub.lastStatus["old"] = "offline"
+	sub := streamHub.subscribe(func(string) bool { return true })
+
+	checkHeartbeats(now)
+
+	if _, ok := data.servers["old"]; ok {
+		t.Fatal("replaced session still listed")
+	}
+	if len(recorder.deleted) != 1 || recorder.deleted[0] != "old" {
+		t.Errorf("deleted = %v, want [old]", recorder.deleted)
+	}
+	if _, ok := streamHub.lastStatus["old"]; ok {
+		t.Error("stream status kept for replaced session")
+	}
+	if states := alertManager.states["old"]; len(states) != 0 {
+		t.Errorf("alert states kept: %+v", states)
+	}
+	resolved := 0
+	for _, event := range alertManager.events {
+		if event.Type == alertEventResolved && event.SessionID == "old" {
+			resolved++
+		}
+	}
+	if resolved != 1 {
+		t.Errorf("events = %+v, want agent_down resolved for old", alertManager.events)
+	}
+	// 宕机的主机另有状态推送，只检查移除事件
+	removals := 0
+	for len(sub.events) > 0 {
+		event := <-sub.events
+		if event.Type != streamEventRemove {
+			continue
+		}
+		removals++
+		if removal, ok := event.Data.(streamRemoval); !ok || removal.SessionID != "old" || removal.Reason != "replaced" {
+			t.Errorf("event = %+v, want replaced removal", event)
+		}
+	}
+	if removals != 1 {
+		t.Errorf("published %d remove events, want 1", removals)
+	}
+
+	// 其他主机和其他项目的同名主机按宕机处理
+	for _, key := range []string{"db-01", "private"} {
+		if server := data.servers[key]; server == nil || server.DownSince == nil {
+			t.Errorf("%s = %+v, want down", key, server)
+		}
+	}
+}
+
+func TestCheckHeartbeatsKeepsOfflineWithoutNewSession(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	recorder := useTestGlobals(t, map[string]*ServerInfo{"old": sessionSample("old", now.Add(-2*time.Minute))})
+	// 状态巡检先推送了离线状态
+	streamHub.lastStatus["old"] = "offline"
+	sub := streamHub.subscribe(func(string) bool { return true })
+
+	checkHeartbeats(now)
+	checkHeartbeats(now.Add(heartbeatCheckInterval))
+
+	server := data.servers["old"]
+	if server == nil || server.DownSince == nil || !server.DownSince.Equal(now) {
+		t.Fatalf("server = %+v, want down since %v", server, now)
+	}
+	if len(recorder.deleted) != 0 || len(alertManager.events) != 1 {
+		t.Errorf("deleted %v, events %+v", recorder.deleted, alertManager.events)
+	}
+
+	// 判定宕机时推送带down_since的状态，且只推送一次
+	if len(sub.events) != 1 {
+		t.Fatalf("published %d events, want 1", len(sub.events))
+	}
+	event := <-sub.events
+	status, ok := event.Data.(ServerStatus)
+	if event.Type != streamEventUpdate || !ok || status.Status != "offline" || status.DownSince == nil || !status.DownSince.Equal(now) {
+		t.Errorf("event = %+v, want offline status with down_since", event)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeReportLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"within limit", `[{"hostname": "web-01"}]`, http.StatusOK},
+		{"too large", `[` + strings.Repeat(`{"hostname": "web-01"},`, 100) + `{}]`, http.StatusRequestEntityTooLarge},
+		{"invalid", `[{"hostname": `, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/api/data/batch", strings.NewReader(tt.body))
+			var samples []*SystemInfo
+			ok := decodeReport(w, r, 1024, &samples)
+			if ok != (tt.status == http.StatusOK) || w.Code != tt.status {
+				t.Errorf("ok %v status %d, want %d", ok, w.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestHandleDataBatchRejectsTooManySamples(t *testing.T) {
+	useTestGlobals(t, map[string]*ServerInfo{})
+	body := `[` + strings.Repeat(`{"hostname": "web-01"},`, maxBatchSamples) + `{}]`
+	w := httptest.NewRecorder()
+	handleDataBatch(w, httptest.NewRequest("POST", "/api/data/batch", strings.NewReader(body)))
+	if w.Code != http.StatusRequestEntityTooLarge || len(data.servers) != 0 {
+		t.Errorf("status %d, %d servers stored", w.Code, len(data.servers))
+	}
+}
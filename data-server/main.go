@@ -1,104 +1,59 @@
 package main
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sort"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
-)
-
-type SystemInfo struct {
-	Hostname    string    `json:"hostname"`
-	SessionID   string    `json:"session_id,omitempty"` // UUID session标识
-	Timestamp   time.Time `json:"timestamp"`
-	CPU         CPUInfo   `json:"cpu"`
-	Memory      MemInfo   `json:"memory"`
-	Disk        DiskInfo  `json:"disk"`
-	Network     NetInfo   `json:"network"`
-	GPU         GPUInfo   `json:"gpu"`  // 保持兼容性，主GPU信息
-	GPUs        []GPUInfo `json:"gpus"` // 所有GPU信息
-	OS          OSInfo    `json:"os"`
-	Temperature TempInfo  `json:"temperature"`
-	ProjectKey  string    `json:"project_key,omitempty"`
-}
-
-type CPUInfo struct {
-	UsagePercent float64 `json:"usage_percent"`
-	CoreCount    int     `json:"core_count"`
-	ModelName    string  `json:"model_name"`
-}
-
-type MemInfo struct {
-	Total        uint64  `json:"total"`
-	Used         uint64  `json:"used"`
-	Free         uint64  `json:"free"`
-	UsagePercent float64 `json:"usage_percent"`
-}
 
-type DiskInfo struct {
-	Total        uint64  `json:"total"`
-	Used         uint64  `json:"used"`
-	Free         uint64  `json:"free"`
-	UsagePercent float64 `json:"usage_percent"`
-}
-
-type NetInfo struct {
-	BytesSent    uint64        `json:"bytes_sent"`     // 总发送字节数
-	BytesRecv    uint64        `json:"bytes_recv"`     // 总接收字节数
-	PacketsSent  uint64        `json:"packets_sent"`   // 总发送包数
-	PacketsRecv  uint64        `json:"packets_recv"`   // 总接收包数
-	SpeedSent    float64       `json:"speed_sent"`     // 发送速率 (KB/s)
-	SpeedRecv    float64       `json:"speed_recv"`     // 接收速率 (KB/s)
-	Interfaces   []NetInterface `json:"interfaces"`     // 网卡详细信息
-}
-
-type NetInterface struct {
-	Name        string   `json:"name"`         // 网卡名称
-	BytesSent   uint64   `json:"bytes_sent"`   // 发送字节数
-	BytesRecv   uint64   `json:"bytes_recv"`   // 接收字节数
-	PacketsSent uint64   `json:"packets_sent"` // 发送包数
-	PacketsRecv uint64   `json:"packets_recv"` // 接收包数
-	SpeedSent   float64  `json:"speed_sent"`   // 发送速率 (KB/s)
-	SpeedRecv   float64  `json:"speed_recv"`   // 接收速率 (KB/s)
-	IsUp        bool     `json:"is_up"`        // 网卡状态
-	MTU         int      `json:"mtu"`          // MTU
-	Addrs       []string `json:"addrs"`        // IP地址列表
-}
-
-type GPUInfo struct {
-	Name         string  `json:"name"`
-	MemoryTotal  uint64  `json:"memory_total"`
-	MemoryUsed   uint64  `json:"memory_used"`
-	UsagePercent float64 `json:"usage_percent"`
-	Temperature  float64 `json:"temperature"`
-}
-
-type OSInfo struct {
-	Platform string `json:"platform"`
-	Version  string `json:"version"`
-	Arch     string `json:"arch"`
-	Uptime   uint64 `json:"uptime"`
-}
+	"serverstatus-monitor/internal/model"
+)
 
-type TempInfo struct {
-	CPUTemp float64            `json:"cpu_temp"`
-	GPUTemp float64            `json:"gpu_temp"`
-	Sensors map[string]float64 `json:"sensors"`
-	MaxTemp float64            `json:"max_temp"`
-	AvgTemp float64            `json:"avg_temp"`
-}
+// 采集数据结构与monitor-agent共用，定义见 internal/model
+type (
+	SystemInfo      = model.SystemInfo
+	CheckResult     = model.CheckResult
+	PluginResult    = model.PluginResult
+	CustomMetric    = model.CustomMetric
+	LogStats        = model.LogStats
+	LogPatternStats = model.LogPatternStats
+	LogLine         = model.LogLine
+	ProcessStats    = model.ProcessStats
+	ProcessInfo     = model.ProcessInfo
+	ServiceStatus   = model.ServiceStatus
+	ContainerInfo   = model.ContainerInfo
+	CgroupInfo      = model.CgroupInfo
+	PressureStat    = model.PressureStat
+	CPUInfo         = model.CPUInfo
+	MemInfo         = model.MemInfo
+	DiskInfo        = model.DiskInfo
+	DiskMount       = model.DiskMount
+	DiskIOStat      = model.DiskIOStat
+	NetInfo         = model.NetInfo
+	SocketStats     = model.SocketStats
+	ListenPort      = model.ListenPort
+	ConntrackStats  = model.ConntrackStats
+	NetInterface    = model.NetInterface
+	GPUInfo         = model.GPUInfo
+	OSInfo          = model.OSInfo
+	TempInfo        = model.TempInfo
+)
 
 type ServerData struct {
 	mu             sync.RWMutex
@@ -112,6 +67,16 @@ type ServerInfo struct {
 	Latest   *SystemInfo   `json:"latest"`
 	History  []*SystemInfo `json:"history"`
 	LastSeen time.Time     `json:"last_seen"`
+
+	Rollup1m []*RollupPoint `json:"-"` // 1分钟聚合，只通过历史查询接口返回
+	Rollup1h []*RollupPoint `json:"-"` // 1小时聚合
+
+	DownSince *time.Time `json:"down_since,omitempty"` // 心跳丢失被判定宕机的时间，恢复上报后清空
+
+	Addr string `json:"-"` // 代理最近一次上报的来源IP，网格探测以此为目标，不通过接口返回
+
+	customSeries  map[string]time.Time // 自定义指标序列最近一次上报的时间，用于限制序列数
+	customLimited bool                 // 上一条样本是否有序列因超限被丢弃
 }
 
 type ServerStatus struct {
@@ -120,8 +85,18 @@ type ServerStatus struct {
 	LastSeen          time.Time `json:"last_seen"`
 	Status            string    `json:"status"`
 	CPUPercent        float64   `json:"cpu_percent"`
+	Load1             float64   `json:"load1"`
+	Load5             float64   `json:"load5"`
+	Load15            float64   `json:"load15"`
+	CPUSteal          float64   `json:"cpu_steal"`  // CPU steal占比
+	CPUIOWait         float64   `json:"cpu_iowait"` // CPU iowait占比
 	MemoryPercent     float64   `json:"memory_percent"`
-	DiskPercent       float64   `json:"disk_percent"`
+	DiskPercent       float64   `json:"disk_percent"`             // 使用率最高的挂载点
+	DiskMount         string    `json:"disk_mount,omitempty"`     // 使用率最高的挂载点路径
+	InodePercent      float64   `json:"inode_percent,omitempty"`  // 最高的inode使用率
+	DiskReadSpeed     float64   `json:"disk_read_speed"`          // 所有磁盘读取速率之和 (KB/s)
+	DiskWriteSpeed    float64   `json:"disk_write_speed"`         // 所有磁盘写入速率之和 (KB/s)
+	DiskUtil          float64   `json:"disk_util"`                // 最繁忙磁盘的利用率
 	OS                string    `json:"os"`
 	CPUTemp           float64   `json:"cpu_temp"`
 	GPUTemp           float64   `json:"gpu_temp"` // 保持兼容性，主GPU温度
@@ -131,6 +106,12 @@ type ServerStatus struct {
 	NetworkSpeedRecv  float64   `json:"network_speed_recv"`  // 网络接收速率 (KB/s)
 	NetworkBytesSent  uint64    `json:"network_bytes_sent"`  // 总发送字节数
 	NetworkBytesRecv  uint64    `json:"network_bytes_recv"`  // 总接收字节数
+	DownSince         *time.Time `json:"down_since,omitempty"` // 宕机时间，在线时为空
+	ServicesDown      []string   `json:"services_down,omitempty"` // 已停止的监控服务
+	ContainersUp      int        `json:"containers_running,omitempty"` // 运行中的容器数
+	Containers        int        `json:"containers_total,omitempty"`   // 上报的容器总数
+	TCPEstablished    int        `json:"tcp_established,omitempty"`    // ESTABLISHED状态的TCP连接数
+	ChecksFailed      []string   `json:"checks_failed,omitempty"`      // 失败的代理侧检查
 }
 
 type ServerConfig struct {
@@ -141,6 +122,25 @@ type ServerConfig struct {
 	RequireAuth  bool   `json:"require_auth"`
 	DataLimit    int    `json:"data_limit"`    // 数据保留条数限制
 	DataInterval int    `json:"data_interval"` // 数据上报间隔(秒)
+
+	Storage         string `json:"storage"`          // 存储类型: memory / file
+	DataDir         string `json:"data_dir"`         // 磁盘存储目录
+	CompactInterval int    `json:"compact_interval"` // 存储压缩间隔(分钟)
+
+	RawRetention      int `json:"raw_retention"`       // 原始数据保留时长(分钟)
+	Rollup1mRetention int `json:"rollup_1m_retention"` // 1分钟聚合保留时长(小时)
+	Rollup1hRetention int `json:"rollup_1h_retention"` // 1小时聚合保留时长(天)
+
+	AlertRules    []AlertRule                `json:"alert_rules,omitempty"`   // 阈值告警规则
+	Notifications map[string][]NotifyChannel `json:"notifications,omitempty"` // 告警通知渠道，key为项目密钥，"*"为全部项目
+	Probes        []ProbeTask                `json:"probes,omitempty"`        // 下发给代理的探测任务
+
+	OfflineRetention int `json:"offline_retention"` // 离线服务器保留时长(小时)，0或负数为一直保留
+	CheckFailures    int `json:"check_failures"`    // 代理侧检查连续失败多少次后告警
+	MaxCustomSeries  int `json:"max_custom_series"` // 每台服务器最多保留的自定义指标序列数，负数为不限制
+
+	RollupCustom   []string `json:"rollup_custom,omitempty"`   // 写入聚合层的自定义指标，默认只保留原始数据
+	TrustedProxies []string `json:"trusted_proxies,omitempty"` // 可信反向代理的IP或CIDR，只有来自这些地址的请求才使用X-Forwarded-For/X-Real-IP
 }
 
 // AccessKey缓存结构
@@ -164,8 +164,23 @@ var (
 		RequireAuth:  false,
 		DataLimit:    1000, // 默认保留1000条数据
 		DataInterval: 5,    // 默认5秒间隔
+
+		Storage:         "file", // 默认持久化，重启后保留历史
+		DataDir:         "data", // 相对路径以配置文件所在目录为基准
+		CompactInterval: 60,
+
+		RawRetention:      120, // 原始数据保留2小时
+		Rollup1mRetention: 48,  // 1分钟聚合保留2天
+		Rollup1hRetention: 30,  // 1小时聚合保留30天
+
+		OfflineRetention: 0,    // 离线服务器一直保留，显示为宕机
+		CheckFailures:    3,    // 检查连续失败3次后告警，避免偶发失败
+		MaxCustomSeries:  1000, // 插件标签过多时限制序列数
 	}
 
+	// 历史数据存储后端
+	store Storage = memoryStorage{}
+
 	// 全局AccessKey缓存
 	accessKeyCache = &AccessKeyCache{
 		cache: make(map[string]string),
@@ -180,6 +195,8 @@ var (
 	requireAuth  = flag.Bool("auth", false, "是否要求API密钥认证")
 	dataLimit    = flag.Int("data-limit", 1000, "数据保留条数限制")
 	dataInterval = flag.Int("data-interval", 5, "推荐的数据上报间隔(秒)")
+	storageType  = flag.String("storage", "", "存储类型: memory / file")
+	dataDir      = flag.String("data-dir", "", "磁盘存储目录")
 	showHelp     = flag.Bool("help", false, "显示帮助信息")
 )
 
@@ -187,6 +204,9 @@ var (
 
 const (
 	offlineThreshold = 30 * time.Second
+	maxBatchSamples  = 1000                         // 批量上报单次最多条数
+	maxSampleBytes   = 4 << 20                      // 单条上报的请求体上限
+	maxBatchBytes    = maxBatchSamples * (64 << 10) // 批量上报的请求体上限，按平均每条64KB
 )
 
 // corsMiddleware 处理CORS跨域请求
@@ -249,6 +269,15 @@ func main() {
 	if *dataInterval != 5 {
 		serverConfig.DataInterval = *dataInterval
 	}
+	if *storageType != "" {
+		serverConfig.Storage = *storageType
+	}
+	if *dataDir != "" {
+		serverConfig.DataDir = *dataDir
+	} else if !filepath.IsAbs(serverConfig.DataDir) {
+		// 不随启动时的工作目录变化
+		serverConfig.DataDir = filepath.Join(filepath.Dir(*configFile), serverConfig.DataDir)
+	}
 
 	log.Println("启动 ServerStatus Monitor Data Server...")
 	log.Printf("端口: %s", serverConfig.Port)
@@ -260,6 +289,30 @@ func main() {
 		log.Println("API认证: 禁用")
 	}
 
+	alertManager.setRules(serverConfig.AlertRules)
+	log.Printf("告警规则: %d 条", len(alertManager.rules))
+	log.Printf("通知渠道: %d 个", notifier.start(serverConfig.Notifications))
+	setProbeTasks(serverConfig.Probes)
+	log.Printf("探测任务: %d 个", len(probeTasks))
+	setTrustedProxies(serverConfig.TrustedProxies)
+	log.Printf("可信代理: %d 个", len(trustedProxies))
+
+	// 初始化存储并恢复历史数据
+	s, err := newStorage(serverConfig)
+	if err != nil {
+		log.Fatalf("初始化存储失败: %v", err)
+	}
+	store = s
+
+	servers, err := store.Load()
+	if err != nil {
+		log.Fatalf("加载历史数据失败: %v", err)
+	}
+	data.servers = servers
+	log.Printf("存储类型: %s, 已恢复 %d 台服务器的历史数据", serverConfig.Storage, len(servers))
+	// 启动时压缩一次，清理上次运行遗留的冗余记录
+	compactStorage()
+
 	r := mux.NewRouter()
 
 	// 添加CORS中间件支持前后端分离
@@ -267,8 +320,13 @@ func main() {
 
 	// API路由
 	r.HandleFunc("/api/data", handleData).Methods("POST")
+	r.HandleFunc("/api/data/batch", handleDataBatch).Methods("POST")
 	r.HandleFunc("/api/register-session", handleRegisterSession).Methods("POST")
 	r.HandleFunc("/api/servers", handleGetServers).Methods("GET")
+	r.HandleFunc("/api/stream", handleStreamPublic).Methods("GET")
+	r.HandleFunc("/api/alerts", handleGetAlerts).Methods("GET")
+	r.HandleFunc("/api/latency", handleGetLatencyMatrix).Methods("GET")
+	r.HandleFunc("/metrics", handleMetricsPublic).Methods("GET")
 	r.HandleFunc("/api/server/{hostname}", handleGetServer).Methods("GET")
 	// 移除基于项目密钥和访问令牌的路由，只保留AccessKey访问方式
 	// 双密钥认证相关路由
@@ -276,6 +334,11 @@ func main() {
 	r.HandleFunc("/api/access/{accessKey}/servers", handleGetServersByAccessKey).Methods("GET")
 	r.HandleFunc("/api/access/{accessKey}/server/{hostname}", handleGetServerByAccessKey).Methods("GET")
 	r.HandleFunc("/api/access/{accessKey}/server-by-session/{sessionID}", handleGetServerBySessionID).Methods("GET")
+	r.HandleFunc("/api/access/{accessKey}/history/{sessionID}", handleQueryHistory).Methods("GET")
+	r.HandleFunc("/api/access/{accessKey}/stream", handleStreamByAccessKey).Methods("GET")
+	r.HandleFunc("/api/access/{accessKey}/alerts", handleGetAlertsByAccessKey).Methods("GET")
+	r.HandleFunc("/api/access/{accessKey}/latency", handleGetLatencyMatrixByAccessKey).Methods("GET")
+	r.HandleFunc("/api/access/{accessKey}/metrics", handleMetricsByAccessKey).Methods("GET")
 	r.HandleFunc("/api/uuid-count", handleGetUUIDCount).Methods("GET")
 
 	// 下载路由
@@ -290,6 +353,9 @@ func main() {
 
 	// 启动清理协程
 	go cleanupRoutine()
+	go heartbeatRoutine()
+	go compactRoutine()
+	go statusWatchRoutine()
 
 	log.Printf("API服务器启动在 %s:%s", serverConfig.Host, serverConfig.Port)
 	if serverConfig.Host == "0.0.0.0" {
@@ -300,13 +366,128 @@ func main() {
 		log.Printf("API文档地址: http://%s:%s/API.md", serverConfig.Host, serverConfig.Port)
 	}
 	log.Println("前后端已分离，前端需独立部署")
-	log.Fatal(http.ListenAndServe(serverConfig.Host+":"+serverConfig.Port, r))
+
+	srv := &http.Server{Addr: serverConfig.Host + ":" + serverConfig.Port, Handler: r}
+	srv.RegisterOnShutdown(streamHub.closeAll)
+	stopped := make(chan struct{})
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		log.Println("正在关闭服务器...")
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+		close(stopped)
+	}()
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		store.Close()
+		log.Fatal(err)
+	}
+	// 等待处理中的上报写入存储后再关闭
+	<-stopped
+	if err := store.Close(); err != nil {
+		log.Printf("[存储] 关闭失败: %v", err)
+	}
 }
 
 func handleData(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[数据上报] 收到数据上报请求，来源IP: %s", r.RemoteAddr)
 
-	var projectKey string
+	projectKey, ok := authorizeReport(w, r)
+	if !ok {
+		return
+	}
+
+	var info SystemInfo
+	if !decodeReport(w, r, maxSampleBytes, &info) {
+		return
+	}
+
+	// 为数据添加项目密钥标识
+	info.ProjectKey = projectKey
+
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	now := time.Now()
+	serverKey, newer := ingestSample(&info, now)
+	if newer {
+		data.servers[serverKey].Addr = clientIP(r)
+		streamHub.publishStatus(serverKey, data.servers[serverKey], now)
+	}
+
+	// 响应中附带该代理需要执行的探测任务
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ProbeResponse{Probes: probesFor(serverKey, projectKey, info.Tags, now)})
+	log.Printf("收到 %s 的数据上报 (Session: %s)", info.Hostname, serverKey)
+}
+
+// handleDataBatch 批量接收上报数据（代理补传离线期间缓存的数据），按时间顺序写入历史
+func handleDataBatch(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[数据上报] 收到批量上报请求，来源IP: %s", r.RemoteAddr)
+
+	projectKey, ok := authorizeReport(w, r)
+	if !ok {
+		return
+	}
+
+	// 先限制请求体大小再解析，避免未认证的请求让服务器分配任意大的内存
+	var samples []*SystemInfo
+	if !decodeReport(w, r, maxBatchBytes, &samples) {
+		return
+	}
+	if len(samples) > maxBatchSamples {
+		http.Error(w, fmt.Sprintf("单次最多上报 %d 条数据", maxBatchSamples), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	now := time.Now()
+	sort.SliceStable(samples, func(i, j int) bool {
+		return sampleTime(samples[i], now).Before(sampleTime(samples[j], now))
+	})
+
+	data.mu.Lock()
+	accepted := 0
+	updated := make(map[string]bool)
+	for _, info := range samples {
+		if info == nil {
+			continue
+		}
+		info.ProjectKey = projectKey
+		if key, newer := ingestSample(info, now); newer {
+			updated[key] = true
+		}
+		accepted++
+	}
+	// 每台服务器只推送一次最终状态
+	for key := range updated {
+		streamHub.publishStatus(key, data.servers[key], now)
+	}
+	data.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"accepted": accepted})
+	log.Printf("批量上报完成: %d 条", accepted)
+}
+
+// decodeReport 解析上报的请求体，超过limit字节时返回413
+func decodeReport(w http.ResponseWriter, r *http.Request, limit int64, v interface{}) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("请求体超过 %d 字节", limit), http.StatusRequestEntityTooLarge)
+			return false
+		}
+		http.Error(w, "解析数据失败", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// authorizeReport 校验上报请求的服务器密钥，返回数据所属的项目密钥
+func authorizeReport(w http.ResponseWriter, r *http.Request) (string, bool) {
 	// 服务器密钥验证
 	if serverConfig.RequireAuth {
 		serverKey := r.Header.Get("X-Server-Key")
@@ -316,109 +497,273 @@ func handleData(w http.ResponseWriter, r *http.Request) {
 		if serverConfig.ServerKey != "" && serverKey != serverConfig.ServerKey {
 			log.Printf("[数据上报] 验证失败: 服务器密钥不匹配 - 收到: %s, 期望: %s", serverKey, serverConfig.ServerKey)
 			http.Error(w, "无效的服务器密钥", http.StatusUnauthorized)
-			return
+			return "", false
 		}
 		log.Printf("[数据上报] 服务器密钥验证通过")
 	}
 
 	// 获取ProjectKey用于数据分组（用户自定义，不验证）
-	projectKey = r.Header.Get("X-Project-Key")
+	projectKey := r.Header.Get("X-Project-Key")
 	if projectKey == "" {
 		projectKey = "default" // 默认密钥组
 	}
 	log.Printf("[数据上报] ProjectKey: %s", projectKey)
+	return projectKey, true
+}
 
-	var info SystemInfo
-	err := json.NewDecoder(r.Body).Decode(&info)
-	if err != nil {
-		http.Error(w, "解析数据失败", http.StatusBadRequest)
-		return
-	}
-
-	// 为数据添加项目密钥标识
-	info.ProjectKey = projectKey
-
-	data.mu.Lock()
-	defer data.mu.Unlock()
-
+// ingestSample 保存一条上报数据并评估告警，返回服务器标识及该样本是否成为最新数据（调用方需持有data.mu写锁）
+func ingestSample(info *SystemInfo, now time.Time) (string, bool) {
 	// 使用sessionID作为key，如果没有sessionID则使用hostname（向后兼容）
 	serverKey := info.SessionID
 	if serverKey == "" {
 		serverKey = info.Hostname
 	}
 
-	if data.servers[serverKey] == nil {
-		data.servers[serverKey] = &ServerInfo{
+	existing := data.servers[serverKey]
+	if existing == nil {
+		log.Printf("新服务器注册: %s (Session: %s)", info.Hostname, serverKey)
+	}
+
+	// 补传的旧数据只写入历史，不影响在线状态和告警
+	newer := isNewerSample(existing, info, now)
+	var prev *SystemInfo
+	if existing != nil {
+		prev = existing.Latest
+	}
+	if newer && existing != nil && existing.DownSince != nil {
+		alertManager.resolveAgentDown(serverKey, existing, now, "已恢复上报")
+	}
+	storeSample(data.servers, serverKey, info, now)
+	if err := store.Append(serverKey, historySample(info), now); err != nil {
+		log.Printf("[存储] 写入 %s 的数据失败: %v", serverKey, err)
+	}
+	if newer {
+		alertManager.evaluate(serverKey, info, sampleTime(info, now))
+		alertManager.evaluateServices(serverKey, prev, info, sampleTime(info, now))
+		alertManager.evaluateChecks(serverKey, info, sampleTime(info, now))
+	}
+	return serverKey, newer
+}
+
+// historySample 返回写入历史和持久化存储的样本，进程列表、监听端口和日志匹配行只保留在最新数据中
+func historySample(info *SystemInfo) *SystemInfo {
+	if info.Processes == nil && info.Network.Listening == nil && !hasRecentLogLines(info) {
+		return info
+	}
+	sample := *info
+	sample.Processes = nil
+	sample.Network.Listening = nil
+	if sample.Logs != nil {
+		sample.Logs = make([]LogStats, len(info.Logs))
+		for i, l := range info.Logs {
+			l.Recent = nil
+			sample.Logs[i] = l
+		}
+	}
+	return &sample
+}
+
+func hasRecentLogLines(info *SystemInfo) bool {
+	for _, l := range info.Logs {
+		if len(l.Recent) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// isNewerSample 判断样本是否不早于服务器当前的最新数据
+func isNewerSample(server *ServerInfo, info *SystemInfo, seen time.Time) bool {
+	return server == nil || server.Latest == nil || !sampleTime(info, seen).Before(server.Latest.Timestamp)
+}
+
+// storeSample 将一条上报数据写入内存（调用方需持有data.mu写锁）
+// 启动时回放存储日志也走这里，保证重启前后的裁剪结果一致
+func storeSample(servers map[string]*ServerInfo, key string, info *SystemInfo, seen time.Time) *ServerInfo {
+	server := servers[key]
+	if server == nil {
+		server = &ServerInfo{
 			History: make([]*SystemInfo, 0, serverConfig.DataLimit),
 		}
-		log.Printf("新服务器注册: %s (Session: %s)", info.Hostname, serverKey)
+		servers[key] = server
 	}
 
-	server := data.servers[serverKey]
-	server.Latest = &info
-	server.LastSeen = time.Now()
+	ts := sampleTime(info, seen)
+	limitCustomSeries(server, info, ts)
+	hidePeerTargets(info)
 
-	// 添加到历史记录
-	server.History = append(server.History, &info)
+	// 代理补传的离线数据可能早于最新数据，只有更新的样本才替换Latest
+	if isNewerSample(server, info, seen) {
+		server.Latest = info
+		server.DownSince = nil
+	}
+	server.LastSeen = seen
+
+	// 按时间顺序插入历史记录，原始数据同时受条数和时长限制
+	// 代理未带时间戳时以接收时间记录，避免零时间的样本排在最前并被立即裁剪
+	sample := historySample(info)
+	if sample.Timestamp.IsZero() {
+		stamped := *sample
+		stamped.Timestamp = ts
+		sample = &stamped
+	}
+	server.History = insertHistory(server.History, sample, ts)
 	if len(server.History) > serverConfig.DataLimit {
-		server.History = server.History[1:]
+		server.History = server.History[len(server.History)-serverConfig.DataLimit:]
+	}
+	if serverConfig.RawRetention > 0 {
+		server.History = trimRawHistory(server.History, sampleTime(server.Latest, seen).Add(-time.Duration(serverConfig.RawRetention)*time.Minute))
 	}
 
-	w.WriteHeader(http.StatusOK)
-	log.Printf("收到 %s 的数据上报 (Session: %s)", info.Hostname, serverKey)
+	// 更长时间范围的趋势由聚合层提供
+	rollupSample(server, info, ts)
+	return server
 }
 
 func handleGetServers(w http.ResponseWriter, r *http.Request) {
 	data.mu.RLock()
 	defer data.mu.RUnlock()
 
-	var servers []ServerStatus
-	now := time.Now()
+	// 默认只显示ProjectKey为"public"的服务器
+	servers := collectServerStatuses(isPublicProject)
 
-	for _, server := range data.servers {
-		if server.Latest == nil {
-			continue
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(servers); err != nil {
+		log.Printf("Error encoding servers response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// buildServerStatus 根据服务器最新数据生成列表状态（调用方需持有data.mu读锁）
+func buildServerStatus(server *ServerInfo, now time.Time) ServerStatus {
+	status := "online"
+	if isOffline(server, now) {
+		status = "offline"
+	}
+
+
+	diskPercent, diskMount, inodePercent := diskSummary(server.Latest)
+	diskIO := diskIOSummary(server.Latest)
+	running, _ := containerSummary(server.Latest)
+
+	return ServerStatus{
+		Hostname:         server.Latest.Hostname,
+		SessionID:        server.Latest.SessionID,
+		LastSeen:         server.LastSeen,
+		Status:           status,
+		CPUPercent:       server.Latest.CPU.UsagePercent,
+		Load1:            server.Latest.CPU.Load1,
+		Load5:            server.Latest.CPU.Load5,
+		Load15:           server.Latest.CPU.Load15,
+		CPUSteal:         server.Latest.CPU.StealPercent,
+		CPUIOWait:        server.Latest.CPU.IOWaitPercent,
+		MemoryPercent:    server.Latest.MemoryPercent(),
+		DiskPercent:      diskPercent,
+		DiskMount:        diskMount,
+		InodePercent:     inodePercent,
+		DiskReadSpeed:    diskIO.ReadSpeed,
+		DiskWriteSpeed:   diskIO.WriteSpeed,
+		DiskUtil:         diskIO.UtilPercent,
+		OS:               server.Latest.OS.Platform,
+		CPUTemp:          server.Latest.Temperature.CPUTemp,
+		GPUTemp:          server.Latest.Temperature.GPUTemp,
+		GPUs:             server.Latest.GPUs, // 添加所有GPU信息
+		MaxTemp:          server.Latest.Temperature.MaxTemp,
+		NetworkSpeedSent: server.Latest.Network.SpeedSent,
+		NetworkSpeedRecv: server.Latest.Network.SpeedRecv,
+		NetworkBytesSent: server.Latest.Network.BytesSent,
+		NetworkBytesRecv: server.Latest.Network.BytesRecv,
+		DownSince:        server.DownSince,
+		ServicesDown:     servicesDown(server.Latest),
+		ContainersUp:     running,
+		Containers:       len(server.Latest.Containers),
+		TCPEstablished:   tcpEstablished(server.Latest),
+		ChecksFailed:     checksFailed(server.Latest),
+	}
+}
+
+// containerSummary 返回运行中的容器数和所有容器的重启次数之和
+func containerSummary(info *SystemInfo) (running, restarts int) {
+	for _, c := range info.Containers {
+		if c.State == "running" {
+			running++
 		}
+		restarts += c.RestartCount
+	}
+	return running, restarts
+}
 
-		// 默认只显示ProjectKey为"public"的服务器
-		if server.Latest.ProjectKey != "public" {
-			continue
+// tcpEstablished 返回ESTABLISHED状态的TCP连接数，旧版代理没有套接字统计时为0
+func tcpEstablished(info *SystemInfo) int {
+	if info.Network.Sockets == nil {
+		return 0
+	}
+	return info.Network.Sockets.Established
+}
+
+// diskSummary 返回使用率最高的挂载点，旧版代理没有挂载点列表时使用根分区
+func diskSummary(info *SystemInfo) (percent float64, mount string, inodePercent float64) {
+	if len(info.Disks) == 0 {
+		return info.Disk.UsagePercent, "", 0
+	}
+	for i, d := range info.Disks {
+		if i == 0 || d.UsagePercent > percent {
+			percent = d.UsagePercent
+			mount = d.Mountpoint
+		}
+		if d.InodesUsagePercent > inodePercent {
+			inodePercent = d.InodesUsagePercent
 		}
+	}
+	return percent, mount, inodePercent
+}
 
-		status := "online"
-		if now.Sub(server.Latest.Timestamp) > offlineThreshold {
-			status = "offline"
+// diskIOSummary 汇总所有磁盘的I/O：速率和IOPS求和，await和利用率取最大值
+func diskIOSummary(info *SystemInfo) DiskIOStat {
+	var sum DiskIOStat
+	for _, d := range info.DiskIO {
+		sum.ReadSpeed += d.ReadSpeed
+		sum.WriteSpeed += d.WriteSpeed
+		sum.ReadIOPS += d.ReadIOPS
+		sum.WriteIOPS += d.WriteIOPS
+		if d.Await > sum.Await {
+			sum.Await = d.Await
+		}
+		if d.UtilPercent > sum.UtilPercent {
+			sum.UtilPercent = d.UtilPercent
 		}
+	}
+	return sum
+}
+
+// collectServerStatuses 收集项目匹配的服务器状态，按主机名排序（调用方需持有data.mu读锁）
+func collectServerStatuses(match func(projectKey string) bool) []ServerStatus {
+	var servers []ServerStatus
+	now := time.Now()
 
-		servers = append(servers, ServerStatus{
-			Hostname:          server.Latest.Hostname,
-			SessionID:         server.Latest.SessionID,
-			LastSeen:          server.LastSeen,
-			Status:            status,
-			CPUPercent:        server.Latest.CPU.UsagePercent,
-			MemoryPercent:     server.Latest.Memory.UsagePercent,
-			DiskPercent:       server.Latest.Disk.UsagePercent,
-			OS:                server.Latest.OS.Platform,
-			CPUTemp:           server.Latest.Temperature.CPUTemp,
-			GPUTemp:           server.Latest.Temperature.GPUTemp,
-			GPUs:              server.Latest.GPUs, // 添加所有GPU信息
-			MaxTemp:           server.Latest.Temperature.MaxTemp,
-			NetworkSpeedSent:  server.Latest.Network.SpeedSent,
-			NetworkSpeedRecv:  server.Latest.Network.SpeedRecv,
-			NetworkBytesSent:  server.Latest.Network.BytesSent,
-			NetworkBytesRecv:  server.Latest.Network.BytesRecv,
-		})
+	for _, server := range data.servers {
+		if server.Latest == nil || !match(server.Latest.ProjectKey) {
+			continue
+		}
+		servers = append(servers, buildServerStatus(server, now))
 	}
 
 	// 按主机名排序
 	sort.Slice(servers, func(i, j int) bool {
 		return servers[i].Hostname < servers[j].Hostname
 	})
+	return servers
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(servers); err != nil {
-		log.Printf("Error encoding servers response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+// isPublicProject 公开面板只展示public项目
+func isPublicProject(projectKey string) bool {
+	return projectKey == "public"
+}
+
+// accessKeyMatcher 返回按访问密钥匹配项目的判断函数
+func accessKeyMatcher(accessKey string) func(projectKey string) bool {
+	return func(projectKey string) bool {
+		return isServerMatchingAccessKey(projectKey, accessKey)
 	}
 }
 
@@ -459,14 +804,16 @@ type AccessKeyResponse struct {
 
 // SessionRegisterRequest session注册请求结构
 type SessionRegisterRequest struct {
-	Hostname   string `json:"hostname"`
-	ProjectKey string `json:"project_key"`
+	Hostname   string   `json:"hostname"`
+	ProjectKey string   `json:"project_key"`
+	Tags       []string `json:"tags,omitempty"`
 }
 
 // SessionRegisterResponse session注册响应结构
 type SessionRegisterResponse struct {
-	SessionID string `json:"session_id"`
-	Hostname  string `json:"hostname"`
+	SessionID string       `json:"session_id"`
+	Hostname  string       `json:"hostname"`
+	Probes    []ProbeCheck `json:"probes"` // 该代理需要执行的探测任务
 }
 
 // handleRegisterSession 注册新的session
@@ -510,6 +857,9 @@ func handleRegisterSession(w http.ResponseWriter, r *http.Request) {
 		SessionID: sessionID,
 		Hostname:  req.Hostname,
 	}
+	data.mu.RLock()
+	response.Probes = probesFor(sessionID, req.ProjectKey, req.Tags, time.Now())
+	data.mu.RUnlock()
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
@@ -565,50 +915,8 @@ func handleGetServersByAccessKey(w http.ResponseWriter, r *http.Request) {
 	data.mu.RLock()
 	defer data.mu.RUnlock()
 
-	now := time.Now()
-	var servers []ServerStatus
-
 	// 遍历所有服务器，查找匹配访问密钥的数据
-	for _, server := range data.servers {
-		if server.Latest == nil {
-			continue
-		}
-
-		// 检查服务器数据是否匹配访问密钥
-		// 这里需要根据服务器的ProjectKey重新计算访问密钥进行匹配
-		if !isServerMatchingAccessKey(server.Latest.ProjectKey, accessKey) {
-			continue
-		}
-
-		status := "online"
-		if now.Sub(server.Latest.Timestamp) > offlineThreshold {
-			status = "offline"
-		}
-
-		servers = append(servers, ServerStatus{
-			Hostname:          server.Latest.Hostname,
-			SessionID:         server.Latest.SessionID,
-			LastSeen:          server.LastSeen,
-			Status:            status,
-			CPUPercent:        server.Latest.CPU.UsagePercent,
-			MemoryPercent:     server.Latest.Memory.UsagePercent,
-			DiskPercent:       server.Latest.Disk.UsagePercent,
-			OS:                server.Latest.OS.Platform,
-			CPUTemp:           server.Latest.Temperature.CPUTemp,
-			GPUTemp:           server.Latest.Temperature.GPUTemp,
-			GPUs:              server.Latest.GPUs, // 添加所有GPU信息
-			MaxTemp:           server.Latest.Temperature.MaxTemp,
-			NetworkSpeedSent:  server.Latest.Network.SpeedSent,
-			NetworkSpeedRecv:  server.Latest.Network.SpeedRecv,
-			NetworkBytesSent:  server.Latest.Network.BytesSent,
-			NetworkBytesRecv:  server.Latest.Network.BytesRecv,
-		})
-	}
-
-	// 按主机名排序
-	sort.Slice(servers, func(i, j int) bool {
-		return servers[i].Hostname < servers[j].Hostname
-	})
+	servers := collectServerStatuses(accessKeyMatcher(accessKey))
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(servers); err != nil {
@@ -954,23 +1262,6 @@ echo "后台运行: nohup ./monitor-agent-linux > agent.log 2>&1 &"
 `
 }
 
-func cleanupRoutine() {
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		data.mu.Lock()
-		now := time.Now()
-		for hostname, server := range data.servers {
-			if server.Latest != nil && now.Sub(server.Latest.Timestamp) > 10*time.Minute {
-				log.Printf("清理长时间离线的服务器: %s", hostname)
-				delete(data.servers, hostname)
-			}
-		}
-		data.mu.Unlock()
-	}
-}
-
 // isValidProjectKey 验证项目密钥
 func isValidProjectKey(key string) bool {
 	if key == "" {
@@ -1091,6 +1382,48 @@ func loadServerConfig() {
 	if fileConfig.DataInterval > 0 {
 		serverConfig.DataInterval = fileConfig.DataInterval
 	}
+	if fileConfig.Storage != "" {
+		serverConfig.Storage = fileConfig.Storage
+	}
+	if fileConfig.DataDir != "" {
+		serverConfig.DataDir = fileConfig.DataDir
+	}
+	if fileConfig.CompactInterval > 0 {
+		serverConfig.CompactInterval = fileConfig.CompactInterval
+	}
+	if fileConfig.RawRetention > 0 {
+		serverConfig.RawRetention = fileConfig.RawRetention
+	}
+	if fileConfig.Rollup1mRetention > 0 {
+		serverConfig.Rollup1mRetention = fileConfig.Rollup1mRetention
+	}
+	if fileConfig.Rollup1hRetention > 0 {
+		serverConfig.Rollup1hRetention = fileConfig.Rollup1hRetention
+	}
+	if fileConfig.OfflineRetention != 0 {
+		serverConfig.OfflineRetention = fileConfig.OfflineRetention
+	}
+	if fileConfig.CheckFailures > 0 {
+		serverConfig.CheckFailures = fileConfig.CheckFailures
+	}
+	if fileConfig.MaxCustomSeries != 0 {
+		serverConfig.MaxCustomSeries = fileConfig.MaxCustomSeries
+	}
+	if len(fileConfig.RollupCustom) > 0 {
+		serverConfig.RollupCustom = fileConfig.RollupCustom
+	}
+	if len(fileConfig.AlertRules) > 0 {
+		serverConfig.AlertRules = fileConfig.AlertRules
+	}
+	if len(fileConfig.Notifications) > 0 {
+		serverConfig.Notifications = fileConfig.Notifications
+	}
+	if len(fileConfig.Probes) > 0 {
+		serverConfig.Probes = fileConfig.Probes
+	}
+	if len(fileConfig.TrustedProxies) > 0 {
+		serverConfig.TrustedProxies = fileConfig.TrustedProxies
+	}
 
 	log.Printf("加载服务器配置文件: %s", *configFile)
 }
@@ -1140,6 +1473,10 @@ func printServerUsage() {
 	fmt.Println("        每台客户端数据保留条数限制 (默认: 1000)")
 	fmt.Println("  -data-interval int")
 	fmt.Println("        推荐的数据上报间隔秒数 (默认: 5)")
+	fmt.Println("  -storage string")
+	fmt.Println("        存储类型 memory / file (默认: file)")
+	fmt.Println("  -data-dir string")
+	fmt.Println("        磁盘存储目录 (默认: data)")
 	fmt.Println("  -help")
 	fmt.Println("        显示此帮助信息")
 	fmt.Println()
@@ -1178,19 +1515,50 @@ func printServerUsage() {
 	fmt.Println(`    "port": "8080",`)
 	fmt.Println(`    "require_auth": true,`)
 	fmt.Println(`    "data_limit": 1000,`)
-	fmt.Println(`    "data_interval": 5`)
+	fmt.Println(`    "data_interval": 5,`)
+	fmt.Println(`    "storage": "file",`)
+	fmt.Println(`    "data_dir": "data",`)
+	fmt.Println(`    "compact_interval": 60,`)
+	fmt.Println(`    "raw_retention": 120,`)
+	fmt.Println(`    "rollup_1m_retention": 48,`)
+	fmt.Println(`    "rollup_1h_retention": 30,`)
+	fmt.Println(`    "offline_retention": 24,`)
+	fmt.Println(`    "check_failures": 3,`)
+	fmt.Println(`    "max_custom_series": 1000,`)
+	fmt.Println(`    "rollup_custom": ["custom[jobs].queue.depth", "custom[statsd].api.*"],`)
+	fmt.Println(`    "trusted_proxies": ["127.0.0.1", "10.0.0.0/8"],`)
+	fmt.Println(`    "alert_rules": [`)
+	fmt.Println(`      {"name": "high-cpu", "metric": "cpu.usage_percent", "op": ">", "threshold": 90, "for": 60}`)
+	fmt.Println(`    ],`)
+	fmt.Println(`    "notifications": {`)
+	fmt.Println(`      "*": [{"type": "webhook", "url": "https://example.com/hook", "secret": "sign-secret"}]`)
+	fmt.Println(`    },`)
+	fmt.Println(`    "probes": [`)
+	fmt.Println(`      {"name": "api", "type": "http", "target": "https://api.example.com/health", "tags": ["edge"]},`)
+	fmt.Println(`      {"name": "mesh", "mesh": true, "interval": 60, "projects": ["project-alpha"]}`)
+	fmt.Println(`    ]`)
 	fmt.Println(`  }`)
 	fmt.Println()
 	fmt.Println("API端点:")
 	fmt.Println("  POST /api/data       - 接收监控数据上报")
+	fmt.Println("  POST /api/data/batch - 批量接收监控数据 (代理补传离线数据)")
 	fmt.Println("  POST /api/register-session - 注册新的session获取UUID")
 	fmt.Println("  GET  /api/servers    - 获取服务器列表")
+	fmt.Println("  GET  /api/stream     - 实时推送服务器状态 (SSE)")
+	fmt.Println("  GET  /api/alerts     - 获取当前告警和最近告警事件")
+	fmt.Println("  GET  /api/latency    - 获取代理之间的网格探测延迟矩阵")
+	fmt.Println("  GET  /metrics        - Prometheus指标 (Bearer服务器密钥可导出全部项目)")
 	fmt.Println("  GET  /api/server/{hostname} - 获取特定服务器详情")
 	// 已移除项目密钥和访问令牌相关API端点
 	fmt.Println("  POST /api/generate-access-key - 生成访问密钥 (双密钥认证)")
 	fmt.Println("  GET  /api/access/{accessKey}/servers - 根据访问密钥获取服务器列表")
 	fmt.Println("  GET  /api/access/{accessKey}/server/{hostname} - 根据访问密钥获取特定服务器")
 	fmt.Println("  GET  /api/access/{accessKey}/server-by-session/{sessionID} - 根据访问密钥和sessionID获取特定服务器")
+	fmt.Println("  GET  /api/access/{accessKey}/history/{sessionID} - 按时间范围和指标查询历史数据")
+	fmt.Println("  GET  /api/access/{accessKey}/stream - 根据访问密钥实时推送服务器状态 (SSE)")
+	fmt.Println("  GET  /api/access/{accessKey}/alerts - 根据访问密钥获取项目告警")
+	fmt.Println("  GET  /api/access/{accessKey}/latency - 根据访问密钥获取项目的延迟矩阵")
+	fmt.Println("  GET  /api/access/{accessKey}/metrics - 根据访问密钥导出Prometheus指标")
 
 	fmt.Println()
 	fmt.Println("双密钥认证使用说明:")
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"serverstatus-monitor/internal/prom"
+)
+
+// handleMetricsPublic 导出public项目的指标；携带 Authorization: Bearer <server_key> 时导出全部项目
+func handleMetricsPublic(w http.ResponseWriter, r *http.Request) {
+	match := isPublicProject
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") && serverConfig.ServerKey != "" {
+		if strings.TrimPrefix(auth, "Bearer ") != serverConfig.ServerKey {
+			http.Error(w, "无效的服务器密钥", http.StatusUnauthorized)
+			return
+		}
+		match = func(string) bool { return true }
+	}
+	writeMetrics(w, match)
+}
+
+// handleMetricsByAccessKey 导出访问密钥对应项目的指标
+func handleMetricsByAccessKey(w http.ResponseWriter, r *http.Request) {
+	accessKey := mux.Vars(r)["accessKey"]
+	if accessKey == "" {
+		http.Error(w, "无效的访问密钥", http.StatusUnauthorized)
+		return
+	}
+	writeMetrics(w, accessKeyMatcher(accessKey))
+}
+
+func writeMetrics(w http.ResponseWriter, match func(projectKey string) bool) {
+	p := prom.NewWriter()
+	now := time.Now()
+
+	data.mu.RLock()
+	keys := make([]string, 0, len(data.servers))
+	for key, server := range data.servers {
+		if server.Latest != nil && match(server.Latest.ProjectKey) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		exportServerMetrics(p, data.servers[key], now)
+	}
+	data.mu.RUnlock()
+
+	w.Header().Set("Content-Type", prom.ContentType)
+	if _, err := w.Write(p.Bytes()); err != nil {
+		log.Printf("Error writing metrics response: %v", err)
+	}
+}
+
+// exportServerMetrics 将服务器最新样本导出为Prometheus指标（调用方需持有data.mu锁）
+func exportServerMetrics(p *prom.Writer, server *ServerInfo, now time.Time) {
+	info := server.Latest
+	base := []string{"hostname", info.Hostname, "session_id", info.SessionID, "project", info.ProjectKey}
+
+	up := 1.0
+	if isOffline(server, now) {
+		up = 0
+	}
+	p.Gauge("up", "Whether the agent reported within the offline threshold (1 = online).", up, base...)
+	prom.WriteSystem(p, info, base)
+}
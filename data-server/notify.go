@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	notifyQueueSize      = 256              // 待发送通知队列长度
+	notifyWorkers        = 4                // 并发发送数
+	notifyDefaultRetries = 3                // 默认失败重试次数
+	notifyDefaultTimeout = 10               // 默认请求超时(秒)
+	notifyBackoffMax     = 30 * time.Second // 重试退避上限
+	notifyAllProjects    = "*"              // 对全部项目生效的渠道
+	notifySignatureKey   = "X-ServerStatus-Signature"
+	telegramDefaultAPI   = "https://api.telegram.org"
+)
+
+// 通知渠道类型
+const (
+	notifyWebhook  = "webhook"
+	notifySMTP     = "smtp"
+	notifySlack    = "slack"
+	notifyDingTalk = "dingtalk"
+	notifyFeishu   = "feishu"
+	notifyTelegram = "telegram"
+)
+
+// NotifyChannel 告警通知渠道配置
+type NotifyChannel struct {
+	Type       string            `json:"type"`                 // webhook / smtp / slack / dingtalk / feishu / telegram
+	Name       string            `json:"name,omitempty"`       // 渠道名称，用于日志
+	URL        string            `json:"url,omitempty"`        // webhook / slack / dingtalk / feishu 地址
+	Headers    map[string]string `json:"headers,omitempty"`    // webhook 附加请求头
+	Template   string            `json:"template,omitempty"`   // webhook 请求体模板(text/template)，默认为事件JSON
+	Secret     string            `json:"secret,omitempty"`     // webhook HMAC签名 / 钉钉、飞书加签密钥
+	Severities []string          `json:"severities,omitempty"` // 只发送这些级别，空为全部
+	Retries    int               `json:"retries,omitempty"`    // 失败重试次数，默认3
+	Timeout    int               `json:"timeout,omitempty"`    // 请求超时(秒)，默认10
+
+	// SMTP
+	SMTPHost string   `json:"smtp_host,omitempty"`
+	SMTPPort int      `json:"smtp_port,omitempty"` // 默认25
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+
+	// Telegram
+	BotToken string `json:"bot_token,omitempty"`
+	ChatID   string `json:"chat_id,omitempty"`
+	APIBase  string `json:"api_base,omitempty"` // 默认 https://api.telegram.org
+
+	tmpl *template.Template // 加载配置时解析的webhook模板
+}
+
+// notifyJob 一次待发送的通知
+type notifyJob struct {
+	channel *NotifyChannel
+	event   AlertEvent
+}
+
+// Notifier 按项目将告警事件分发到通知渠道，异步发送
+type Notifier struct {
+	channels map[string][]*NotifyChannel // key: projectKey，"*" 为全部项目
+	queue    chan notifyJob
+	client   *http.Client
+}
+
+var notifier = &Notifier{
+	channels: make(map[string][]*NotifyChannel),
+	queue:    make(chan notifyJob, notifyQueueSize),
+	client:   &http.Client{},
+}
+
+// start 校验并加载渠道配置，启动发送协程并订阅告警事件
+func (n *Notifier) start(config map[string][]NotifyChannel) int {
+	count := 0
+	for project, channels := range config {
+		for i := range channels {
+			channel := channels[i]
+			if err := validateNotifyChannel(&channel); err != nil {
+				log.Printf("[通知] 忽略项目 %s 的无效渠道 %s: %v", project, channel.label(), err)
+				continue
+			}
+			n.channels[project] = append(n.channels[project], &channel)
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+
+	for i := 0; i < notifyWorkers; i++ {
+		go n.worker()
+	}
+	alertManager.subscribe(n.notify)
+	return count
+}
+
+func validateNotifyChannel(channel *NotifyChannel) error {
+	switch channel.Type {
+	case notifyWebhook, notifySlack, notifyDingTalk, notifyFeishu:
+		if channel.URL == "" {
+			return fmt.Errorf("缺少url")
+		}
+	case notifySMTP:
+		if channel.SMTPHost == "" || channel.From == "" || len(channel.To) == 0 {
+			return fmt.Errorf("缺少smtp_host/from/to")
+		}
+		if channel.SMTPPort == 0 {
+			channel.SMTPPort = 25
+		}
+	case notifyTelegram:
+		if channel.BotToken == "" || channel.ChatID == "" {
+			return fmt.Errorf("缺少bot_token/chat_id")
+		}
+		if channel.APIBase == "" {
+			channel.APIBase = telegramDefaultAPI
+		}
+	default:
+		return fmt.Errorf("不支持的type: %s", channel.Type)
+	}
+
+	if channel.Template != "" {
+		tmpl, err := template.New("body").Funcs(notifyTemplateFuncs).Parse(channel.Template)
+		if err != nil {
+			return fmt.Errorf("模板解析失败: %v", err)
+		}
+		channel.tmpl = tmpl
+	}
+	if channel.Retries == 0 {
+		channel.Retries = notifyDefaultRetries
+	}
+	if channel.Timeout <= 0 {
+		channel.Timeout = notifyDefaultTimeout
+	}
+	return nil
+}
+
+func (c *NotifyChannel) label() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Type
+}
+
+func (c *NotifyChannel) accepts(severity string) bool {
+	if len(c.Severities) == 0 {
+		return true
+	}
+	for _, s := range c.Severities {
+		if s == severity {
+			return true
+		}
+	}
+	return false
+}
+
+// notify 告警监听回调，在持有data.mu时被调用，只入队不做网络请求
+func (n *Notifier) notify(event AlertEvent) {
+	channels := append(append([]*NotifyChannel{}, n.channels[notifyAllProjects]...), n.channels[event.ProjectKey]...)
+	for _, channel := range channels {
+		if !channel.accepts(event.Severity) {
+			continue
+		}
+		select {
+		case n.queue <- notifyJob{channel: channel, event: event}:
+		default:
+			log.Printf("[通知] 队列已满，丢弃 %s 的通知: %s", channel.label(), event.Message)
+		}
+	}
+}
+
+func (n *Notifier) worker() {
+	for job := range n.queue {
+		n.deliver(job.channel, job.event)
+	}
+}
+
+// deliver 发送通知，失败时按指数退避重试
+func (n *Notifier) deliver(channel *NotifyChannel, event AlertEvent) {
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		err := n.send(channel, event)
+		if err == nil {
+			return
+		}
+		if attempt >= channel.Retries {
+			log.Printf("[通知] %s 发送失败，已放弃: %v", channel.label(), err)
+			return
+		}
+		log.Printf("[通知] %s 发送失败，%s 后重试: %v", channel.label(), backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > notifyBackoffMax {
+			backoff = notifyBackoffMax
+		}
+	}
+}
+
+func (n *Notifier) send(channel *NotifyChannel, event AlertEvent) error {
+	switch channel.Type {
+	case notifyWebhook:
+		return n.sendWebhook(channel, event)
+	case notifySMTP:
+		return sendMail(channel, event)
+	case notifySlack:
+		return n.postJSON(channel, channel.URL, map[string]interface{}{
+			"text": notifyText(event),
+		})
+	case notifyDingTalk:
+		return n.sendDingTalk(channel, event)
+	case notifyFeishu:
+		return n.sendFeishu(channel, event)
+	case notifyTelegram:
+		endpoint := strings.TrimRight(channel.APIBase, "/") + "/bot" + channel.BotToken + "/sendMessage"
+		return n.postJSON(channel, endpoint, map[string]interface{}{
+			"chat_id": channel.ChatID,
+			"text":    notifyText(event),
+		})
+	}
+	return fmt.Errorf("不支持的type: %s", channel.Type)
+}
+
+var notifyTemplateFuncs = template.FuncMap{
+	// json 将值编码为JSON，用于在模板中安全嵌入字符串
+	"json": func(v interface{}) (string, error) {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(buf.String(), "\n"), nil
+	},
+}
+
+// sendWebhook 发送通用webhook，请求体按模板渲染，配置secret时附带HMAC-SHA256签名
+func (n *Notifier) sendWebhook(channel *NotifyChannel, event AlertEvent) error {
+	var body []byte
+	if channel.tmpl == nil {
+		b, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		body = b
+	} else {
+		var buf bytes.Buffer
+		if err := channel.tmpl.Execute(&buf, event); err != nil {
+			return fmt.Errorf("模板渲染失败: %v", err)
+		}
+		body = buf.Bytes()
+	}
+
+	req, err := http.NewRequest("POST", channel.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range channel.Headers {
+		req.Header.Set(k, v)
+	}
+	if channel.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(channel.Secret))
+		mac.Write(body)
+		req.Header.Set(notifySignatureKey, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	_, err = n.do(channel, req)
+	return err
+}
+
+// sendDingTalk 钉钉机器人，配置secret时按加签规则在URL中附带timestamp和sign
+func (n *Notifier) sendDingTalk(channel *NotifyChannel, event AlertEvent) error {
+	endpoint := channel.URL
+	if channel.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		mac := hmac.New(sha256.New, []byte(channel.Secret))
+		mac.Write([]byte(timestamp + "\n" + channel.Secret))
+		sign := url.QueryEscape(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+
+		sep := "?"
+		if strings.Contains(endpoint, "?") {
+			sep = "&"
+		}
+		endpoint += sep + "timestamp=" + timestamp + "&sign=" + sign
+	}
+	return n.postJSON(channel, endpoint, map[string]interface{}{
+		"msgtype": "text",
+		"text":    map[string]string{"content": notifyText(event)},
+	})
+}
+
+// sendFeishu 飞书机器人，配置secret时按加签规则在请求体中附带timestamp和sign
+func (n *Notifier) sendFeishu(channel *NotifyChannel, event AlertEvent) error {
+	payload := map[string]interface{}{
+		"msg_type": "text",
+		"content":  map[string]string{"text": notifyText(event)},
+	}
+	if channel.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(timestamp+"\n"+channel.Secret))
+		payload["timestamp"] = timestamp
+		payload["sign"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	return n.postJSON(channel, channel.URL, payload)
+}
+
+func (n *Notifier) postJSON(channel *NotifyChannel, endpoint string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	respBody, err := n.do(channel, req)
+	if err != nil {
+		return err
+	}
+
+	// 钉钉/飞书/Telegram 出错时可能仍返回200，需检查响应体中的错误码
+	var result struct {
+		ErrCode     *int    `json:"errcode"`
+		Code        *int    `json:"code"`
+		OK          *bool   `json:"ok"`
+		ErrMsg      string  `json:"errmsg"`
+		Msg         string  `json:"msg"`
+		Description *string `json:"description"`
+	}
+	if json.Unmarshal(respBody, &result) != nil {
+		return nil
+	}
+	switch {
+	case result.ErrCode != nil && *result.ErrCode != 0:
+		return fmt.Errorf("errcode=%d %s", *result.ErrCode, result.ErrMsg)
+	case result.Code != nil && *result.Code != 0:
+		return fmt.Errorf("code=%d %s", *result.Code, result.Msg)
+	case result.OK != nil && !*result.OK:
+		desc := ""
+		if result.Description != nil {
+			desc = *result.Description
+		}
+		return fmt.Errorf("ok=false %s", desc)
+	}
+	return nil
+}
+
+// do 发送请求，非2xx视为失败
+func (n *Notifier) do(channel *NotifyChannel, req *http.Request) ([]byte, error) {
+	client := *n.client
+	client.Timeout = time.Duration(channel.Timeout) * time.Second
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// sendMail 通过SMTP发送邮件，服务器支持时自动启用STARTTLS
+func sendMail(channel *NotifyChannel, event AlertEvent) error {
+	addr := fmt.Sprintf("%s:%d", channel.SMTPHost, channel.SMTPPort)
+	var auth smtp.Auth
+	if channel.Username != "" {
+		auth = smtp.PlainAuth("", channel.Username, channel.Password, channel.SMTPHost)
+	}
+
+	subject := "[ServerStatus] " + event.Message
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", channel.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(channel.To, ", "))
+	fmt.Fprintf(&msg, "Subject: =?UTF-8?B?%s?=\r\n", base64.StdEncoding.EncodeToString([]byte(subject)))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(notifyText(event), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	return smtp.SendMail(addr, auth, channel.From, channel.To, msg.Bytes())
+}
+
+// notifyText 聊天和邮件使用的纯文本消息
+func notifyText(event AlertEvent) string {
+	status := "告警触发"
+	switch event.Type {
+	case alertEventResolved:
+		status = "告警恢复"
+	case alertEventRemoved:
+		status = "服务器已清理"
+	}
+	lines := []string{
+		fmt.Sprintf("【%s】%s", status, event.Message),
+		"主机: " + event.Hostname,
+		"项目: " + event.ProjectKey,
+		fmt.Sprintf("指标: %s = %.2f", event.Metric, event.Value),
+		"时间: " + event.Timestamp.Format("2006-01-02 15:04:05"),
+	}
+	return strings.Join(lines, "\n")
+}
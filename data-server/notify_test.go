@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testAlertEvent() AlertEvent {
+	return AlertEvent{
+		Type:       alertEventFiring,
+		Rule:       "high-cpu",
+		Metric:     "cpu.usage_percent",
+		Severity:   "critical",
+		Value:      95.5,
+		Threshold:  90,
+		Hostname:   "web-01",
+		ProjectKey: "public",
+		Message:    `[critical] high-cpu 告警触发: web-01 "cpu" = 95.50`,
+		Timestamp:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// captureRequest 本地HTTP服务，记录收到的请求并返回固定响应
+func captureRequest(t *testing.T, response string) (*httptest.Server, <-chan *http.Request, <-chan []byte) {
+	t.Helper()
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests, bodies
+}
+
+func newTestChannel(t *testing.T, channel NotifyChannel) *NotifyChannel {
+	t.Helper()
+	if err := validateNotifyChannel(&channel); err != nil {
+		t.Fatalf("validateNotifyChannel: %v", err)
+	}
+	channel.Retries = 0
+	return &channel
+}
+
+func TestWebhookTemplateAndSignature(t *testing.T) {
+	srv, requests, bodies := captureRequest(t, "ok")
+	channel := newTestChannel(t, NotifyChannel{
+		Type:     notifyWebhook,
+		URL:      srv.URL,
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+		Template: `{"text": {{json .Message}}, "host": {{json .Hostname}}, "value": {{.Value}}}`,
+		Secret:   "sign-secret",
+	})
+
+	n := &Notifier{client: &http.Client{}}
+	if err := n.send(channel, testAlertEvent()); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	req, body := <-requests, <-bodies
+
+	want := `{"text": "[critical] high-cpu 告警触发: web-01 \"cpu\" = 95.50", "host": "web-01", "value": 95.5}`
+	if string(body) != want {
+		t.Errorf("body = %s\nwant  %s", body, want)
+	}
+	if !json.Valid(body) {
+		t.Errorf("rendered body is not valid JSON: %s", body)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("Authorization = %q", got)
+	}
+
+	mac := hmac.New(sha256.New, []byte("sign-secret"))
+	mac.Write(body)
+	wantSig := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := req.Header.Get(notifySignatureKey); got != wantSig {
+		t.Errorf("%s = %q, want %q", notifySignatureKey, got, wantSig)
+	}
+}
+
+func TestWebhookDefaultBody(t *testing.T) {
+	srv, requests, bodies := captureRequest(t, "")
+	channel := newTestChannel(t, NotifyChannel{Type: notifyWebhook, URL: srv.URL})
+
+	n := &Notifier{client: &http.Client{}}
+	if err := n.send(channel, testAlertEvent()); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	req, body := <-requests, <-bodies
+
+	var event AlertEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatalf("body is not an event: %v", err)
+	}
+	if event.Rule != "high-cpu" || event.Value != 95.5 {
+		t.Errorf("event = %+v", event)
+	}
+	if got := req.Header.Get(notifySignatureKey); got != "" {
+		t.Errorf("unexpected signature without secret: %q", got)
+	}
+}
+
+func TestWebhookInvalidTemplate(t *testing.T) {
+	channel := NotifyChannel{Type: notifyWebhook, URL: "http://127.0.0.1", Template: "{{.Message"}
+	if err := validateNotifyChannel(&channel); err == nil {
+		t.Fatal("expected template parse error at config load")
+	}
+}
+
+func TestDingTalkSign(t *testing.T) {
+	srv, requests, bodies := captureRequest(t, `{"errcode": 0, "errmsg": "ok"}`)
+	channel := newTestChannel(t, NotifyChannel{Type: notifyDingTalk, URL: srv.URL + "/robot/send?access_token=abc", Secret: "SECdemo"})
+
+	n := &Notifier{client: &http.Client{}}
+	if err := n.send(channel, testAlertEvent()); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	req, body := <-requests, <-bodies
+
+	query := req.URL.Query()
+	if query.Get("access_token") != "abc" {
+		t.Errorf("access_token lost: %s", req.URL.RawQuery)
+	}
+	timestamp := query.Get("timestamp")
+	mac := hmac.New(sha256.New, []byte("SECdemo"))
+	mac.Write([]byte(timestamp + "\n" + "SECdemo"))
+	if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); query.Get("sign") != want {
+		t.Errorf("sign = %q, want %q", query.Get("sign"), want)
+	}
+
+	var payload struct {
+		MsgType string `json:"msgtype"`
+		Text    struct {
+			Content string `json:"content"`
+		} `json:"text"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.MsgType != "text" || !strings.Contains(payload.Text.Content, "主机: web-01") {
+		t.Errorf("payload = %+v", payload)
+	}
+}
+
+func TestDingTalkErrorCode(t *testing.T) {
+	srv, _, _ := captureRequest(t, `{"errcode": 310000, "errmsg": "sign not match"}`)
+	channel := newTestChannel(t, NotifyChannel{Type: notifyDingTalk, URL: srv.URL})
+
+	n := &Notifier{client: &http.Client{}}
+	err := n.send(channel, testAlertEvent())
+	if err == nil || !strings.Contains(err.Error(), "310000") {
+		t.Fatalf("err = %v, want errcode 310000", err)
+	}
+}
+
+func TestFeishuSign(t *testing.T) {
+	srv, _, bodies := captureRequest(t, `{"code": 0, "msg": "success"}`)
+	channel := newTestChannel(t, NotifyChannel{Type: notifyFeishu, URL: srv.URL, Secret: "feishu-secret"})
+
+	n := &Notifier{client: &http.Client{}}
+	if err := n.send(channel, testAlertEvent()); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	body := <-bodies
+
+	var payload struct {
+		Timestamp string `json:"timestamp"`
+		Sign      string `json:"sign"`
+		MsgType   string `json:"msg_type"`
+		Content   struct {
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	// 飞书的签名以 timestamp+"\n"+secret 为密钥，对空内容计算HMAC
+	mac := hmac.New(sha256.New, []byte(payload.Timestamp+"\n"+"feishu-secret"))
+	if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); payload.Sign != want {
+		t.Errorf("sign = %q, want %q", payload.Sign, want)
+	}
+	if payload.MsgType != "text" || !strings.Contains(payload.Content.Text, "【告警触发】") {
+		t.Errorf("payload = %+v", payload)
+	}
+}
+
+// smtpSession 本地SMTP服务记录的一次投递
+type smtpSession struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// serveSMTP 在回环地址上接受一次连接，按最小的SMTP流程应答
+func serveSMTP(t *testing.T) (string, <-chan smtpSession) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	sessions := make(chan smtpSession, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		var session smtpSession
+		reply("220 localhost ESMTP test")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			cmd := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case strings.HasPrefix(cmd, "AUTH PLAIN"):
+				session.auth = strings.TrimSpace(line[len("AUTH PLAIN"):])
+				reply("235 2.7.0 Authentication successful")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				session.from = line[len("MAIL FROM:"):]
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				session.to = append(session.to, line[len("RCPT TO:"):])
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				session.data = data.String()
+				reply("250 OK queued")
+			case cmd == "QUIT":
+				reply("221 Bye")
+				sessions <- session
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return ln.Addr().String(), sessions
+}
+
+func TestSendMail(t *testing.T) {
+	addr, sessions := serveSMTP(t)
+	host, port, _ := net.SplitHostPort(addr)
+	portNum, _ := strconv.Atoi(port)
+	channel := newTestChannel(t, NotifyChannel{
+		Type:     notifySMTP,
+		SMTPHost: host,
+		SMTPPort: portNum,
+		Username: "alert",
+		Password: "pass",
+		From:     "alert@example.com",
+		To:       []string{"ops@example.com", "dev@example.com"},
+	})
+
+	n := &Notifier{client: &http.Client{}}
+	if err := n.send(channel, testAlertEvent()); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	session := <-sessions
+
+	if want := base64.StdEncoding.EncodeToString([]byte("\x00alert\x00pass")); session.auth != want {
+		t.Errorf("auth = %q, want %q", session.auth, want)
+	}
+	if session.from != "<alert@example.com>" {
+		t.Errorf("from = %q", session.from)
+	}
+	if len(session.to) != 2 || session.to[0] != "<ops@example.com>" || session.to[1] != "<dev@example.com>" {
+		t.Errorf("to = %q", session.to)
+	}
+
+	subject := base64.StdEncoding.EncodeToString([]byte("[ServerStatus] " + testAlertEvent().Message))
+	for _, want := range []string{
+		"To: ops@example.com, dev@example.com\r\n",
+		"Subject: =?UTF-8?B?" + subject + "?=\r\n",
+		"Content-Type: text/plain; charset=UTF-8\r\n",
+		"主机: web-01\r\n",
+		"指标: cpu.usage_percent = 95.50\r\n",
+	} {
+		if !strings.Contains(session.data, want) {
+			t.Errorf("message missing %q:\n%s", want, session.data)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ProbeCheck 下发给代理的检查，字段与代理配置中的checks一致
+type ProbeCheck struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`                    // ping / tcp / http / tls
+	Target       string `json:"target,omitempty"`        // 网格探测由服务器填写对端地址
+	Interval     int    `json:"interval,omitempty"`      // 检查间隔（秒）
+	Timeout      int    `json:"timeout,omitempty"`       // 超时（秒）
+	Count        int    `json:"count,omitempty"`         // ping发送的包数
+	Method       string `json:"method,omitempty"`        // HTTP方法
+	ExpectStatus []int  `json:"expect_status,omitempty"` // 期望的HTTP状态码
+	BodyMatch    string `json:"body_match,omitempty"`    // 响应内容需匹配的正则表达式
+	MinCertDays  int    `json:"min_cert_days,omitempty"` // 证书剩余天数下限
+	Insecure     bool   `json:"insecure,omitempty"`      // 不校验证书
+	Task         string `json:"task,omitempty"`          // 下发该检查的探测任务
+	Peer         string `json:"peer,omitempty"`          // 网格探测的对端服务器（sessionID，旧版代理为主机名）
+}
+
+// ProbeTask 服务器定义的探测任务，在上报和注册的响应中下发给匹配的代理
+type ProbeTask struct {
+	ProbeCheck
+	Projects []string `json:"projects,omitempty"` // 只下发给这些项目的代理，空为全部
+	Tags     []string `json:"tags,omitempty"`     // 只下发给带有其中任一标签的代理，空为全部
+	Mesh     bool     `json:"mesh,omitempty"`     // 网格探测：同一项目内每个代理ping其他所有在线代理
+}
+
+// ProbeResponse /api/data 的响应，probes始终存在，代理据此区分旧版服务器
+type ProbeResponse struct {
+	Probes []ProbeCheck `json:"probes"`
+}
+
+// probeTasks 校验后的探测任务
+var probeTasks []ProbeTask
+
+// setProbeTasks 校验探测任务，忽略无效和重名的任务
+func setProbeTasks(tasks []ProbeTask) {
+	var valid []ProbeTask
+	names := make(map[string]bool)
+	for _, task := range tasks {
+		if err := validateProbeTask(&task); err != nil {
+			log.Printf("[探测] 忽略无效任务 %q: %v", task.Name, err)
+			continue
+		}
+		if names[task.Name] {
+			log.Printf("[探测] 忽略重名任务 %q", task.Name)
+			continue
+		}
+		names[task.Name] = true
+		valid = append(valid, task)
+	}
+	probeTasks = valid
+}
+
+func validateProbeTask(task *ProbeTask) error {
+	if task.Name == "" {
+		return fmt.Errorf("缺少name")
+	}
+	if strings.Contains(task.Name, "/") {
+		return fmt.Errorf("name不能包含 /")
+	}
+	if task.Mesh {
+		if task.Type != "" && task.Type != "ping" {
+			return fmt.Errorf("网格探测只支持ping")
+		}
+		task.Type = "ping"
+		task.Target = ""
+		return nil
+	}
+	switch task.Type {
+	case "ping", "tcp", "http", "tls":
+	default:
+		return fmt.Errorf("未知的检查类型 %q", task.Type)
+	}
+	if task.Target == "" {
+		return fmt.Errorf("缺少target")
+	}
+	return nil
+}
+
+// appliesTo 判断任务是否下发给指定项目和标签的代理
+func (t *ProbeTask) appliesTo(projectKey string, tags []string) bool {
+	if len(t.Projects) > 0 && !containsString(t.Projects, projectKey) {
+		return false
+	}
+	if len(t.Tags) == 0 {
+		return true
+	}
+	for _, tag := range tags {
+		if containsString(t.Tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// probesFor 返回下发给某个代理的检查（调用方需持有data.mu读锁）
+// 网格任务为每个符合条件的在线对端生成一个ping检查，名称为 "任务名/对端主机名"
+func probesFor(serverKey, projectKey string, tags []string, now time.Time) []ProbeCheck {
+	probes := []ProbeCheck{}
+	for i := range probeTasks {
+		task := &probeTasks[i]
+		if !task.appliesTo(projectKey, tags) {
+			continue
+		}
+		if !task.Mesh {
+			check := task.ProbeCheck
+			check.Task = task.Name
+			probes = append(probes, check)
+			continue
+		}
+
+		keys := make([]string, 0, len(data.servers))
+		for key := range data.servers {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		names := make(map[string]bool)
+		for _, key := range keys {
+			peer := data.servers[key]
+			if key == serverKey || peer.Latest == nil || peer.Addr == "" || isOffline(peer, now) ||
+				peer.Latest.ProjectKey != projectKey || !task.appliesTo(projectKey, peer.Latest.Tags) {
+				continue
+			}
+			check := task.ProbeCheck
+			check.Name = task.Name + "/" + peer.Latest.Hostname
+			if names[check.Name] {
+				check.Name = task.Name + "/" + key
+			}
+			names[check.Name] = true
+			check.Target = peer.Addr
+			check.Task = task.Name
+			check.Peer = key
+			probes = append(probes, check)
+		}
+	}
+	return probes
+}
+
+// trustedProxies 可信反向代理的地址段，只有来自这些地址的请求才读取转发头
+var trustedProxies []netip.Prefix
+
+// setTrustedProxies 解析trusted_proxies，支持单个IP和CIDR，无效项跳过
+func setTrustedProxies(entries []string) {
+	var prefixes []netip.Prefix
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(entry)
+			if addrErr != nil {
+				log.Printf("[来源IP] 忽略无效的可信代理 %q", entry)
+				continue
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	trustedProxies = prefixes
+}
+
+func isTrustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP 代理的来源IP
+// 只有直连地址是可信反向代理时才使用转发头：从 X-Forwarded-For 右侧向左跳过可信代理，取第一个不可信的地址；
+// 没有 X-Forwarded-For 时使用 X-Real-IP。其他请求的转发头可以被客户端伪造，直接使用连接地址
+func clientIP(r *http.Request) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	if !isTrustedProxy(remote) {
+		return remote
+	}
+
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		client := ""
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				break
+			}
+			client = ip.String()
+			if !isTrustedProxy(client) {
+				break
+			}
+		}
+		if client != "" {
+			return client
+		}
+	}
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return remote
+}
+
+// LatencyMatrix 网格探测结果
+type LatencyMatrix struct {
+	Nodes   []MatrixNode  `json:"nodes"`
+	Entries []MatrixEntry `json:"entries"`
+}
+
+// MatrixNode 参与网格探测的服务器
+type MatrixNode struct {
+	ID         string `json:"id"` // sessionID，旧版代理为主机名
+	Hostname   string `json:"hostname"`
+	ProjectKey string `json:"project_key"`
+	Online     bool   `json:"online"`
+}
+
+// MatrixEntry 一个代理到另一个代理最近一次的探测结果
+type MatrixEntry struct {
+	Task       string    `json:"task"`
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	Success    bool      `json:"success"`
+	LatencyMs  float64   `json:"latency_ms"`
+	PacketLoss float64   `json:"packet_loss"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// hidePeerTargets 网格探测结果的目标是对端代理的来源IP，存储前清除，避免通过查询接口泄露
+func hidePeerTargets(info *SystemInfo) {
+	for i := range info.Checks {
+		if info.Checks[i].Peer != "" {
+			info.Checks[i].Target = ""
+		}
+	}
+}
+
+// buildLatencyMatrix 汇总各代理上报的网格探测结果（调用方需持有data.mu读锁）
+func buildLatencyMatrix(match func(projectKey string) bool, task string, now time.Time) LatencyMatrix {
+	matrix := LatencyMatrix{Nodes: []MatrixNode{}, Entries: []MatrixEntry{}}
+	for key, server := range data.servers {
+		if server.Latest == nil || !match(server.Latest.ProjectKey) {
+			continue
+		}
+		matrix.Nodes = append(matrix.Nodes, MatrixNode{
+			ID:         key,
+			Hostname:   server.Latest.Hostname,
+			ProjectKey: server.Latest.ProjectKey,
+			Online:     !isOffline(server, now),
+		})
+		for _, c := range server.Latest.Checks {
+			if c.Peer == "" || (task != "" && c.Task != task) {
+				continue
+			}
+			matrix.Entries = append(matrix.Entries, MatrixEntry{
+				Task:       c.Task,
+				From:       key,
+				To:         c.Peer,
+				Success:    c.Success,
+				LatencyMs:  c.LatencyMs,
+				PacketLoss: c.PacketLoss,
+				CheckedAt:  c.CheckedAt,
+			})
+		}
+	}
+
+	sort.Slice(matrix.Nodes, func(i, j int) bool {
+		a, b := matrix.Nodes[i], matrix.Nodes[j]
+		if a.Hostname != b.Hostname {
+			return a.Hostname < b.Hostname
+		}
+		return a.ID < b.ID
+	})
+	sort.Slice(matrix.Entries, func(i, j int) bool {
+		a, b := matrix.Entries[i], matrix.Entries[j]
+		if a.Task != b.Task {
+			return a.Task < b.Task
+		}
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+	return matrix
+}
+
+// handleGetLatencyMatrix 获取public项目的网格探测结果
+func handleGetLatencyMatrix(w http.ResponseWriter, r *http.Request) {
+	writeLatencyMatrix(w, r, isPublicProject)
+}
+
+// handleGetLatencyMatrixByAccessKey 根据访问密钥获取项目的网格探测结果
+func handleGetLatencyMatrixByAccessKey(w http.ResponseWriter, r *http.Request) {
+	accessKey := mux.Vars(r)["accessKey"]
+	if accessKey == "" {
+		http.Error(w, "无效的访问密钥", http.StatusUnauthorized)
+		return
+	}
+	writeLatencyMatrix(w, r, accessKeyMatcher(accessKey))
+}
+
+func writeLatencyMatrix(w http.ResponseWriter, r *http.Request, match func(projectKey string) bool) {
+	data.mu.RLock()
+	matrix := buildLatencyMatrix(match, r.URL.Query().Get("task"), time.Now())
+	data.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(matrix); err != nil {
+		log.Printf("Error encoding latency matrix response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
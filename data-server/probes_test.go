@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientIP(t *testing.T) {
+	saved := trustedProxies
+	t.Cleanup(func() { trustedProxies = saved })
+	setTrustedProxies([]string{"127.0.0.1", "10.0.0.0/8", "not-an-ip"})
+	if len(trustedProxies) != 2 {
+		t.Fatalf("trustedProxies = %v, want 2 valid entries", trustedProxies)
+	}
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded string
+		realIP    string
+		want      string
+	}{
+		{"direct", "203.0.113.7:5000", "", "", "203.0.113.7"},
+		{"spoofed header from untrusted client", "203.0.113.7:5000", "198.51.100.1", "198.51.100.2", "203.0.113.7"},
+		{"trusted proxy", "127.0.0.1:5000", "198.51.100.1", "", "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.2:5000", "198.51.100.1, 10.1.2.3", "", "198.51.100.1"},
+		{"client-supplied prefix ignored", "127.0.0.1:5000", "192.0.2.99, 198.51.100.1", "", "198.51.100.1"},
+		{"real ip from trusted proxy", "127.0.0.1:5000", "", "198.51.100.3", "198.51.100.3"},
+		{"invalid header from trusted proxy", "127.0.0.1:5000", "garbage", "", "127.0.0.1"},
+		{"ipv4-mapped remote", "[::ffff:127.0.0.1]:5000", "198.51.100.1", "", "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/data", nil)
+			r.RemoteAddr = tt.remote
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := clientIP(r); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIPWithoutTrustedProxies(t *testing.T) {
+	saved := trustedProxies
+	t.Cleanup(func() { trustedProxies = saved })
+	setTrustedProxies(nil)
+
+	r := httptest.NewRequest("POST", "/api/data", nil)
+	r.RemoteAddr = "127.0.0.1:5000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	if got := clientIP(r); got != "127.0.0.1" {
+		t.Errorf("clientIP = %q, want the connection address", got)
+	}
+}
+
+func TestAgentAddressNotExposed(t *testing.T) {
+	info := &SystemInfo{Hostname: "web-01", Checks: []CheckResult{
+		{Name: "api", Target: "https://example.com"},
+		{Name: "mesh:web-02", Task: "mesh", Peer: "web-02", Target: "198.51.100.2"},
+	}}
+	servers := make(map[string]*ServerInfo)
+	server := storeSample(servers, "web-01", info, time.Now())
+	server.Addr = "198.51.100.1"
+
+	body, err := json.Marshal(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range []string{"198.51.100.1", "198.51.100.2"} {
+		if strings.Contains(string(body), addr) {
+			t.Errorf("server JSON exposes %s: %s", addr, body)
+		}
+	}
+	if server.Latest.Checks[0].Target != "https://example.com" {
+		t.Errorf("check target cleared: %+v", server.Latest.Checks[0])
+	}
+}
+
+// meshServer 构造参与网格探测的服务器，ago为距最近一次上报的时长
+func meshServer(hostname, project, addr string, now time.Time, ago time.Duration, checks ...CheckResult) *ServerInfo {
+	return &ServerInfo{
+		Latest: &SystemInfo{Hostname: hostname, ProjectKey: project, Timestamp: now.Add(-ago), Checks: checks},
+		Addr:   addr,
+	}
+}
+
+func TestProbesFor(t *testing.T) {
+	saved := probeTasks
+	t.Cleanup(func() { probeTasks = saved })
+	setProbeTasks([]ProbeTask{
+		{ProbeCheck: ProbeCheck{Name: "api", Type: "http", Target: "https://api.example.com"}, Tags: []string{"edge"}},
+		{ProbeCheck: ProbeCheck{Name: "mesh", Interval: 60}, Mesh: true},
+		{ProbeCheck: ProbeCheck{Name: "bad", Type: "dns", Target: "example.com"}},
+	})
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	useTestGlobals(t, map[string]*ServerInfo{
+		"a1": meshServer("web-01", "public", "10.0.0.1", now, 0),
+		"a2": meshServer("web-02", "public", "10.0.0.2", now, 0),
+		"a3": meshServer("web-02", "public", "10.0.0.3", now, 0), // 主机名重复
+		"a4": meshServer("web-04", "public", "10.0.0.4", now, time.Hour),
+		"a5": meshServer("web-05", "public", "", now, 0), // 来源IP未知（重启后尚未上报）
+		"b1": meshServer("db-01", "other", "10.1.0.1", now, 0),
+		"b2": meshServer("db-02", "other", "10.1.0.2", now, 0),
+	})
+
+	type probe struct{ name, target, peer string }
+	tests := []struct {
+		name    string
+		key     string
+		project string
+		tags    []string
+		want    []probe
+	}{
+		{"tagged agent", "a1", "public", []string{"edge"}, []probe{
+			{"api", "https://api.example.com", ""},
+			{"mesh/web-02", "10.0.0.2", "a2"},
+			{"mesh/a3", "10.0.0.3", "a3"},
+		}},
+		{"duplicate hostname", "a2", "public", nil, []probe{
+			{"mesh/web-01", "10.0.0.1", "a1"},
+			{"mesh/web-02", "10.0.0.3", "a3"},
+		}},
+		{"separate project", "b1", "other", nil, []probe{
+			{"mesh/db-02", "10.1.0.2", "b2"},
+		}},
+		{"new agent", "c1", "third", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []probe
+			for _, p := range probesFor(tt.key, tt.project, tt.tags, now) {
+				if p.Task != strings.SplitN(p.Name, "/", 2)[0] {
+					t.Errorf("probe %q has task %q", p.Name, p.Task)
+				}
+				if p.Task == "mesh" && (p.Type != "ping" || p.Interval != 60) {
+					t.Errorf("mesh probe = %+v", p)
+				}
+				got = append(got, probe{p.Name, p.Target, p.Peer})
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("probes = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("probe %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBuildLatencyMatrix(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	useTestGlobals(t, map[string]*ServerInfo{
+		"a1": meshServer("web-01", "public", "10.0.0.1", now, 0,
+			CheckResult{Name: "mesh/web-02", Task: "mesh", Peer: "a2", Success: true, LatencyMs: 12},
+			CheckResult{Name: "edge/web-02", Task: "edge", Peer: "a2", Success: true, LatencyMs: 30},
+			CheckResult{Name: "api", Task: "api", Success: true}),
+		"a2": meshServer("web-02", "public", "10.0.0.2", now, time.Hour,
+			CheckResult{Name: "mesh/web-01", Task: "mesh", Peer: "a1", PacketLoss: 100}),
+		"b1": meshServer("db-01", "other", "10.1.0.1", now, 0,
+			CheckResult{Name: "mesh/db-02", Task: "mesh", Peer: "b2", Success: true}),
+	})
+	public := func(projectKey string) bool { return projectKey == "public" }
+
+	tests := []struct {
+		task    string
+		entries []string
+	}{
+		{"", []string{"edge a1->a2", "mesh a1->a2", "mesh a2->a1"}},
+		{"mesh", []string{"mesh a1->a2", "mesh a2->a1"}},
+		{"missing", nil},
+	}
+	for _, tt := range tests {
+		matrix := buildLatencyMatrix(public, tt.task, now)
+		if len(matrix.Nodes) != 2 || matrix.Nodes[0].ID != "a1" || !matrix.Nodes[0].Online || matrix.Nodes[1].Online {
+			t.Errorf("task %q: nodes = %+v", tt.task, matrix.Nodes)
+		}
+		var entries []string
+		for _, e := range matrix.Entries {
+			entries = append(entries, e.Task+" "+e.From+"->"+e.To)
+		}
+		if strings.Join(entries, ",") != strings.Join(tt.entries, ",") {
+			t.Errorf("task %q: entries = %v, want %v", tt.task, entries, tt.entries)
+		}
+	}
+
+	body, _ := json.Marshal(buildLatencyMatrix(public, "", now))
+	if strings.Contains(string(body), "10.0.0.") {
+		t.Errorf("matrix exposes agent addresses: %s", body)
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// 聚合层级
+const (
+	tierRaw    = "raw"
+	tierMinute = "1m"
+	tierHour   = "1h"
+)
+
+const (
+	defaultQueryPoints = 300   // 未指定step时的目标点数
+	maxQueryPoints     = 10000 // 单次查询最多返回的点数
+)
+
+// 未指定metrics时返回的默认指标
+var defaultQueryMetrics = []string{
+	"cpu.usage_percent",
+	"memory.usage_percent",
+	"disk.usage_percent",
+	"network.speed_sent",
+	"network.speed_recv",
+}
+
+// HistoryQueryResponse 历史查询响应（列式存储，timestamps与各series一一对应）
+type HistoryQueryResponse struct {
+	SessionID  string                `json:"session_id"`
+	Hostname   string                `json:"hostname"`
+	Tier       string                `json:"tier"`
+	From       time.Time             `json:"from"`
+	To         time.Time             `json:"to"`
+	Step       int64                 `json:"step"` // 秒
+	Agg        string                `json:"agg"`
+	Timestamps []int64               `json:"timestamps"` // Unix秒，时间桶起点
+	Series     map[string][]*float64 `json:"series"`     // 无数据的时间桶为null
+}
+
+// bucketAgg 单个时间桶内某指标的累计值
+type bucketAgg struct {
+	min, max, sum, last float64
+	count               int
+	lastTime            time.Time
+}
+
+func (b *bucketAgg) merge(min, max, sum, last float64, count int, ts time.Time) {
+	if count == 0 {
+		return
+	}
+	if b.count == 0 || min < b.min {
+		b.min = min
+	}
+	if b.count == 0 || max > b.max {
+		b.max = max
+	}
+	b.sum += sum
+	b.count += count
+	if !ts.Before(b.lastTime) {
+		b.last = last
+		b.lastTime = ts
+	}
+}
+
+func (b *bucketAgg) value(agg string) float64 {
+	switch agg {
+	case "min":
+		return b.min
+	case "max":
+		return b.max
+	case "sum":
+		return b.sum
+	case "count":
+		return float64(b.count)
+	case "last":
+		return b.last
+	default:
+		return b.sum / float64(b.count)
+	}
+}
+
+// handleQueryHistory 按时间范围和指标查询历史数据
+// GET /api/access/{accessKey}/history/{sessionID}?from=&to=&step=&metrics=&agg=&tier=
+func handleQueryHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	accessKey := vars["accessKey"]
+	sessionID := vars["sessionID"]
+
+	if accessKey == "" {
+		http.Error(w, "无效的访问密钥", http.StatusUnauthorized)
+		return
+	}
+
+	q := r.URL.Query()
+	now := time.Now()
+
+	to, err := parseQueryTime(q.Get("to"), now)
+	if err != nil {
+		http.Error(w, "无效的to参数", http.StatusBadRequest)
+		return
+	}
+	from, err := parseQueryTime(q.Get("from"), to.Add(-time.Hour))
+	if err != nil {
+		http.Error(w, "无效的from参数", http.StatusBadRequest)
+		return
+	}
+	if !from.Before(to) {
+		http.Error(w, "from必须早于to", http.StatusBadRequest)
+		return
+	}
+
+	step, err := parseQueryStep(q.Get("step"), to.Sub(from))
+	if err != nil {
+		http.Error(w, "无效的step参数", http.StatusBadRequest)
+		return
+	}
+	// 时间桶按step对齐，与聚合层的桶边界保持一致
+	from = from.Truncate(step)
+	buckets := int(math.Ceil(float64(to.Sub(from)) / float64(step)))
+	if buckets > maxQueryPoints {
+		http.Error(w, "查询点数过多，请增大step或缩小时间范围", http.StatusBadRequest)
+		return
+	}
+
+	agg := q.Get("agg")
+	if agg == "" {
+		agg = "avg"
+	}
+	switch agg {
+	case "avg", "min", "max", "sum", "count", "last":
+	default:
+		http.Error(w, "无效的agg参数，可选: avg/min/max/sum/count/last", http.StatusBadRequest)
+		return
+	}
+
+	metrics := parseQueryMetrics(q["metrics"])
+	if len(metrics) == 0 {
+		metrics = defaultQueryMetrics
+	}
+
+	data.mu.RLock()
+	defer data.mu.RUnlock()
+
+	server, exists := data.servers[sessionID]
+	if !exists {
+		http.Error(w, "服务器不存在", http.StatusNotFound)
+		return
+	}
+	if server.Latest == nil || !isServerMatchingAccessKey(server.Latest.ProjectKey, accessKey) {
+		http.Error(w, "服务器不属于指定访问密钥或无数据", http.StatusForbidden)
+		return
+	}
+
+	tier := q.Get("tier")
+	switch tier {
+	case "", "auto":
+		tier = selectTier(server, from, step)
+	case tierRaw, tierMinute, tierHour:
+	default:
+		http.Error(w, "无效的tier参数，可选: auto/raw/1m/1h", http.StatusBadRequest)
+		return
+	}
+
+	// 按时间桶累计各指标
+	acc := make([]map[string]*bucketAgg, buckets)
+	bucketOf := func(ts time.Time) int {
+		if ts.Before(from) || !ts.Before(to) {
+			return -1
+		}
+		return int(ts.Sub(from) / step)
+	}
+	slot := func(i int, metric string) *bucketAgg {
+		if acc[i] == nil {
+			acc[i] = make(map[string]*bucketAgg, len(metrics))
+		}
+		b := acc[i][metric]
+		if b == nil {
+			b = &bucketAgg{}
+			acc[i][metric] = b
+		}
+		return b
+	}
+
+	if tier == tierRaw {
+		for _, item := range server.History {
+			i := bucketOf(item.Timestamp)
+			if i < 0 || !isServerMatchingAccessKey(item.ProjectKey, accessKey) {
+				continue
+			}
+			values := flattenMetrics(item)
+			for _, metric := range metrics {
+				if v, ok := values[metric]; ok {
+					slot(i, metric).merge(v, v, v, v, 1, item.Timestamp)
+				}
+			}
+		}
+	} else {
+		points := server.Rollup1m
+		if tier == tierHour {
+			points = server.Rollup1h
+		}
+		for _, point := range points {
+			i := bucketOf(point.Timestamp)
+			if i < 0 {
+				continue
+			}
+			for _, metric := range metrics {
+				if a, ok := point.Metrics[metric]; ok {
+					last, lastAt := a.Last, a.LastAt
+					if lastAt.IsZero() {
+						// 旧版本持久化的聚合没有记录最后的值
+						last, lastAt = a.Avg(), point.Timestamp
+					}
+					slot(i, metric).merge(a.Min, a.Max, a.Sum, last, a.Count, lastAt)
+				}
+			}
+		}
+	}
+
+	response := HistoryQueryResponse{
+		SessionID:  sessionID,
+		Hostname:   server.Latest.Hostname,
+		Tier:       tier,
+		From:       from,
+		To:         to,
+		Step:       int64(step / time.Second),
+		Agg:        agg,
+		Timestamps: make([]int64, buckets),
+		Series:     make(map[string][]*float64, len(metrics)),
+	}
+	for _, metric := range metrics {
+		response.Series[metric] = make([]*float64, buckets)
+	}
+	for i := 0; i < buckets; i++ {
+		response.Timestamps[i] = from.Add(time.Duration(i) * step).Unix()
+		for metric, b := range acc[i] {
+			v := b.value(agg)
+			response.Series[metric][i] = &v
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding history response: %v", err)
+	}
+}
+
+// selectTier 选择能覆盖查询起点的最细聚合层；step 已超过下一层粒度时直接使用更粗的层。
+// 没有任何层覆盖起点时（如新上线的服务器），选择保留了最早数据的最细层
+func selectTier(server *ServerInfo, from time.Time, step time.Duration) string {
+	type candidate struct {
+		name       string
+		resolution time.Duration
+		start      time.Time
+		hasData    bool
+	}
+	candidates := []candidate{{name: tierRaw}, {name: tierMinute, resolution: time.Minute}, {name: tierHour, resolution: time.Hour}}
+	if len(server.History) > 0 {
+		candidates[0].start, candidates[0].hasData = server.History[0].Timestamp, true
+	}
+	if len(server.Rollup1m) > 0 {
+		candidates[1].start, candidates[1].hasData = server.Rollup1m[0].Timestamp, true
+	}
+	if len(server.Rollup1h) > 0 {
+		candidates[2].start, candidates[2].hasData = server.Rollup1h[0].Timestamp, true
+	}
+
+	// 下一层粒度不大于step时跳过当前层
+	eligible := candidates[:0]
+	for i, c := range candidates {
+		if i+1 < len(candidates) && step >= candidates[i+1].resolution {
+			continue
+		}
+		if c.hasData {
+			eligible = append(eligible, c)
+		}
+	}
+	if len(eligible) == 0 {
+		return tierHour
+	}
+
+	// 首个时间桶在from之前结束，才能确定该层保留了from之前的数据
+	for _, c := range eligible {
+		if !c.start.Add(c.resolution).After(from) {
+			return c.name
+		}
+	}
+
+	// 聚合层的起点被截断到桶边界，真实数据起点不晚于 start+resolution
+	bound := eligible[0].start.Add(eligible[0].resolution)
+	for _, c := range eligible[1:] {
+		if b := c.start.Add(c.resolution); b.Before(bound) {
+			bound = b
+		}
+	}
+	for _, c := range eligible {
+		if !c.start.After(bound) {
+			return c.name
+		}
+	}
+	return eligible[len(eligible)-1].name
+}
+
+// parseQueryTime 解析时间参数，支持RFC3339和Unix秒
+func parseQueryTime(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parseQueryStep 解析step参数，支持Go时长格式(1m, 30s)和秒数；未指定时按目标点数自动计算
+func parseQueryStep(value string, span time.Duration) (time.Duration, error) {
+	var step time.Duration
+	if value == "" {
+		step = (span / defaultQueryPoints).Truncate(time.Second)
+	} else if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+		step = time.Duration(sec) * time.Second
+	} else {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, err
+		}
+		step = d
+	}
+	if step < time.Second {
+		step = time.Second
+	}
+	return step, nil
+}
+
+// parseQueryMetrics 解析metrics参数，支持逗号分隔和重复参数，[] 和 {} 内的逗号不作为分隔符
+func parseQueryMetrics(values []string) []string {
+	var metrics []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		for _, metric := range splitMetricList(value) {
+			metric = strings.TrimSpace(metric)
+			if metric == "" || seen[metric] {
+				continue
+			}
+			seen[metric] = true
+			metrics = append(metrics, metric)
+		}
+	}
+	return metrics
+}
+
+func splitMetricList(value string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range value {
+		switch r {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, value[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, value[start:])
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AggValue 单个指标在一个时间桶内的 min/avg/max/last 聚合
+type AggValue struct {
+	Min    float64   `json:"min"`
+	Max    float64   `json:"max"`
+	Sum    float64   `json:"sum"`
+	Count  int       `json:"count"`
+	Last   float64   `json:"last"`    // 桶内时间最晚的样本值
+	LastAt time.Time `json:"last_at"` // 该样本的时间，乱序补传的样本不覆盖更晚的值
+}
+
+// Avg 返回平均值
+func (a *AggValue) Avg() float64 {
+	if a.Count == 0 {
+		return 0
+	}
+	return a.Sum / float64(a.Count)
+}
+
+func (a *AggValue) add(v float64, ts time.Time) {
+	if a.Count == 0 || v < a.Min {
+		a.Min = v
+	}
+	if a.Count == 0 || v > a.Max {
+		a.Max = v
+	}
+	a.Sum += v
+	a.Count++
+	if !ts.Before(a.LastAt) {
+		a.Last = v
+		a.LastAt = ts
+	}
+}
+
+// RollupPoint 一个时间桶的聚合数据
+type RollupPoint struct {
+	Timestamp time.Time            `json:"timestamp"` // 时间桶起点
+	Count     int                  `json:"count"`     // 桶内原始样本数
+	Metrics   map[string]*AggValue `json:"metrics"`
+}
+
+// sampleTime 返回样本的时间戳，代理未带时间戳时使用接收时间
+func sampleTime(info *SystemInfo, seen time.Time) time.Time {
+	if info.Timestamp.IsZero() {
+		return seen
+	}
+	return info.Timestamp
+}
+
+// rollupSample 将样本合并进 1分钟/1小时 聚合层，并按各层保留时长裁剪
+func rollupSample(server *ServerInfo, info *SystemInfo, ts time.Time) {
+	metrics := flattenMetrics(info)
+	for name := range metrics {
+		if strings.HasPrefix(name, "custom[") && !rollupCustomAllowed(name) {
+			delete(metrics, name)
+		}
+	}
+
+	server.Rollup1m = addToTier(server.Rollup1m, ts.Truncate(time.Minute), ts, metrics)
+	server.Rollup1h = addToTier(server.Rollup1h, ts.Truncate(time.Hour), ts, metrics)
+
+	if retention := time.Duration(serverConfig.Rollup1mRetention) * time.Hour; retention > 0 {
+		server.Rollup1m = trimTier(server.Rollup1m, ts.Add(-retention))
+	}
+	if retention := time.Duration(serverConfig.Rollup1hRetention) * 24 * time.Hour; retention > 0 {
+		server.Rollup1h = trimTier(server.Rollup1h, ts.Add(-retention))
+	}
+}
+
+// rollupCustomAllowed 自定义指标是否写入聚合层
+// 每个序列在两个聚合层各占一份，插件和StatsD的序列数可达上千，因此只聚合 rollup_custom 中列出的；
+// 按不带标签的名称匹配，以 * 结尾的条目按前缀匹配，如 custom[statsd].api.*
+func rollupCustomAllowed(series string) bool {
+	name, _, _ := strings.Cut(series, "{")
+	for _, pattern := range serverConfig.RollupCustom {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// addToTier 将指标并入对应时间桶，桶按时间升序排列（乱序样本插入到正确位置）
+func addToTier(points []*RollupPoint, bucket, ts time.Time, metrics map[string]float64) []*RollupPoint {
+	idx := sort.Search(len(points), func(i int) bool {
+		return !points[i].Timestamp.Before(bucket)
+	})
+
+	var point *RollupPoint
+	if idx < len(points) && points[idx].Timestamp.Equal(bucket) {
+		point = points[idx]
+	} else {
+		point = &RollupPoint{
+			Timestamp: bucket,
+			Metrics:   make(map[string]*AggValue, len(metrics)),
+		}
+		points = append(points, nil)
+		copy(points[idx+1:], points[idx:])
+		points[idx] = point
+	}
+
+	point.Count++
+	for name, value := range metrics {
+		agg := point.Metrics[name]
+		if agg == nil {
+			agg = &AggValue{}
+			point.Metrics[name] = agg
+		}
+		agg.add(value, ts)
+	}
+	return points
+}
+
+// trimTier 丢弃早于 cutoff 的时间桶
+func trimTier(points []*RollupPoint, cutoff time.Time) []*RollupPoint {
+	idx := sort.Search(len(points), func(i int) bool {
+		return !points[i].Timestamp.Before(cutoff)
+	})
+	if idx == 0 {
+		return points
+	}
+	return append(points[:0:0], points[idx:]...)
+}
+
+// insertHistory 按时间顺序插入原始数据，常规上报直接追加
+func insertHistory(history []*SystemInfo, info *SystemInfo, ts time.Time) []*SystemInfo {
+	if len(history) == 0 || !ts.Before(sampleTime(history[len(history)-1], ts)) {
+		return append(history, info)
+	}
+	idx := sort.Search(len(history), func(i int) bool {
+		return sampleTime(history[i], ts).After(ts)
+	})
+	history = append(history, nil)
+	copy(history[idx+1:], history[idx:])
+	history[idx] = info
+	return history
+}
+
+// trimRawHistory 按原始数据保留时长裁剪历史记录
+func trimRawHistory(history []*SystemInfo, cutoff time.Time) []*SystemInfo {
+	idx := 0
+	for idx < len(history) && sampleTime(history[idx], cutoff).Before(cutoff) {
+		idx++
+	}
+	if idx == 0 {
+		return history
+	}
+	return history[idx:]
+}
+
+// flattenMetrics 将样本展开为 "路径 -> 数值" 形式，作为聚合和查询的统一指标命名
+func flattenMetrics(info *SystemInfo) map[string]float64 {
+	m := map[string]float64{
+		"cpu.usage_percent":    info.CPU.UsagePercent,
+		"memory.usage_percent": info.MemoryPercent(),
+		"memory.used":          float64(info.Memory.Used),
+		"disk.usage_percent":   info.Disk.UsagePercent,
+		"disk.used":            float64(info.Disk.Used),
+		"network.speed_sent":   info.Network.SpeedSent,
+		"network.speed_recv":   info.Network.SpeedRecv,
+		"network.bytes_sent":   float64(info.Network.BytesSent),
+		"network.bytes_recv":   float64(info.Network.BytesRecv),
+		"temperature.cpu_temp": info.Temperature.CPUTemp,
+		"temperature.gpu_temp": info.Temperature.GPUTemp,
+		"temperature.max_temp": info.Temperature.MaxTemp,
+		"temperature.avg_temp": info.Temperature.AvgTemp,
+	}
+
+	// 旧版代理没有CPU细分数据，不写入全为0的指标
+	if c := info.CPU; c.UserPercent+c.SystemPercent+c.IdlePercent > 0 {
+		m["cpu.user_percent"] = c.UserPercent
+		m["cpu.system_percent"] = c.SystemPercent
+		m["cpu.nice_percent"] = c.NicePercent
+		m["cpu.iowait_percent"] = c.IOWaitPercent
+		m["cpu.irq_percent"] = c.IRQPercent
+		m["cpu.softirq_percent"] = c.SoftIRQPercent
+		m["cpu.steal_percent"] = c.StealPercent
+		m["cpu.guest_percent"] = c.GuestPercent
+		m["cpu.load1"] = c.Load1
+		m["cpu.load5"] = c.Load5
+		m["cpu.load15"] = c.Load15
+		m["cpu.ctx_switch_rate"] = c.CtxSwitchRate
+		m["cpu.interrupt_rate"] = c.InterruptRate
+	}
+	if mem := info.Memory; mem.Available > 0 {
+		m["memory.available"] = float64(mem.Available)
+		m["memory.cached"] = float64(mem.Cached)
+		m["memory.buffers"] = float64(mem.Buffers)
+	}
+	if mem := info.Memory; mem.SwapTotal > 0 {
+		m["memory.swap_used"] = float64(mem.SwapUsed)
+		m["memory.swap_percent"] = mem.SwapPercent
+		m["memory.swap_in_speed"] = mem.SwapInSpeed
+		m["memory.swap_out_speed"] = mem.SwapOutSpeed
+	}
+	if info.Processes != nil {
+		m["processes.total"] = float64(info.Processes.Total)
+	}
+	for _, svc := range info.Services {
+		running := 0.0
+		if !isServiceDown(svc) {
+			running = 1
+		}
+		m[serviceMetric(svc.Name)] = running
+		m[fmt.Sprintf("services[%s].count", svc.Name)] = float64(svc.Count)
+		m[fmt.Sprintf("services[%s].restarts", svc.Name)] = float64(svc.Restarts)
+	}
+	for _, c := range info.Checks {
+		prefix := fmt.Sprintf("checks[%s].", c.Name)
+		success := 0.0
+		if c.Success {
+			success = 1
+		}
+		m[checkMetric(c.Name)] = success
+		m[prefix+"latency_ms"] = c.LatencyMs
+		if c.Type == "ping" {
+			m[prefix+"packet_loss"] = c.PacketLoss
+		}
+		if c.CertExpiry != nil {
+			m[prefix+"cert_days_left"] = c.CertDaysLeft
+		}
+	}
+	for _, plugin := range info.Custom {
+		for _, metric := range plugin.Metrics {
+			m[customMetricKey(plugin.Name, metric)] = metric.Value
+		}
+	}
+	for _, l := range info.Logs {
+		for _, pt := range l.Patterns {
+			prefix := fmt.Sprintf("logs[%s].%s.", l.Name, pt.Name)
+			m[prefix+"count"] = float64(pt.Count)
+			m[prefix+"rate"] = pt.Rate
+		}
+	}
+	for _, c := range info.Containers {
+		prefix := fmt.Sprintf("containers[%s].", c.Name)
+		m[prefix+"cpu_percent"] = c.CPUPercent
+		m[prefix+"memory_usage"] = float64(c.MemoryUsage)
+		m[prefix+"memory_percent"] = c.MemoryPercent
+		m[prefix+"net_rx_speed"] = c.NetRxSpeed
+		m[prefix+"net_tx_speed"] = c.NetTxSpeed
+		m[prefix+"block_read_speed"] = c.BlockReadSpeed
+		m[prefix+"block_write_speed"] = c.BlockWriteSpeed
+		m[prefix+"restart_count"] = float64(c.RestartCount)
+	}
+	if len(info.Containers) > 0 {
+		running, restarts := containerSummary(info)
+		m["containers.running"] = float64(running)
+		m["containers.restarts"] = float64(restarts)
+	}
+	if cg := info.Cgroup; cg != nil {
+		m["cgroup.cpu_usage_percent"] = cg.CPUUsagePercent
+		m["cgroup.throttled_percent"] = cg.ThrottledPercent
+		m["cgroup.memory_usage"] = float64(cg.MemoryUsage)
+		m["cgroup.memory_percent"] = cg.MemoryPercent
+		m["cgroup.oom_kills"] = float64(cg.OOMKills)
+		for name, psi := range map[string]*PressureStat{"cpu": cg.CPUPressure, "memory": cg.MemoryPressure, "io": cg.IOPressure} {
+			if psi != nil {
+				m["cgroup."+name+"_pressure.some_avg10"] = psi.SomeAvg10
+				m["cgroup."+name+"_pressure.full_avg10"] = psi.FullAvg10
+			}
+		}
+	}
+	for i, usage := range info.CPU.PerCore {
+		m[fmt.Sprintf("cpu.per_core[%d]", i)] = usage
+	}
+
+	for _, iface := range info.Network.Interfaces {
+		prefix := fmt.Sprintf("network.interfaces[%s].", iface.Name)
+		m[prefix+"speed_sent"] = iface.SpeedSent
+		m[prefix+"speed_recv"] = iface.SpeedRecv
+		m[prefix+"bytes_sent"] = float64(iface.BytesSent)
+		m[prefix+"bytes_recv"] = float64(iface.BytesRecv)
+		if iface.Kind != "" {
+			// 旧版代理固定上报is_up为true，没有类型的网卡不写入
+			up := 0.0
+			if iface.IsUp {
+				up = 1
+			}
+			m[prefix+"up"] = up
+			m[prefix+"err_in_rate"] = iface.ErrInRate
+			m[prefix+"err_out_rate"] = iface.ErrOutRate
+			m[prefix+"drop_in_rate"] = iface.DropInRate
+			m[prefix+"drop_out_rate"] = iface.DropOutRate
+		}
+	}
+	if sk := info.Network.Sockets; sk != nil {
+		m["network.sockets.tcp"] = float64(sk.TCP)
+		m["network.sockets.established"] = float64(sk.Established)
+		m["network.sockets.syn_recv"] = float64(sk.SynRecv)
+		m["network.sockets.time_wait"] = float64(sk.TimeWait)
+		m["network.sockets.close_wait"] = float64(sk.CloseWait)
+		m["network.sockets.udp"] = float64(sk.UDP)
+	}
+	if ct := info.Network.Conntrack; ct != nil {
+		m["network.conntrack.count"] = float64(ct.Count)
+		m["network.conntrack.percent"] = ct.Percent
+	}
+
+	for _, d := range info.Disks {
+		prefix := fmt.Sprintf("disks[%s].", d.Mountpoint)
+		m[prefix+"usage_percent"] = d.UsagePercent
+		m[prefix+"used"] = float64(d.Used)
+		m[prefix+"inodes_usage_percent"] = d.InodesUsagePercent
+	}
+	if len(info.Disks) > 0 {
+		percent, _, inodePercent := diskSummary(info)
+		m["disk.max_usage_percent"] = percent
+		m["disk.max_inodes_usage_percent"] = inodePercent
+	}
+
+	for _, d := range info.DiskIO {
+		prefix := fmt.Sprintf("disk_io[%s].", d.Name)
+		m[prefix+"read_speed"] = d.ReadSpeed
+		m[prefix+"write_speed"] = d.WriteSpeed
+		m[prefix+"read_iops"] = d.ReadIOPS
+		m[prefix+"write_iops"] = d.WriteIOPS
+		m[prefix+"await"] = d.Await
+		m[prefix+"util_percent"] = d.UtilPercent
+	}
+	if len(info.DiskIO) > 0 {
+		sum := diskIOSummary(info)
+		m["disk_io.read_speed"] = sum.ReadSpeed
+		m["disk_io.write_speed"] = sum.WriteSpeed
+		m["disk_io.read_iops"] = sum.ReadIOPS
+		m["disk_io.write_iops"] = sum.WriteIOPS
+		m["disk_io.max_await"] = sum.Await
+		m["disk_io.max_util_percent"] = sum.UtilPercent
+	}
+
+	for i, gpu := range info.GPUs {
+		prefix := fmt.Sprintf("gpus[%d].", i)
+		m[prefix+"usage_percent"] = gpu.UsagePercent
+		m[prefix+"temperature"] = gpu.Temperature
+		m[prefix+"memory_used"] = float64(gpu.MemoryUsed)
+	}
+
+	return m
+}
+
+// customMetricKey 插件指标的路径，标签按名称排序，如 custom[app].queue_depth、custom[app].requests{code="200",method="GET"}
+func customMetricKey(plugin string, metric CustomMetric) string {
+	key := fmt.Sprintf("custom[%s].%s", plugin, metric.Name)
+	if len(metric.Labels) == 0 {
+		return key
+	}
+	names := make([]string, 0, len(metric.Labels))
+	for name := range metric.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	labels := make([]string, len(names))
+	for i, name := range names {
+		labels[i] = fmt.Sprintf("%s=%q", name, metric.Labels[name])
+	}
+	return key + "{" + strings.Join(labels, ",") + "}"
+}
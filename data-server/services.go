@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	serviceDownRule      = "service_down"
+	serviceRestartedRule = "service_restarted"
+)
+
+// isServiceDown 进程全部退出、数量不足或systemd服务失败
+func isServiceDown(s ServiceStatus) bool {
+	return !s.Running || s.Failed
+}
+
+// serviceMetric 服务对应的指标路径，同时作为告警状态的key
+func serviceMetric(name string) string {
+	return fmt.Sprintf("services[%s].running", name)
+}
+
+// servicesDown 返回当前停止的服务名
+func servicesDown(info *SystemInfo) []string {
+	var names []string
+	for _, s := range info.Services {
+		if isServiceDown(s) {
+			names = append(names, s.Name)
+		}
+	}
+	return names
+}
+
+// evaluateServices 比较服务状态，停止时发出service_down事件，恢复时发出恢复事件
+// 两次上报之间发生的重启（重启次数增加但仍在运行）发出service_restarted事件
+func (m *AlertManager) evaluateServices(key string, prev, info *SystemInfo, ts time.Time) {
+	var events []AlertEvent
+
+	m.mu.Lock()
+	states := m.states[key]
+	current := make(map[string]bool)
+	for _, s := range info.Services {
+		metric := serviceMetric(s.Name)
+		current[metric] = true
+		state := states[metric]
+
+		if !isServiceDown(s) {
+			if state != nil {
+				delete(states, metric)
+				state.Value = 1
+				event := newAlertEvent(alertEventResolved, state, ts)
+				event.Message = fmt.Sprintf("[critical] %s 服务恢复: %s %s，停止 %s",
+					serviceDownRule, info.Hostname, s.Name, ts.Sub(state.FiredAt).Truncate(time.Second))
+				events = append(events, event)
+			} else if old, ok := findService(prev, s.Name); ok && s.Restarts > old.Restarts {
+				state := serviceState(serviceRestartedRule, "warning", info, s, ts)
+				state.Value = float64(s.Restarts)
+				event := newAlertEvent(alertEventFiring, state, ts)
+				event.Message = fmt.Sprintf("[warning] %s 服务重启: %s %s 重启次数 %d -> %d",
+					serviceRestartedRule, info.Hostname, s.Name, old.Restarts, s.Restarts)
+				events = append(events, event)
+			}
+			continue
+		}
+
+		if state != nil {
+			continue
+		}
+		state = serviceState(serviceDownRule, "critical", info, s, ts)
+		if states == nil {
+			states = make(map[string]*AlertState)
+			m.states[key] = states
+		}
+		states[metric] = state
+		event := newAlertEvent(alertEventFiring, state, ts)
+		event.Message = fmt.Sprintf("[critical] %s 服务停止: %s %s (%s)",
+			serviceDownRule, info.Hostname, s.Name, describeService(s))
+		events = append(events, event)
+	}
+
+	// 从检查列表中移除的服务不再保留告警
+	for metric, state := range states {
+		if state.Rule == serviceDownRule && !current[metric] {
+			delete(states, metric)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, event := range events {
+		m.emit(event)
+	}
+}
+
+func serviceState(rule, severity string, info *SystemInfo, s ServiceStatus, ts time.Time) *AlertState {
+	return &AlertState{
+		Rule:       rule,
+		Metric:     serviceMetric(s.Name),
+		Severity:   severity,
+		State:      alertStateFiring,
+		Hostname:   info.Hostname,
+		SessionID:  info.SessionID,
+		ProjectKey: info.ProjectKey,
+		Since:      ts,
+		FiredAt:    ts,
+	}
+}
+
+func findService(info *SystemInfo, name string) (ServiceStatus, bool) {
+	if info != nil {
+		for _, s := range info.Services {
+			if s.Name == name {
+				return s, true
+			}
+		}
+	}
+	return ServiceStatus{}, false
+}
+
+func describeService(s ServiceStatus) string {
+	if s.Type == "systemd" {
+		return s.State
+	}
+	return fmt.Sprintf("%d 个进程", s.Count)
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Storage 历史数据持久化接口
+// 内存中的 data.servers 仍是读取路径，Storage 负责写穿透落盘并在启动时恢复
+type Storage interface {
+	// Load 读取已持久化的全部服务器数据
+	Load() (map[string]*ServerInfo, error)
+	// Append 追加一条上报记录
+	Append(key string, info *SystemInfo, seen time.Time) error
+	// Delete 删除服务器及其全部历史
+	Delete(key string) error
+	// Compact 用内存快照重写存储，丢弃已被裁剪的记录
+	// 调用方在持有 data.mu 时调用，返回的函数在释放锁后执行写盘，期间追加的记录会补写到新日志中
+	Compact(snapshot map[string]*ServerInfo) func() error
+	Close() error
+}
+
+// newStorage 根据配置创建存储后端
+func newStorage(cfg ServerConfig) (Storage, error) {
+	switch cfg.Storage {
+	case "memory":
+		return memoryStorage{}, nil
+	case "", "file":
+		return newFileStorage(cfg.DataDir)
+	default:
+		return nil, fmt.Errorf("未知的存储类型: %s", cfg.Storage)
+	}
+}
+
+// memoryStorage 纯内存模式，重启后数据丢失（旧版行为）
+type memoryStorage struct{}
+
+func (memoryStorage) Load() (map[string]*ServerInfo, error) {
+	return make(map[string]*ServerInfo), nil
+}
+func (memoryStorage) Append(string, *SystemInfo, time.Time) error { return nil }
+func (memoryStorage) Delete(string) error                         { return nil }
+func (memoryStorage) Compact(map[string]*ServerInfo) func() error {
+	return func() error { return nil }
+}
+func (memoryStorage) Close() error { return nil }
+
+// 日志记录操作类型
+const (
+	opAppend   = "append"
+	opDelete   = "delete"
+	opSnapshot = "snapshot"
+)
+
+// storageRecord 追加日志中的一行
+type storageRecord struct {
+	Op     string      `json:"op"`
+	Key    string      `json:"key"`
+	Seen   time.Time   `json:"seen,omitempty"`
+	Info   *SystemInfo `json:"info,omitempty"`
+	Server *ServerInfo `json:"server,omitempty"`
+
+	// 聚合数据不随 ServerInfo 序列化，快照记录单独保存
+	Rollup1m []*RollupPoint `json:"rollup_1m,omitempty"`
+	Rollup1h []*RollupPoint `json:"rollup_1h,omitempty"`
+}
+
+// fileStorage 嵌入式磁盘存储：追加写日志 + 定期压缩
+type fileStorage struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	pending [][]byte // 压缩期间追加的记录，非nil表示正在压缩
+}
+
+func newFileStorage(dir string) (*fileStorage, error) {
+	if dir == "" {
+		dir = "data"
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建数据目录失败: %v", err)
+	}
+	s := &fileStorage{path: filepath.Join(dir, "history.log")}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileStorage) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("打开数据文件失败: %v", err)
+	}
+	// 上次崩溃时最后一行可能只写了一半，先补上换行，避免下一条记录拼接在后面一起损坏
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			if _, err := f.Write([]byte{'\n'}); err != nil {
+				f.Close()
+				return fmt.Errorf("修复数据文件失败: %v", err)
+			}
+		}
+	}
+	s.file = f
+	return nil
+}
+
+// Load 顺序回放日志，重建内存数据
+func (s *fileStorage) Load() (map[string]*ServerInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	servers := make(map[string]*ServerInfo)
+
+	f, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return servers, nil
+		}
+		return nil, fmt.Errorf("读取数据文件失败: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	lineNo, skipped := 0, 0
+	for scanner.Scan() {
+		lineNo++
+		var rec storageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// 进程崩溃时最后一行可能写了一半，跳过即可
+			skipped++
+			continue
+		}
+		switch rec.Op {
+		case opAppend:
+			if rec.Info != nil {
+				storeSample(servers, rec.Key, rec.Info, rec.Seen)
+			}
+		case opDelete:
+			delete(servers, rec.Key)
+		case opSnapshot:
+			if rec.Server != nil {
+				rec.Server.Rollup1m = rec.Rollup1m
+				rec.Server.Rollup1h = rec.Rollup1h
+				// 旧版本快照中在线服务器的down_since为零值
+				if rec.Server.DownSince != nil && rec.Server.DownSince.IsZero() {
+					rec.Server.DownSince = nil
+				}
+				servers[rec.Key] = rec.Server
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取数据文件失败 (第%d行): %v", lineNo, err)
+	}
+	if skipped > 0 {
+		log.Printf("[存储] 跳过 %d 条损坏的记录", skipped)
+	}
+	return servers, nil
+}
+
+func (s *fileStorage) Append(key string, info *SystemInfo, seen time.Time) error {
+	return s.write(storageRecord{Op: opAppend, Key: key, Seen: seen, Info: info})
+}
+
+func (s *fileStorage) Delete(key string) error {
+	return s.write(storageRecord{Op: opDelete, Key: key})
+}
+
+func (s *fileStorage) write(rec storageRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("序列化记录失败: %v", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return fmt.Errorf("数据文件已关闭")
+	}
+	if s.pending != nil {
+		s.pending = append(s.pending, line)
+	}
+	_, err = s.file.Write(line)
+	return err
+}
+
+// Compact 记录快照点，返回的函数将快照和之后追加的记录写入临时文件后原子替换日志
+func (s *fileStorage) Compact(snapshot map[string]*ServerInfo) func() error {
+	s.mu.Lock()
+	s.pending = [][]byte{}
+	s.mu.Unlock()
+	return func() error {
+		err := s.rewrite(snapshot)
+		s.mu.Lock()
+		s.pending = nil
+		s.mu.Unlock()
+		return err
+	}
+}
+
+func (s *fileStorage) rewrite(snapshot map[string]*ServerInfo) error {
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("创建压缩文件失败: %v", err)
+	}
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for key, server := range snapshot {
+		rec := storageRecord{Op: opSnapshot, Key: key, Server: server, Rollup1m: server.Rollup1m, Rollup1h: server.Rollup1h}
+		if err := enc.Encode(rec); err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("写入压缩文件失败: %v", err)
+		}
+	}
+
+	// 补写快照之后追加的记录，替换完成前阻塞新的追加
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, line := range s.pending {
+		if _, err := w.Write(line); err != nil {
+			break
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("写入压缩文件失败: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("同步压缩文件失败: %v", err)
+	}
+	tmp.Close()
+
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		// 替换失败时继续向旧日志追加，不丢数据
+		if openErr := s.open(); openErr != nil {
+			return fmt.Errorf("替换数据文件失败: %v (重新打开失败: %v)", err, openErr)
+		}
+		return fmt.Errorf("替换数据文件失败: %v", err)
+	}
+	return s.open()
+}
+
+func (s *fileStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// compactRoutine 定期压缩存储日志
+func compactRoutine() {
+	interval := time.Duration(serverConfig.CompactInterval) * time.Minute
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		compactStorage()
+	}
+}
+
+// compactStorage 持有读锁复制快照后即释放，写盘期间 handleData 可以继续写入
+func compactStorage() {
+	start := time.Now()
+	data.mu.RLock()
+	snapshot := snapshotServers(data.servers)
+	finish := store.Compact(snapshot)
+	data.mu.RUnlock()
+
+	if err := finish(); err != nil {
+		log.Printf("[存储] 压缩失败: %v", err)
+		return
+	}
+	log.Printf("[存储] 压缩完成: %d 台服务器, 耗时 %v", len(snapshot), time.Since(start))
+}
+
+// snapshotServers 复制服务器数据供压缩写盘（调用方需持有data.mu读锁）
+// 样本写入后不再修改，只复制切片；聚合桶会被原地更新，需要深拷贝
+func snapshotServers(servers map[string]*ServerInfo) map[string]*ServerInfo {
+	snapshot := make(map[string]*ServerInfo, len(servers))
+	for key, server := range servers {
+		s := *server
+		s.History = append([]*SystemInfo(nil), server.History...)
+		s.Rollup1m = cloneRollups(server.Rollup1m)
+		s.Rollup1h = cloneRollups(server.Rollup1h)
+		snapshot[key] = &s
+	}
+	return snapshot
+}
+
+func cloneRollups(points []*RollupPoint) []*RollupPoint {
+	if points == nil {
+		return nil
+	}
+	clone := make([]*RollupPoint, len(points))
+	for i, p := range points {
+		metrics := make(map[string]*AggValue, len(p.Metrics))
+		for name, agg := range p.Metrics {
+			a := *agg
+			metrics[name] = &a
+		}
+		clone[i] = &RollupPoint{Timestamp: p.Timestamp, Count: p.Count, Metrics: metrics}
+	}
+	return clone
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestStorage(t *testing.T, dir string) *fileStorage {
+	t.Helper()
+	s, err := newFileStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func loadTestStorage(t *testing.T, dir string) map[string]*ServerInfo {
+	t.Helper()
+	s := openTestStorage(t, dir)
+	servers, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	return servers
+}
+
+func appendSamples(t *testing.T, s Storage, key string, start time.Time, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		ts := start.Add(time.Duration(i) * 5 * time.Second)
+		info := &SystemInfo{Hostname: key, SessionID: key, Timestamp: ts, CPU: CPUInfo{UsagePercent: float64(i)}}
+		if err := s.Append(key, info, ts); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFileStorageReloadAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().Add(-time.Minute).Truncate(time.Second)
+	s := openTestStorage(t, dir)
+	appendSamples(t, s, "web-01", start, 3)
+	appendSamples(t, s, "web-02", start, 2)
+	if err := s.Delete("web-02"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	servers := loadTestStorage(t, dir)
+	if len(servers) != 1 || servers["web-01"] == nil {
+		t.Fatalf("servers = %v, want only web-01", servers)
+	}
+	server := servers["web-01"]
+	if len(server.History) != 3 || server.Latest.CPU.UsagePercent != 2 || len(server.Rollup1m) == 0 {
+		t.Errorf("history %d, latest %+v, rollups %d", len(server.History), server.Latest.CPU, len(server.Rollup1m))
+	}
+}
+
+func TestFileStorageTornFinalLine(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().Add(-time.Minute).Truncate(time.Second)
+	s := openTestStorage(t, dir)
+	appendSamples(t, s, "web-01", start, 2)
+	s.Close()
+
+	// 模拟写到一半时进程崩溃
+	f, err := os.OpenFile(filepath.Join(dir, "history.log"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"append","key":"web-01","info":{"hostn`)
+	f.Close()
+
+	// 重启后继续追加，新记录不能与半行拼在一起
+	s = openTestStorage(t, dir)
+	servers, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(servers["web-01"].History); got != 2 {
+		t.Fatalf("history = %d, want 2", got)
+	}
+	appendSamples(t, s, "web-01", start.Add(time.Minute), 1)
+	s.Close()
+
+	if got := len(loadTestStorage(t, dir)["web-01"].History); got != 3 {
+		t.Errorf("history after append = %d, want 3", got)
+	}
+}
+
+func TestFileStorageAppendDuringCompaction(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().Add(-time.Minute).Truncate(time.Second)
+	s := openTestStorage(t, dir)
+	appendSamples(t, s, "web-01", start, 3)
+	servers, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	finish := s.Compact(snapshotServers(servers))
+	// 快照之后、写盘之前的追加进入pending，补写到新日志中
+	appendSamples(t, s, "web-01", start.Add(15*time.Second), 1)
+	appendSamples(t, s, "web-02", start, 1)
+	if len(s.pending) != 2 {
+		t.Fatalf("pending = %d, want 2", len(s.pending))
+	}
+	if err := finish(); err != nil {
+		t.Fatal(err)
+	}
+	if s.pending != nil {
+		t.Error("pending kept after compaction")
+	}
+	// 压缩后继续写入新日志
+	appendSamples(t, s, "web-02", start.Add(5*time.Second), 1)
+	s.Close()
+
+	if _, err := os.Stat(filepath.Join(dir, "history.log.tmp")); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+	servers = loadTestStorage(t, dir)
+	if got := len(servers["web-01"].History); got != 4 {
+		t.Errorf("web-01 history = %d, want 4", got)
+	}
+	if got := len(servers["web-02"].History); got != 2 {
+		t.Errorf("web-02 history = %d, want 2", got)
+	}
+}
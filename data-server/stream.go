@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	streamBufferSize    = 64               // 每个订阅者的事件缓冲
+	streamHeartbeat     = 15 * time.Second // SSE心跳间隔，防止代理断开空闲连接
+	statusWatchInterval = 5 * time.Second  // 在线/离线状态巡检间隔
+	streamEventUpdate   = "update"
+	streamEventRemove   = "remove"
+	streamEventSnapshot = "snapshot"
+)
+
+// streamEvent 推送给订阅者的事件
+type streamEvent struct {
+	Type string
+	Data interface{}
+}
+
+// streamRemoval 服务器被清理时推送的数据
+type streamRemoval struct {
+	Hostname  string `json:"hostname"`
+	SessionID string `json:"session_id,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+// streamSubscriber 一个SSE连接
+type streamSubscriber struct {
+	match  func(projectKey string) bool
+	events chan streamEvent
+	closed bool
+}
+
+// StreamHub 按访问密钥分发服务器状态变更
+type StreamHub struct {
+	mu          sync.Mutex
+	subscribers map[*streamSubscriber]struct{}
+	lastStatus  map[string]string // key: sessionID, value: online/offline
+	closed      bool              // 服务器关闭中，不再接受新的订阅
+}
+
+var streamHub = &StreamHub{
+	subscribers: make(map[*streamSubscriber]struct{}),
+	lastStatus:  make(map[string]string),
+}
+
+func (h *StreamHub) subscribe(match func(projectKey string) bool) *streamSubscriber {
+	sub := &streamSubscriber{
+		match:  match,
+		events: make(chan streamEvent, streamBufferSize),
+	}
+	h.mu.Lock()
+	if h.closed {
+		sub.closed = true
+		close(sub.events)
+	} else {
+		h.subscribers[sub] = struct{}{}
+	}
+	h.mu.Unlock()
+	return sub
+}
+
+// closeAll 服务器关闭时结束全部SSE连接，http.Server.Shutdown不会取消长连接请求的context
+func (h *StreamHub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subscribers {
+		h.closeLocked(sub)
+	}
+}
+
+func (h *StreamHub) unsubscribe(sub *streamSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closeLocked(sub)
+}
+
+func (h *StreamHub) closeLocked(sub *streamSubscriber) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(h.subscribers, sub)
+	close(sub.events)
+}
+
+// publish 向匹配项目的订阅者推送事件；缓冲已满的慢连接直接断开，由客户端重连后重新获取快照
+func (h *StreamHub) publish(projectKey string, event streamEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscribers {
+		if !sub.match(projectKey) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			log.Printf("[推送] 订阅者处理过慢，断开连接")
+			h.closeLocked(sub)
+		}
+	}
+}
+
+// publishStatus 推送服务器最新状态（调用方需持有data.mu锁）
+func (h *StreamHub) publishStatus(key string, server *ServerInfo, now time.Time) {
+	if server.Latest == nil {
+		return
+	}
+	status := buildServerStatus(server, now)
+
+	h.mu.Lock()
+	h.lastStatus[key] = status.Status
+	h.mu.Unlock()
+
+	h.publish(server.Latest.ProjectKey, streamEvent{Type: streamEventUpdate, Data: status})
+}
+
+// publishRemoval 推送服务器被清理（调用方需持有data.mu锁），reason为 cleanup 或 replaced
+func (h *StreamHub) publishRemoval(key string, server *ServerInfo, reason string) {
+	h.mu.Lock()
+	delete(h.lastStatus, key)
+	h.mu.Unlock()
+
+	if server.Latest == nil {
+		return
+	}
+	h.publish(server.Latest.ProjectKey, streamEvent{Type: streamEventRemove, Data: streamRemoval{
+		Hostname:  server.Latest.Hostname,
+		SessionID: server.Latest.SessionID,
+		Reason:    reason,
+	}})
+}
+
+// statusWatchRoutine 定期检查在线/离线状态变化，变化时推送
+func statusWatchRoutine() {
+	ticker := time.NewTicker(statusWatchInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+		data.mu.RLock()
+		for key, server := range data.servers {
+			if server.Latest == nil {
+				continue
+			}
+			status := buildServerStatus(server, now)
+
+			streamHub.mu.Lock()
+			changed := streamHub.lastStatus[key] != status.Status
+			streamHub.lastStatus[key] = status.Status
+			streamHub.mu.Unlock()
+
+			if changed {
+				streamHub.publish(server.Latest.ProjectKey, streamEvent{Type: streamEventUpdate, Data: status})
+			}
+		}
+		data.mu.RUnlock()
+	}
+}
+
+// handleStreamPublic 推送public项目的服务器状态
+func handleStreamPublic(w http.ResponseWriter, r *http.Request) {
+	serveStream(w, r, isPublicProject)
+}
+
+// handleStreamByAccessKey 推送访问密钥对应项目的服务器状态
+func handleStreamByAccessKey(w http.ResponseWriter, r *http.Request) {
+	accessKey := mux.Vars(r)["accessKey"]
+	if accessKey == "" {
+		http.Error(w, "无效的访问密钥", http.StatusUnauthorized)
+		return
+	}
+	serveStream(w, r, accessKeyMatcher(accessKey))
+}
+
+// serveStream 以Server-Sent Events方式推送：先发送完整快照，之后只推送变化的服务器
+func serveStream(w http.ResponseWriter, r *http.Request, match func(projectKey string) bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "当前连接不支持流式推送", http.StatusInternalServerError)
+		return
+	}
+
+	sub := streamHub.subscribe(match)
+	defer streamHub.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // 关闭nginx缓冲
+
+	// 先订阅再取快照，避免两者之间的更新丢失
+	data.mu.RLock()
+	snapshot := collectServerStatuses(match)
+	data.mu.RUnlock()
+	if snapshot == nil {
+		snapshot = []ServerStatus{}
+	}
+	if err := writeStreamEvent(w, streamEvent{Type: streamEventSnapshot, Data: snapshot}); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.events:
+			if !ok {
+				return
+			}
+			if err := writeStreamEvent(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeStreamEvent(w http.ResponseWriter, event streamEvent) error {
+	payload, err := json.Marshal(event.Data)
+	if err != nil {
+		log.Printf("Error encoding stream event: %v", err)
+		return nil
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, payload)
+	return err
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShutdownClosesStreams(t *testing.T) {
+	useTestGlobals(t, map[string]*ServerInfo{})
+	srv := httptest.NewServer(http.HandlerFunc(handleStreamPublic))
+	defer srv.Close()
+	srv.Config.RegisterOnShutdown(streamHub.closeAll)
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "event: snapshot") {
+		t.Fatalf("first line = %q, %v", line, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := srv.Config.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown waited for the open stream: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("shutdown took %v", elapsed)
+	}
+
+	// 关闭后的订阅立即结束
+	sub := streamHub.subscribe(func(string) bool { return true })
+	if _, ok := <-sub.events; ok {
+		t.Error("subscription accepted after shutdown")
+	}
+}
@@ -5,6 +5,9 @@ class GPUMonitor {
         this.selectedServer = null;
         this.pollInterval = null;
         this.pollFrequency = CONFIG.POLL_INTERVAL; // 使用配置文件
+        this.eventSource = null; // SSE连接
+        this.streamServers = new Map(); // SSE推送的服务器状态 sessionID/hostname -> server
+        this.streamRenderTimer = null;
         this.apiBaseUrl = CONFIG.API_BASE_URL; // API基础地址
         this.apiEndpoint = this.apiBaseUrl + '/api/servers'; // API端点
         this.accessKey = CONFIG.ACCESS_KEY;
@@ -65,8 +68,12 @@ class GPUMonitor {
         setTimeout(() => {
             this.applyLanguage();
         }, 100);
-        this.startPolling();
-        this.loadInitialData();
+        if (CONFIG.USE_STREAM && window.EventSource) {
+            this.startStream();
+        } else {
+            this.startPolling();
+            this.loadInitialData();
+        }
         this.initializeTutorial();
     }
 
@@ -2751,6 +2758,81 @@ class GPUMonitor {
         }, this.pollFrequency);
     }
 
+    startStream() {
+        // /api/servers -> /api/stream, /api/access/{key}/servers -> /api/access/{key}/stream
+        const streamEndpoint = this.apiEndpoint.replace(/\/servers$/, '/stream');
+        console.log('开始SSE实时推送:', streamEndpoint);
+
+        let opened = false;
+        this.eventSource = new EventSource(streamEndpoint);
+
+        this.eventSource.onopen = () => {
+            opened = true;
+            this.updateConnectionStatus(true);
+        };
+
+        this.eventSource.addEventListener('snapshot', (e) => {
+            this.streamServers.clear();
+            JSON.parse(e.data).forEach(server => {
+                this.streamServers.set(server.session_id || server.hostname, server);
+            });
+            this.renderStreamServers();
+        });
+
+        this.eventSource.addEventListener('update', (e) => {
+            const server = JSON.parse(e.data);
+            this.streamServers.set(server.session_id || server.hostname, server);
+            this.scheduleStreamRender();
+        });
+
+        this.eventSource.addEventListener('remove', (e) => {
+            const server = JSON.parse(e.data);
+            this.streamServers.delete(server.session_id || server.hostname);
+            this.servers.delete(server.hostname);
+            this.scheduleStreamRender();
+        });
+
+        this.eventSource.onerror = () => {
+            if (!opened || this.eventSource.readyState === EventSource.CLOSED) {
+                // 服务端不支持推送或连接被关闭，回退到轮询
+                console.warn('SSE连接失败，回退到HTTP轮询');
+                this.stopStream();
+                this.startPolling();
+                return;
+            }
+            // 浏览器会自动重连，重连成功后会收到新的快照
+            this.updateConnectionStatus(false);
+        };
+    }
+
+    stopStream() {
+        if (this.eventSource) {
+            this.eventSource.close();
+            this.eventSource = null;
+        }
+        if (this.streamRenderTimer) {
+            clearTimeout(this.streamRenderTimer);
+            this.streamRenderTimer = null;
+        }
+    }
+
+    // 合并短时间内的多次推送，避免每条更新都重新渲染全部卡片
+    scheduleStreamRender() {
+        if (this.streamRenderTimer) {
+            return;
+        }
+        this.streamRenderTimer = setTimeout(() => {
+            this.streamRenderTimer = null;
+            this.renderStreamServers();
+        }, 500);
+    }
+
+    renderStreamServers() {
+        const servers = Array.from(this.streamServers.values())
+            .sort((a, b) => a.hostname.localeCompare(b.hostname));
+        this.updateServers(servers);
+    }
+
     stopPolling() {
         if (this.pollInterval) {
             clearInterval(this.pollInterval);
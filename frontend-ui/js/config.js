@@ -5,6 +5,7 @@ const CONFIG = {
     
     // 轮询配置
     POLL_INTERVAL: 3000,                    // 数据轮询间隔(毫秒)
+    USE_STREAM: true,                       // 优先使用SSE实时推送，不可用时回退到轮询
     
     // UI配置
     DEFAULT_LANGUAGE: 'auto',               // 默认语言 'zh', 'en', 'auto'
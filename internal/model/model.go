@@ -0,0 +1,354 @@
+// Package model 定义代理上报、data-server存储和导出共用的采集数据结构
+package model
+
+import "time"
+
+// SystemInfo 代理一次采集的完整数据
+type SystemInfo struct {
+	Hostname    string          `json:"hostname"`
+	SessionID   string          `json:"session_id,omitempty"` // UUID session标识
+	Timestamp   time.Time       `json:"timestamp"`
+	CPU         CPUInfo         `json:"cpu"`
+	Memory      MemInfo         `json:"memory"`
+	Disk        DiskInfo        `json:"disk"`              // 保持兼容性，根分区（Windows为C:）
+	Disks       []DiskMount     `json:"disks,omitempty"`   // 所有挂载点
+	DiskIO      []DiskIOStat    `json:"disk_io,omitempty"` // 各磁盘设备I/O
+	Network     NetInfo         `json:"network"`
+	GPU         GPUInfo         `json:"gpu"`  // 保持兼容性，主GPU信息
+	GPUs        []GPUInfo       `json:"gpus"` // 所有GPU信息
+	OS          OSInfo          `json:"os"`
+	Temperature TempInfo        `json:"temperature"`
+	Processes   *ProcessStats   `json:"processes,omitempty"`  // 占用最高的进程
+	Services    []ServiceStatus `json:"services,omitempty"`   // 监控的进程和服务
+	Containers  []ContainerInfo `json:"containers,omitempty"` // Docker容器
+	Cgroup      *CgroupInfo     `json:"cgroup,omitempty"`     // 容器视图下的cgroup数据
+	Checks      []CheckResult   `json:"checks,omitempty"`     // 代理侧主动检查结果
+	Custom      []PluginResult  `json:"custom,omitempty"`     // 自定义指标插件的输出
+	Logs        []LogStats      `json:"logs,omitempty"`       // 日志文件的匹配统计
+	Tags        []string        `json:"tags,omitempty"`       // 代理配置的标签，用于匹配探测任务
+	ProjectKey  string          `json:"project_key,omitempty"`
+}
+
+// CheckResult 代理侧主动检查（ping/tcp/http/tls）最近一次的结果
+type CheckResult struct {
+	Name         string     `json:"name"`
+	Type         string     `json:"type"`                     // ping / tcp / http / tls
+	Target       string     `json:"target"`                   // 检查目标
+	Success      bool       `json:"success"`                  // 是否成功
+	LatencyMs    float64    `json:"latency_ms"`               // 响应时间 (ms)，ping为平均往返时间
+	PacketLoss   float64    `json:"packet_loss,omitempty"`    // ping丢包率
+	StatusCode   int        `json:"status_code,omitempty"`    // HTTP状态码
+	CertExpiry   *time.Time `json:"cert_expiry,omitempty"`    // 服务器证书过期时间
+	CertDaysLeft float64    `json:"cert_days_left,omitempty"` // 证书剩余天数
+	Error        string     `json:"error,omitempty"`          // 失败原因
+	Failures     int        `json:"failures,omitempty"`       // 连续失败次数
+	CheckedAt    time.Time  `json:"checked_at"`               // 检查时间
+	Task         string     `json:"task,omitempty"`           // 服务器下发的探测任务名，本地配置的检查为空
+	Peer         string     `json:"peer,omitempty"`           // 网格探测的对端服务器标识
+}
+
+// PluginResult 自定义指标插件最近一次的输出
+type PluginResult struct {
+	Name        string         `json:"name"`
+	Metrics     []CustomMetric `json:"metrics,omitempty"`
+	Error       string         `json:"error,omitempty"` // 执行或解析失败的原因
+	DurationMs  float64        `json:"duration_ms"`     // 执行耗时 (ms)
+	CollectedAt time.Time      `json:"collected_at"`
+}
+
+// CustomMetric 插件输出的一个指标
+type CustomMetric struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"` // Prometheus格式输出的标签
+	Value  float64           `json:"value"`
+	Type   string            `json:"type,omitempty"` // counter / gauge，输出中未声明时为空
+}
+
+// LogStats 日志文件在一个上报周期内的匹配统计
+type LogStats struct {
+	Name     string            `json:"name"`
+	Path     string            `json:"path"`
+	Lines    int               `json:"lines"` // 本周期读取的行数
+	Patterns []LogPatternStats `json:"patterns"`
+	Recent   []LogLine         `json:"recent,omitempty"` // 最近的匹配行，只保留在最新数据中
+	Error    string            `json:"error,omitempty"`  // 文件不存在或读取失败的原因
+}
+
+// LogPatternStats 一条匹配规则的统计
+type LogPatternStats struct {
+	Name  string  `json:"name"`
+	Count int     `json:"count"` // 本周期匹配的行数
+	Rate  float64 `json:"rate"`  // 每秒匹配的行数
+	Total uint64  `json:"total"` // 代理启动以来匹配的行数
+}
+
+// LogLine 一行匹配的日志
+type LogLine struct {
+	Pattern string    `json:"pattern"`
+	Line    string    `json:"line"` // 超长时截断
+	Time    time.Time `json:"time"` // 代理读取到该行的时间
+}
+
+// ProcessStats 进程统计，只包含占用最高的前N个进程
+type ProcessStats struct {
+	Total     int           `json:"total"`      // 进程总数
+	TopCPU    []ProcessInfo `json:"top_cpu"`    // 按CPU使用率排序
+	TopMemory []ProcessInfo `json:"top_memory"` // 按常驻内存排序
+}
+
+type ProcessInfo struct {
+	PID        int32   `json:"pid"`
+	Name       string  `json:"name"`
+	User       string  `json:"user"`
+	Cmdline    string  `json:"cmdline"`     // 超长时截断
+	CPUPercent float64 `json:"cpu_percent"` // 100表示占满一个核
+	MemPercent float64 `json:"mem_percent"`
+	RSS        uint64  `json:"rss"`
+	Threads    int32   `json:"threads"`
+	OpenFDs    int32   `json:"open_fds"`
+}
+
+// ServiceStatus 监控的进程或systemd服务状态
+type ServiceStatus struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"` // process / systemd
+	Running  bool   `json:"running"`
+	Count    int    `json:"count"`           // 匹配的进程数，systemd服务为主进程是否存在
+	Restarts int    `json:"restarts"`        // 进程为代理启动后观察到的重启次数，systemd服务为NRestarts
+	Failed   bool   `json:"failed"`          // 进程数不足或systemd服务失败
+	State    string `json:"state,omitempty"` // systemd的 ActiveState/SubState
+}
+
+// ContainerInfo Docker容器状态和资源使用
+type ContainerInfo struct {
+	ID              string  `json:"id"` // 短ID
+	Name            string  `json:"name"`
+	Image           string  `json:"image"`
+	State           string  `json:"state"`            // running / exited / restarting ...
+	Status          string  `json:"status"`           // 如 "Up 2 hours"
+	Health          string  `json:"health,omitempty"` // 健康检查状态
+	RestartCount    int     `json:"restart_count"`
+	CPUPercent      float64 `json:"cpu_percent"`  // 100表示占满一个核
+	MemoryUsage     uint64  `json:"memory_usage"` // 不含可回收的缓存
+	MemoryLimit     uint64  `json:"memory_limit"`
+	MemoryPercent   float64 `json:"memory_percent"`
+	NetRxBytes      uint64  `json:"net_rx_bytes"`
+	NetTxBytes      uint64  `json:"net_tx_bytes"`
+	NetRxSpeed      float64 `json:"net_rx_speed"` // 接收速率 (KB/s)
+	NetTxSpeed      float64 `json:"net_tx_speed"` // 发送速率 (KB/s)
+	BlockReadBytes  uint64  `json:"block_read_bytes"`
+	BlockWriteBytes uint64  `json:"block_write_bytes"`
+	BlockReadSpeed  float64 `json:"block_read_speed"`  // 块设备读取速率 (KB/s)
+	BlockWriteSpeed float64 `json:"block_write_speed"` // 块设备写入速率 (KB/s)
+	PIDs            uint64  `json:"pids"`
+}
+
+// CgroupInfo 容器视图下cgroup v2的资源限制和使用情况
+type CgroupInfo struct {
+	CPULimit         float64       `json:"cpu_limit"`         // 可用核数（cpu.max与cpuset取较小值），0为不限制
+	CPUUsagePercent  float64       `json:"cpu_usage_percent"` // 相对可用核数
+	CPUUsageSeconds  float64       `json:"cpu_usage_seconds"` // 累计CPU时间
+	NrPeriods        uint64        `json:"nr_periods"`
+	NrThrottled      uint64        `json:"nr_throttled"`
+	ThrottledSeconds float64       `json:"throttled_seconds"` // 累计被限流时长
+	ThrottledPercent float64       `json:"throttled_percent"` // 采集间隔内被限流的调度周期占比
+	MemoryLimit      uint64        `json:"memory_limit"`      // 0为不限制
+	MemoryUsage      uint64        `json:"memory_usage"`      // 不含可回收的inactive_file
+	MemoryPercent    float64       `json:"memory_percent"`    // 相对限制，不限制时为0
+	MemoryCache      uint64        `json:"memory_cache"`
+	SwapUsage        uint64        `json:"swap_usage"`
+	OOMKills         uint64        `json:"oom_kills"`
+	CPUPressure      *PressureStat `json:"cpu_pressure,omitempty"`
+	MemoryPressure   *PressureStat `json:"memory_pressure,omitempty"`
+	IOPressure       *PressureStat `json:"io_pressure,omitempty"`
+}
+
+// PressureStat PSI压力，等待资源的时间占比(%)
+type PressureStat struct {
+	SomeAvg10  float64 `json:"some_avg10"`
+	SomeAvg60  float64 `json:"some_avg60"`
+	SomeAvg300 float64 `json:"some_avg300"`
+	FullAvg10  float64 `json:"full_avg10"`
+	FullAvg60  float64 `json:"full_avg60"`
+	FullAvg300 float64 `json:"full_avg300"`
+}
+
+type CPUInfo struct {
+	UsagePercent   float64   `json:"usage_percent"`
+	CoreCount      int       `json:"core_count"`
+	ModelName      string    `json:"model_name"`
+	PerCore        []float64 `json:"per_core,omitempty"` // 各核使用率
+	UserPercent    float64   `json:"user_percent"`
+	SystemPercent  float64   `json:"system_percent"`
+	IdlePercent    float64   `json:"idle_percent"`
+	NicePercent    float64   `json:"nice_percent"`
+	IOWaitPercent  float64   `json:"iowait_percent"`
+	IRQPercent     float64   `json:"irq_percent"`
+	SoftIRQPercent float64   `json:"softirq_percent"`
+	StealPercent   float64   `json:"steal_percent"`
+	GuestPercent   float64   `json:"guest_percent"`
+	Load1          float64   `json:"load1"`
+	Load5          float64   `json:"load5"`
+	Load15         float64   `json:"load15"`
+	CtxSwitchRate  float64   `json:"ctx_switch_rate"` // 上下文切换次数/秒
+	InterruptRate  float64   `json:"interrupt_rate"`  // 中断次数/秒
+}
+
+type MemInfo struct {
+	Total          uint64  `json:"total"`
+	Used           uint64  `json:"used"`
+	Free           uint64  `json:"free"`
+	UsagePercent   float64 `json:"usage_percent"`
+	Available      uint64  `json:"available"` // 可用内存（含可回收的缓存）
+	Buffers        uint64  `json:"buffers"`
+	Cached         uint64  `json:"cached"`
+	Shared         uint64  `json:"shared"`
+	SwapTotal      uint64  `json:"swap_total"`
+	SwapUsed       uint64  `json:"swap_used"`
+	SwapFree       uint64  `json:"swap_free"`
+	SwapPercent    float64 `json:"swap_percent"`
+	SwapInSpeed    float64 `json:"swap_in_speed"`  // 换入速率 (KB/s)
+	SwapOutSpeed   float64 `json:"swap_out_speed"` // 换出速率 (KB/s)
+	HugePagesTotal uint64  `json:"hugepages_total"`
+	HugePagesFree  uint64  `json:"hugepages_free"`
+	HugePageSize   uint64  `json:"hugepage_size"`
+}
+
+type DiskInfo struct {
+	Total        uint64  `json:"total"`
+	Used         uint64  `json:"used"`
+	Free         uint64  `json:"free"`
+	UsagePercent float64 `json:"usage_percent"`
+}
+
+// DiskMount 单个挂载点的使用情况
+type DiskMount struct {
+	Mountpoint         string  `json:"mountpoint"`
+	Device             string  `json:"device"`
+	Fstype             string  `json:"fstype"`
+	Total              uint64  `json:"total"`
+	Used               uint64  `json:"used"`
+	Free               uint64  `json:"free"`
+	UsagePercent       float64 `json:"usage_percent"`
+	InodesTotal        uint64  `json:"inodes_total"`
+	InodesUsed         uint64  `json:"inodes_used"`
+	InodesFree         uint64  `json:"inodes_free"`
+	InodesUsagePercent float64 `json:"inodes_usage_percent"`
+}
+
+// DiskIOStat 单个磁盘设备的I/O统计，速率类字段按与上次采集的差值计算
+type DiskIOStat struct {
+	Name        string  `json:"name"`         // 设备名
+	ReadBytes   uint64  `json:"read_bytes"`   // 累计读取字节数
+	WriteBytes  uint64  `json:"write_bytes"`  // 累计写入字节数
+	ReadCount   uint64  `json:"read_count"`   // 累计读次数
+	WriteCount  uint64  `json:"write_count"`  // 累计写次数
+	ReadSpeed   float64 `json:"read_speed"`   // 读取速率 (KB/s)
+	WriteSpeed  float64 `json:"write_speed"`  // 写入速率 (KB/s)
+	ReadIOPS    float64 `json:"read_iops"`    // 每秒读次数
+	WriteIOPS   float64 `json:"write_iops"`   // 每秒写次数
+	Await       float64 `json:"await"`        // 平均每次I/O耗时 (ms)
+	UtilPercent float64 `json:"util_percent"` // 设备繁忙时间占比
+}
+
+type NetInfo struct {
+	BytesSent   uint64          `json:"bytes_sent"`          // 总发送字节数
+	BytesRecv   uint64          `json:"bytes_recv"`          // 总接收字节数
+	PacketsSent uint64          `json:"packets_sent"`        // 总发送包数
+	PacketsRecv uint64          `json:"packets_recv"`        // 总接收包数
+	SpeedSent   float64         `json:"speed_sent"`          // 发送速率 (KB/s)
+	SpeedRecv   float64         `json:"speed_recv"`          // 接收速率 (KB/s)
+	Interfaces  []NetInterface  `json:"interfaces"`          // 网卡详细信息
+	Sockets     *SocketStats    `json:"sockets,omitempty"`   // TCP/UDP套接字统计
+	Listening   []ListenPort    `json:"listening,omitempty"` // TCP监听端口
+	Conntrack   *ConntrackStats `json:"conntrack,omitempty"` // 连接跟踪表使用情况
+}
+
+// SocketStats TCP连接按状态计数，包含IPv4和IPv6
+type SocketStats struct {
+	TCP         int `json:"tcp"`         // TCP套接字总数
+	Established int `json:"established"` // ESTABLISHED
+	SynSent     int `json:"syn_sent"`    // SYN_SENT
+	SynRecv     int `json:"syn_recv"`    // SYN_RECV，持续偏高可能是SYN洪水或backlog不足
+	FinWait     int `json:"fin_wait"`    // FIN_WAIT1 + FIN_WAIT2
+	TimeWait    int `json:"time_wait"`   // TIME_WAIT
+	CloseWait   int `json:"close_wait"`  // CLOSE_WAIT，持续增长通常是应用未关闭连接
+	LastAck     int `json:"last_ack"`    // LAST_ACK
+	Listen      int `json:"listen"`      // LISTEN
+	UDP         int `json:"udp"`         // UDP套接字数
+}
+
+// ListenPort 监听中的TCP端口及所属进程
+type ListenPort struct {
+	Protocol string `json:"protocol"`          // tcp 或 tcp6
+	Address  string `json:"address"`           // 监听地址
+	Port     int    `json:"port"`              // 端口
+	PID      int32  `json:"pid,omitempty"`     // 所属进程，无权限读取时为空
+	Process  string `json:"process,omitempty"` // 进程名
+}
+
+// ConntrackStats netfilter连接跟踪表，满了之后新连接会被丢弃
+type ConntrackStats struct {
+	Count   uint64  `json:"count"`   // 当前条目数
+	Max     uint64  `json:"max"`     // 上限（nf_conntrack_max）
+	Percent float64 `json:"percent"` // 使用率
+}
+
+type NetInterface struct {
+	Name        string   `json:"name"`                 // 网卡名称
+	BytesSent   uint64   `json:"bytes_sent"`           // 发送字节数
+	BytesRecv   uint64   `json:"bytes_recv"`           // 接收字节数
+	PacketsSent uint64   `json:"packets_sent"`         // 发送包数
+	PacketsRecv uint64   `json:"packets_recv"`         // 接收包数
+	SpeedSent   float64  `json:"speed_sent"`           // 发送速率 (KB/s)
+	SpeedRecv   float64  `json:"speed_recv"`           // 接收速率 (KB/s)
+	IsUp        bool     `json:"is_up"`                // 网卡状态
+	MTU         int      `json:"mtu"`                  // MTU
+	Addrs       []string `json:"addrs"`                // IP地址列表
+	OperState   string   `json:"oper_state,omitempty"` // 运行状态: up / down / unknown / dormant 等（仅Linux）
+	LinkSpeed   int      `json:"link_speed,omitempty"` // 协商速率 (Mbps)，虚拟网卡和未连接时为0
+	Duplex      string   `json:"duplex,omitempty"`     // full / half
+	Kind        string   `json:"kind,omitempty"`       // 网卡类型: physical / loopback / bridge / bond / vlan / veth / tun / virtual
+	ErrIn       uint64   `json:"err_in"`               // 累计接收错误数
+	ErrOut      uint64   `json:"err_out"`              // 累计发送错误数
+	DropIn      uint64   `json:"drop_in"`              // 累计接收丢包数
+	DropOut     uint64   `json:"drop_out"`             // 累计发送丢包数
+	ErrInRate   float64  `json:"err_in_rate"`          // 每秒接收错误数
+	ErrOutRate  float64  `json:"err_out_rate"`         // 每秒发送错误数
+	DropInRate  float64  `json:"drop_in_rate"`         // 每秒接收丢包数
+	DropOutRate float64  `json:"drop_out_rate"`        // 每秒发送丢包数
+}
+
+type GPUInfo struct {
+	Name          string  `json:"name"`
+	MemoryTotal   uint64  `json:"memory_total"`
+	MemoryUsed    uint64  `json:"memory_used"`
+	UsagePercent  float64 `json:"usage_percent"`
+	Temperature   float64 `json:"temperature"`
+	DriverVersion string  `json:"driver_version"`
+	CudaVersion   string  `json:"cuda_version"`
+}
+
+type OSInfo struct {
+	Platform string `json:"platform"`
+	Version  string `json:"version"`
+	Arch     string `json:"arch"`
+	Uptime   uint64 `json:"uptime"`
+}
+
+type TempInfo struct {
+	CPUTemp float64            `json:"cpu_temp"`
+	GPUTemp float64            `json:"gpu_temp"`
+	Sensors map[string]float64 `json:"sensors"`
+	MaxTemp float64            `json:"max_temp"`
+	AvgTemp float64            `json:"avg_temp"`
+}
+
+// MemoryPercent 按可用内存计算使用率（页缓存可回收，不算占用），旧版代理没有available时使用上报值
+func (info *SystemInfo) MemoryPercent() float64 {
+	m := info.Memory
+	if m.Available == 0 || m.Total == 0 || m.Available > m.Total {
+		return m.UsagePercent
+	}
+	return float64(m.Total-m.Available) / float64(m.Total) * 100
+}
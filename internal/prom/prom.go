@@ -0,0 +1,146 @@
+// Package prom 按Prometheus文本格式输出指标，data-server和monitor-agent的 /metrics 共用
+package prom
+
+import (
+	"bytes"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+	Namespace   = "serverstatus_"
+)
+
+// family 同名指标，按Prometheus文本格式输出一组HELP/TYPE
+type family struct {
+	name    string
+	help    string
+	typ     string // gauge / counter
+	samples []string
+}
+
+// Writer 收集指标并按家族分组输出
+type Writer struct {
+	families map[string]*family
+	order    []string
+}
+
+func NewWriter() *Writer {
+	return &Writer{families: make(map[string]*family)}
+}
+
+// Gauge 添加一个gauge样本，labels为 name, value 交替排列
+func (p *Writer) Gauge(name, help string, value float64, labels ...string) {
+	p.add(name, "gauge", help, value, labels)
+}
+
+// Counter 添加一个counter样本，name应以_total结尾
+func (p *Writer) Counter(name, help string, value float64, labels ...string) {
+	p.add(name, "counter", help, value, labels)
+}
+
+// conflictLogged 已提示过类型冲突的指标名，每个名称只记录一次日志
+var conflictLogged sync.Map
+
+func (p *Writer) add(name, typ, help string, value float64, labels []string) {
+	name = Namespace + name
+	f := p.families[name]
+	if f == nil {
+		f = &family{name: name, help: help, typ: typ}
+		p.families[name] = f
+		p.order = append(p.order, name)
+	} else if f.typ != typ {
+		// 同一家族只能有一个TYPE，类型不一致的样本丢弃，否则整个输出都无法解析
+		if _, logged := conflictLogged.LoadOrStore(name+" "+typ, true); !logged {
+			log.Printf("指标 %s 已按 %s 输出，丢弃类型为 %s 的样本 | Metric %s already exported as %s, dropping %s samples",
+				name, f.typ, typ, name, f.typ, typ)
+		}
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(labels[i])
+			b.WriteString(`="`)
+			b.WriteString(escaper.Replace(labels[i+1]))
+			b.WriteByte('"')
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(Float(value))
+	f.samples = append(f.samples, b.String())
+}
+
+func (p *Writer) Bytes() []byte {
+	var buf bytes.Buffer
+	for _, name := range p.order {
+		f := p.families[name]
+		buf.WriteString("# HELP " + name + " " + f.help + "\n")
+		buf.WriteString("# TYPE " + name + " " + f.typ + "\n")
+		for _, sample := range f.samples {
+			buf.WriteString(sample)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func Float(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// MetricName 把插件输出的指标名转换为合法的Prometheus指标名
+func MetricName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c == '_' || c == ':' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' && i > 0) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// CustomLabels 在labels后追加插件指标的标签，按名称排序，与已有标签重名时加 exported_ 前缀
+func CustomLabels(labels []string, extra map[string]string) []string {
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	used := make(map[string]bool)
+	for i := 0; i < len(labels); i += 2 {
+		used[labels[i]] = true
+	}
+	for _, name := range names {
+		label := strings.ReplaceAll(MetricName(name), ":", "_")
+		for used[label] {
+			label = "exported_" + label
+		}
+		used[label] = true
+		labels = append(labels, label, extra[name])
+	}
+	return labels
+}
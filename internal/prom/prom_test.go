@@ -0,0 +1,47 @@
+package prom
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriterGroupsFamilies(t *testing.T) {
+	p := NewWriter()
+	p.Gauge("cpu_usage_percent", "CPU usage in percent.", 12.5, "hostname", "a")
+	p.Counter("network_sent_bytes_total", "Bytes sent.", 100, "hostname", "a")
+	p.Gauge("cpu_usage_percent", "CPU usage in percent.", 50, "hostname", `b"c`)
+
+	want := `# HELP serverstatus_cpu_usage_percent CPU usage in percent.
+# TYPE serverstatus_cpu_usage_percent gauge
+serverstatus_cpu_usage_percent{hostname="a"} 12.5
+serverstatus_cpu_usage_percent{hostname="b\"c"} 50
+# HELP serverstatus_network_sent_bytes_total Bytes sent.
+# TYPE serverstatus_network_sent_bytes_total counter
+serverstatus_network_sent_bytes_total{hostname="a"} 100
+`
+	if got := string(p.Bytes()); got != want {
+		t.Errorf("output =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriterRejectsTypeConflict(t *testing.T) {
+	p := NewWriter()
+	p.Counter("custom_requests", "Custom metric reported by an agent plugin.", 10, "plugin", "a")
+	p.Gauge("custom_requests", "Custom metric reported by an agent plugin.", 3, "plugin", "b")
+
+	out := string(p.Bytes())
+	if strings.Count(out, "# TYPE serverstatus_custom_requests") != 1 || !strings.Contains(out, "# TYPE serverstatus_custom_requests counter") {
+		t.Errorf("expected a single counter family:\n%s", out)
+	}
+	if strings.Contains(out, `plugin="b"`) {
+		t.Errorf("conflicting gauge sample was exported:\n%s", out)
+	}
+}
+
+func TestCustomLabels(t *testing.T) {
+	labels := CustomLabels([]string{"hostname", "a", "plugin", "p"}, map[string]string{"plugin": "x", "bad-name": "y"})
+	want := []string{"hostname", "a", "plugin", "p", "bad_name", "y", "exported_plugin", "x"}
+	if strings.Join(labels, ",") != strings.Join(want, ",") {
+		t.Errorf("labels = %q, want %q", labels, want)
+	}
+}
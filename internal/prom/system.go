@@ -0,0 +1,286 @@
+package prom
+
+import (
+	"sort"
+	"strconv"
+
+	"serverstatus-monitor/internal/model"
+)
+
+// WriteSystem 导出一次采集结果，base为每个样本都带的标签（hostname等）
+func WriteSystem(p *Writer, info *model.SystemInfo, base []string) {
+	with := func(extra ...string) []string {
+		return append(append([]string{}, base...), extra...)
+	}
+
+	p.Gauge("last_seen_timestamp_seconds", "Unix time of the latest sample.", float64(info.Timestamp.Unix()), base...)
+	p.Gauge("info", "Static host information.", 1, with("platform", info.OS.Platform, "version", info.OS.Version, "arch", info.OS.Arch, "cpu_model", info.CPU.ModelName)...)
+	p.Gauge("uptime_seconds", "Host uptime in seconds.", float64(info.OS.Uptime), base...)
+
+	p.Gauge("cpu_usage_percent", "CPU usage in percent.", info.CPU.UsagePercent, base...)
+	p.Gauge("cpu_cores", "Number of CPU cores.", float64(info.CPU.CoreCount), base...)
+	for i, usage := range info.CPU.PerCore {
+		p.Gauge("cpu_core_usage_percent", "CPU usage per core in percent.", usage, with("core", strconv.Itoa(i))...)
+	}
+	if c := info.CPU; c.UserPercent+c.SystemPercent+c.IdlePercent > 0 {
+		modes := []struct {
+			name  string
+			value float64
+		}{
+			{"user", c.UserPercent}, {"system", c.SystemPercent}, {"idle", c.IdlePercent},
+			{"nice", c.NicePercent}, {"iowait", c.IOWaitPercent}, {"irq", c.IRQPercent},
+			{"softirq", c.SoftIRQPercent}, {"steal", c.StealPercent}, {"guest", c.GuestPercent},
+		}
+		for _, mode := range modes {
+			p.Gauge("cpu_mode_percent", "Share of CPU time spent in each mode in percent.", mode.value, with("mode", mode.name)...)
+		}
+		p.Gauge("load1", "1-minute load average.", c.Load1, base...)
+		p.Gauge("load5", "5-minute load average.", c.Load5, base...)
+		p.Gauge("load15", "15-minute load average.", c.Load15, base...)
+		p.Gauge("context_switches_per_second", "Context switches per second.", c.CtxSwitchRate, base...)
+		p.Gauge("interrupts_per_second", "Interrupts per second.", c.InterruptRate, base...)
+	}
+
+	p.Gauge("memory_total_bytes", "Total memory in bytes.", float64(info.Memory.Total), base...)
+	p.Gauge("memory_used_bytes", "Used memory in bytes.", float64(info.Memory.Used), base...)
+	p.Gauge("memory_usage_percent", "Memory usage in percent.", info.MemoryPercent(), base...)
+	p.Gauge("memory_free_bytes", "Free memory in bytes.", float64(info.Memory.Free), base...)
+	if info.Memory.Available > 0 {
+		p.Gauge("memory_available_bytes", "Memory available for new allocations in bytes.", float64(info.Memory.Available), base...)
+		p.Gauge("memory_buffers_bytes", "Memory used by kernel buffers in bytes.", float64(info.Memory.Buffers), base...)
+		p.Gauge("memory_cached_bytes", "Memory used by the page cache in bytes.", float64(info.Memory.Cached), base...)
+		p.Gauge("memory_shared_bytes", "Shared memory in bytes.", float64(info.Memory.Shared), base...)
+	}
+	p.Gauge("swap_total_bytes", "Total swap space in bytes.", float64(info.Memory.SwapTotal), base...)
+	p.Gauge("swap_used_bytes", "Used swap space in bytes.", float64(info.Memory.SwapUsed), base...)
+	p.Gauge("swap_usage_percent", "Swap usage in percent.", info.Memory.SwapPercent, base...)
+	p.Gauge("swap_in_speed_kbytes", "Swap-in rate in KB/s.", info.Memory.SwapInSpeed, base...)
+	p.Gauge("swap_out_speed_kbytes", "Swap-out rate in KB/s.", info.Memory.SwapOutSpeed, base...)
+	if info.Memory.HugePagesTotal > 0 {
+		p.Gauge("hugepages_total", "Total huge pages.", float64(info.Memory.HugePagesTotal), base...)
+		p.Gauge("hugepages_free", "Free huge pages.", float64(info.Memory.HugePagesFree), base...)
+		p.Gauge("hugepage_size_bytes", "Huge page size in bytes.", float64(info.Memory.HugePageSize), base...)
+	}
+
+	if info.Processes != nil {
+		p.Gauge("processes", "Number of processes.", float64(info.Processes.Total), base...)
+	}
+
+	for _, svc := range info.Services {
+		labels := with("service", svc.Name, "type", svc.Type)
+		up := 0.0
+		if svc.Running && !svc.Failed {
+			up = 1
+		}
+		p.Gauge("service_up", "Whether the watched process or systemd unit is running.", up, labels...)
+		p.Gauge("service_processes", "Number of matching processes.", float64(svc.Count), labels...)
+		p.Counter("service_restarts_total", "Restarts of the watched process or systemd unit.", float64(svc.Restarts), labels...)
+	}
+
+	for _, c := range info.Containers {
+		labels := with("container", c.Name, "image", c.Image)
+		running := 0.0
+		if c.State == "running" {
+			running = 1
+		}
+		p.Gauge("container_running", "Whether the container is running.", running, labels...)
+		p.Counter("container_restarts_total", "Container restarts.", float64(c.RestartCount), labels...)
+		if c.State != "running" {
+			continue
+		}
+		p.Gauge("container_cpu_usage_percent", "Container CPU usage in percent of one core.", c.CPUPercent, labels...)
+		p.Gauge("container_memory_usage_bytes", "Container memory usage excluding reclaimable cache in bytes.", float64(c.MemoryUsage), labels...)
+		p.Gauge("container_memory_limit_bytes", "Container memory limit in bytes.", float64(c.MemoryLimit), labels...)
+		p.Counter("container_network_received_bytes_total", "Bytes received by the container.", float64(c.NetRxBytes), labels...)
+		p.Counter("container_network_sent_bytes_total", "Bytes sent by the container.", float64(c.NetTxBytes), labels...)
+		p.Counter("container_block_read_bytes_total", "Bytes read from block devices by the container.", float64(c.BlockReadBytes), labels...)
+		p.Counter("container_block_written_bytes_total", "Bytes written to block devices by the container.", float64(c.BlockWriteBytes), labels...)
+		p.Gauge("container_pids", "Number of processes in the container.", float64(c.PIDs), labels...)
+	}
+
+	if cg := info.Cgroup; cg != nil {
+		p.Gauge("cgroup_cpu_limit_cores", "CPU cores available to the cgroup, 0 if unlimited.", cg.CPULimit, base...)
+		p.Gauge("cgroup_cpu_usage_percent", "cgroup CPU usage in percent of the limit.", cg.CPUUsagePercent, base...)
+		p.Counter("cgroup_cpu_usage_seconds_total", "CPU time consumed by the cgroup.", cg.CPUUsageSeconds, base...)
+		p.Counter("cgroup_cpu_periods_total", "Enforcement periods elapsed.", float64(cg.NrPeriods), base...)
+		p.Counter("cgroup_cpu_throttled_periods_total", "Enforcement periods in which the cgroup was throttled.", float64(cg.NrThrottled), base...)
+		p.Counter("cgroup_cpu_throttled_seconds_total", "Time the cgroup was throttled.", cg.ThrottledSeconds, base...)
+		p.Gauge("cgroup_memory_limit_bytes", "cgroup memory limit in bytes, 0 if unlimited.", float64(cg.MemoryLimit), base...)
+		p.Gauge("cgroup_memory_usage_bytes", "cgroup memory usage excluding inactive file cache in bytes.", float64(cg.MemoryUsage), base...)
+		p.Gauge("cgroup_swap_usage_bytes", "cgroup swap usage in bytes.", float64(cg.SwapUsage), base...)
+		p.Counter("cgroup_oom_kills_total", "Processes killed by the OOM killer in the cgroup.", float64(cg.OOMKills), base...)
+		for _, r := range []struct {
+			name string
+			psi  *model.PressureStat
+		}{{"cpu", cg.CPUPressure}, {"memory", cg.MemoryPressure}, {"io", cg.IOPressure}} {
+			if r.psi == nil {
+				continue
+			}
+			for _, v := range []struct {
+				kind, window string
+				value        float64
+			}{
+				{"some", "10", r.psi.SomeAvg10}, {"some", "60", r.psi.SomeAvg60}, {"some", "300", r.psi.SomeAvg300},
+				{"full", "10", r.psi.FullAvg10}, {"full", "60", r.psi.FullAvg60}, {"full", "300", r.psi.FullAvg300},
+			} {
+				p.Gauge("cgroup_pressure_percent", "Share of time tasks stalled on the resource (PSI).", v.value, with("resource", r.name, "kind", v.kind, "window", v.window)...)
+			}
+		}
+	}
+
+	for _, c := range info.Checks {
+		labels := with("check", c.Name, "type", c.Type, "target", c.Target)
+		success := 0.0
+		if c.Success {
+			success = 1
+		}
+		p.Gauge("check_success", "Whether the last agent check succeeded.", success, labels...)
+		p.Gauge("check_latency_seconds", "Latency of the last agent check, average round trip for ping.", c.LatencyMs/1000, labels...)
+		if c.Type == "ping" {
+			p.Gauge("check_packet_loss_percent", "Packet loss of the last ping check.", c.PacketLoss, labels...)
+		}
+		if c.StatusCode > 0 {
+			p.Gauge("check_http_status_code", "HTTP status code of the last check.", float64(c.StatusCode), labels...)
+		}
+		if c.CertExpiry != nil {
+			p.Gauge("check_cert_expiry_timestamp_seconds", "Expiry time of the server certificate.", float64(c.CertExpiry.Unix()), labels...)
+			p.Gauge("check_cert_days_left", "Days until the server certificate expires.", c.CertDaysLeft, labels...)
+		}
+	}
+
+	for _, plugin := range info.Custom {
+		up := 1.0
+		if plugin.Error != "" {
+			up = 0
+		}
+		p.Gauge("plugin_up", "Whether the last run of the custom metric plugin succeeded.", up, with("plugin", plugin.Name)...)
+		p.Gauge("plugin_duration_seconds", "Run time of the last plugin execution.", plugin.DurationMs/1000, with("plugin", plugin.Name)...)
+		for _, m := range plugin.Metrics {
+			labels := CustomLabels(with("plugin", plugin.Name), m.Labels)
+			if m.Type == "counter" {
+				p.Counter("custom_"+MetricName(m.Name), "Custom metric reported by an agent plugin.", m.Value, labels...)
+			} else {
+				p.Gauge("custom_"+MetricName(m.Name), "Custom metric reported by an agent plugin.", m.Value, labels...)
+			}
+		}
+	}
+
+	for _, l := range info.Logs {
+		up := 1.0
+		if l.Error != "" {
+			up = 0
+		}
+		p.Gauge("log_up", "Whether the log file could be read during the last interval.", up, with("log", l.Name, "path", l.Path)...)
+		for _, pt := range l.Patterns {
+			p.Counter("log_matches_total", "Log lines matching the pattern since the agent started.", float64(pt.Total), with("log", l.Name, "path", l.Path, "pattern", pt.Name)...)
+		}
+	}
+
+	p.Gauge("disk_total_bytes", "Total disk size in bytes.", float64(info.Disk.Total), base...)
+	p.Gauge("disk_used_bytes", "Used disk space in bytes.", float64(info.Disk.Used), base...)
+	p.Gauge("disk_usage_percent", "Disk usage in percent.", info.Disk.UsagePercent, base...)
+
+	for _, d := range info.Disks {
+		labels := with("mountpoint", d.Mountpoint, "device", d.Device, "fstype", d.Fstype)
+		p.Gauge("filesystem_size_bytes", "Filesystem size in bytes.", float64(d.Total), labels...)
+		p.Gauge("filesystem_used_bytes", "Filesystem space used in bytes.", float64(d.Used), labels...)
+		p.Gauge("filesystem_free_bytes", "Filesystem space free in bytes.", float64(d.Free), labels...)
+		p.Gauge("filesystem_usage_percent", "Filesystem usage in percent.", d.UsagePercent, labels...)
+		p.Gauge("filesystem_inodes_total", "Total inodes.", float64(d.InodesTotal), labels...)
+		p.Gauge("filesystem_inodes_used", "Used inodes.", float64(d.InodesUsed), labels...)
+		p.Gauge("filesystem_inodes_usage_percent", "Inode usage in percent.", d.InodesUsagePercent, labels...)
+	}
+
+	for _, d := range info.DiskIO {
+		labels := with("device", d.Name)
+		p.Counter("disk_read_bytes_total", "Bytes read per device.", float64(d.ReadBytes), labels...)
+		p.Counter("disk_written_bytes_total", "Bytes written per device.", float64(d.WriteBytes), labels...)
+		p.Counter("disk_reads_completed_total", "Reads completed per device.", float64(d.ReadCount), labels...)
+		p.Counter("disk_writes_completed_total", "Writes completed per device.", float64(d.WriteCount), labels...)
+		p.Gauge("disk_read_speed_kbytes", "Read rate per device in KB/s.", d.ReadSpeed, labels...)
+		p.Gauge("disk_write_speed_kbytes", "Write rate per device in KB/s.", d.WriteSpeed, labels...)
+		p.Gauge("disk_read_iops", "Reads per second per device.", d.ReadIOPS, labels...)
+		p.Gauge("disk_write_iops", "Writes per second per device.", d.WriteIOPS, labels...)
+		p.Gauge("disk_await_milliseconds", "Average time per I/O in milliseconds.", d.Await, labels...)
+		p.Gauge("disk_util_percent", "Percentage of time the device was busy.", d.UtilPercent, labels...)
+	}
+
+	p.Counter("network_sent_bytes_total", "Bytes sent over all interfaces.", float64(info.Network.BytesSent), base...)
+	p.Counter("network_received_bytes_total", "Bytes received over all interfaces.", float64(info.Network.BytesRecv), base...)
+	p.Counter("network_sent_packets_total", "Packets sent over all interfaces.", float64(info.Network.PacketsSent), base...)
+	p.Counter("network_received_packets_total", "Packets received over all interfaces.", float64(info.Network.PacketsRecv), base...)
+	p.Gauge("network_send_speed_kbytes", "Send rate in KB/s.", info.Network.SpeedSent, base...)
+	p.Gauge("network_receive_speed_kbytes", "Receive rate in KB/s.", info.Network.SpeedRecv, base...)
+
+	for _, iface := range info.Network.Interfaces {
+		labels := with("interface", iface.Name)
+		isUp := 0.0
+		if iface.IsUp {
+			isUp = 1
+		}
+		p.Gauge("network_interface_up", "Whether the interface is up.", isUp, labels...)
+		p.Counter("network_interface_sent_bytes_total", "Bytes sent per interface.", float64(iface.BytesSent), labels...)
+		p.Counter("network_interface_received_bytes_total", "Bytes received per interface.", float64(iface.BytesRecv), labels...)
+		p.Counter("network_interface_sent_packets_total", "Packets sent per interface.", float64(iface.PacketsSent), labels...)
+		p.Counter("network_interface_received_packets_total", "Packets received per interface.", float64(iface.PacketsRecv), labels...)
+		p.Gauge("network_interface_send_speed_kbytes", "Send rate per interface in KB/s.", iface.SpeedSent, labels...)
+		p.Gauge("network_interface_receive_speed_kbytes", "Receive rate per interface in KB/s.", iface.SpeedRecv, labels...)
+		p.Counter("network_interface_receive_errors_total", "Receive errors per interface.", float64(iface.ErrIn), labels...)
+		p.Counter("network_interface_transmit_errors_total", "Transmit errors per interface.", float64(iface.ErrOut), labels...)
+		p.Counter("network_interface_receive_drops_total", "Dropped received packets per interface.", float64(iface.DropIn), labels...)
+		p.Counter("network_interface_transmit_drops_total", "Dropped packets on transmit per interface.", float64(iface.DropOut), labels...)
+		if iface.Kind != "" {
+			p.Gauge("network_interface_link_speed_mbps", "Negotiated link speed in Mbit/s, 0 if unknown.", float64(iface.LinkSpeed), labels...)
+			p.Gauge("network_interface_info", "Interface type and link state, always 1.", 1,
+				with("interface", iface.Name, "kind", iface.Kind, "oper_state", iface.OperState, "duplex", iface.Duplex)...)
+		}
+	}
+
+	if sk := info.Network.Sockets; sk != nil {
+		for _, st := range []struct {
+			state string
+			count int
+		}{
+			{"established", sk.Established},
+			{"syn_sent", sk.SynSent},
+			{"syn_recv", sk.SynRecv},
+			{"fin_wait", sk.FinWait},
+			{"time_wait", sk.TimeWait},
+			{"close_wait", sk.CloseWait},
+			{"last_ack", sk.LastAck},
+			{"listen", sk.Listen},
+		} {
+			p.Gauge("tcp_connections", "TCP sockets by state.", float64(st.count), with("state", st.state)...)
+		}
+		p.Gauge("tcp_sockets", "Total TCP sockets in any state.", float64(sk.TCP), base...)
+		p.Gauge("udp_sockets", "Open UDP sockets.", float64(sk.UDP), base...)
+	}
+	for _, lp := range info.Network.Listening {
+		p.Gauge("listening_port_info", "Listening TCP port with its owning process, always 1.", 1,
+			with("protocol", lp.Protocol, "address", lp.Address, "port", strconv.Itoa(lp.Port), "process", lp.Process)...)
+	}
+	if ct := info.Network.Conntrack; ct != nil {
+		p.Gauge("conntrack_entries", "Entries in the netfilter connection tracking table.", float64(ct.Count), base...)
+		p.Gauge("conntrack_entries_limit", "Size limit of the connection tracking table.", float64(ct.Max), base...)
+	}
+
+	for i, gpu := range info.GPUs {
+		labels := with("gpu_index", strconv.Itoa(i), "gpu_name", gpu.Name)
+		p.Gauge("gpu_usage_percent", "GPU utilization in percent.", gpu.UsagePercent, labels...)
+		p.Gauge("gpu_memory_total_bytes", "GPU memory size in bytes.", float64(gpu.MemoryTotal), labels...)
+		p.Gauge("gpu_memory_used_bytes", "GPU memory used in bytes.", float64(gpu.MemoryUsed), labels...)
+		p.Gauge("gpu_temperature_celsius", "GPU temperature in Celsius.", gpu.Temperature, labels...)
+	}
+
+	p.Gauge("cpu_temperature_celsius", "CPU temperature in Celsius.", info.Temperature.CPUTemp, base...)
+	p.Gauge("max_temperature_celsius", "Highest sensor temperature in Celsius.", info.Temperature.MaxTemp, base...)
+	p.Gauge("avg_temperature_celsius", "Average sensor temperature in Celsius.", info.Temperature.AvgTemp, base...)
+	sensors := make([]string, 0, len(info.Temperature.Sensors))
+	for sensor := range info.Temperature.Sensors {
+		sensors = append(sensors, sensor)
+	}
+	sort.Strings(sensors)
+	for _, sensor := range sensors {
+		p.Gauge("sensor_temperature_celsius", "Temperature per sensor in Celsius.", info.Temperature.Sensors[sensor], with("sensor", sensor)...)
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bufio"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 采集视图
+const (
+	viewAuto      = "auto"      // 在容器中且为cgroup v2时使用容器视图，否则使用主机视图
+	viewHost      = "host"      // 主机视图，可通过挂载的宿主机 /proc 采集
+	viewContainer = "container" // 容器视图，CPU和内存按cgroup限制计算
+
+	defaultHostProc = "/host/proc"
+	cgroupRoot      = "/sys/fs/cgroup"
+)
+
+// cgroupCounters cgroup的累计计数，用于与下次采集做差
+type cgroupCounters struct {
+	usageUsec   uint64
+	nrPeriods   uint64
+	nrThrottled uint64
+	time        time.Time
+}
+
+// cgroupDir 容器视图下当前进程的cgroup目录，为空时不采集cgroup
+var cgroupDir string
+
+// setupView 根据配置选择主机视图或容器视图，启动时调用一次
+func setupView() {
+	view := config.View
+	if view == "" {
+		view = viewAuto
+	}
+
+	switch view {
+	case viewHost:
+		setupHostProc()
+	case viewAuto, viewContainer:
+		dir, ok := detectCgroupV2()
+		if !ok {
+			if view == viewContainer {
+				log.Println("未检测到cgroup v2，使用主机视图 | cgroup v2 not detected, using host view")
+			}
+			return
+		}
+		if view == viewAuto && !inContainer() {
+			return
+		}
+		cgroupDir = dir
+		log.Printf("容器视图，cgroup: %s | Container view, cgroup: %s", dir, dir)
+	default:
+		log.Printf("未知的采集视图 %q，使用主机视图 | Unknown view %q, using host view", view, view)
+	}
+}
+
+// setupHostProc 宿主机的 /proc（以及同级的 sys、etc）挂载到容器中时，让gopsutil从挂载点读取
+func setupHostProc() {
+	hostProcDir := config.HostProc
+	if hostProcDir == "" {
+		hostProcDir = defaultHostProc
+	}
+	if _, err := os.Stat(filepath.Join(hostProcDir, "stat")); err != nil {
+		log.Printf("宿主机proc未挂载到 %s，使用当前 /proc | Host proc not mounted at %s, using /proc", hostProcDir, hostProcDir)
+		return
+	}
+
+	hostRoot := filepath.Dir(hostProcDir)
+	envs := map[string]string{
+		"HOST_PROC": hostProcDir,
+		"HOST_SYS":  filepath.Join(hostRoot, "sys"),
+		"HOST_ETC":  filepath.Join(hostRoot, "etc"),
+	}
+	for env, dir := range envs {
+		if os.Getenv(env) != "" {
+			continue // 环境变量优先
+		}
+		if _, err := os.Stat(dir); err == nil {
+			os.Setenv(env, dir)
+		}
+	}
+	log.Printf("主机视图，proc: %s | Host view, proc: %s", os.Getenv("HOST_PROC"), os.Getenv("HOST_PROC"))
+}
+
+// detectCgroupV2 判断是否为cgroup v2（unified），返回当前进程所在的cgroup目录
+func detectCgroupV2() (string, bool) {
+	if runtime.GOOS != "linux" {
+		return "", false
+	}
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return "", false
+	}
+
+	// 有独立cgroup命名空间时为 "0::/"，否则为宿主机上的完整路径
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if path, ok := strings.CutPrefix(line, "0::"); ok {
+				dir := filepath.Join(cgroupRoot, path)
+				if _, err := os.Stat(filepath.Join(dir, "cpu.stat")); err == nil {
+					return dir, true
+				}
+			}
+		}
+	}
+	return cgroupRoot, true
+}
+
+// inContainer 通过容器运行时留下的标记判断是否运行在容器中
+func inContainer() bool {
+	for _, marker := range []string{"/.dockerenv", "/run/.containerenv"} {
+		if _, err := os.Stat(marker); err == nil {
+			return true
+		}
+	}
+	return os.Getenv("container") != "" || os.Getenv("KUBERNETES_SERVICE_HOST") != ""
+}
+
+// collectCgroup 读取cgroup v2的CPU配额、限流、内存和PSI，使用率和限流占比按与上次采集的差值计算
+func collectCgroup() *CgroupInfo {
+	if cgroupDir == "" {
+		return nil
+	}
+	cg := &CgroupInfo{}
+
+	cg.CPULimit = cgroupCPULimit()
+	cpuStat := readCgroupKV("cpu.stat")
+	cg.CPUUsageSeconds = float64(cpuStat["usage_usec"]) / 1e6
+	cg.NrPeriods = cpuStat["nr_periods"]
+	cg.NrThrottled = cpuStat["nr_throttled"]
+	cg.ThrottledSeconds = float64(cpuStat["throttled_usec"]) / 1e6
+
+	current := &cgroupCounters{
+		usageUsec:   cpuStat["usage_usec"],
+		nrPeriods:   cg.NrPeriods,
+		nrThrottled: cg.NrThrottled,
+		time:        time.Now(),
+	}
+	if last := lastCgroupCounters; last != nil {
+		cores := cg.CPULimit
+		if cores == 0 {
+			cores = float64(runtime.NumCPU())
+		}
+		elapsed := float64(current.time.Sub(last.time).Microseconds())
+		if elapsed > 0 && current.usageUsec >= last.usageUsec {
+			cg.CPUUsagePercent = math.Min(float64(current.usageUsec-last.usageUsec)/(elapsed*cores)*100, 100)
+		}
+		if current.nrPeriods > last.nrPeriods && current.nrThrottled >= last.nrThrottled {
+			cg.ThrottledPercent = float64(current.nrThrottled-last.nrThrottled) / float64(current.nrPeriods-last.nrPeriods) * 100
+		}
+	}
+	lastCgroupCounters = current
+
+	// 内存使用与 docker stats 一致，不含可回收的inactive_file
+	memStat := readCgroupKV("memory.stat")
+	usage := readCgroupUint("memory.current")
+	if inactive := memStat["inactive_file"]; inactive < usage {
+		usage -= inactive
+	}
+	cg.MemoryUsage = usage
+	cg.MemoryCache = memStat["file"]
+	cg.MemoryLimit = readCgroupUint("memory.max") // "max" 解析为0
+	if cg.MemoryLimit > 0 {
+		cg.MemoryPercent = float64(cg.MemoryUsage) / float64(cg.MemoryLimit) * 100
+	}
+	cg.SwapUsage = readCgroupUint("memory.swap.current")
+	cg.OOMKills = readCgroupKV("memory.events")["oom_kill"]
+
+	cg.CPUPressure = readPressure("cpu.pressure")
+	cg.MemoryPressure = readPressure("memory.pressure")
+	cg.IOPressure = readPressure("io.pressure")
+	return cg
+}
+
+// applyContainerView 用cgroup限制替换主机的CPU和内存数据
+func applyContainerView(info *SystemInfo, cg *CgroupInfo) {
+	info.CPU.UsagePercent = cg.CPUUsagePercent
+	if cg.CPULimit > 0 {
+		info.CPU.CoreCount = int(math.Ceil(cg.CPULimit))
+	}
+
+	if cg.MemoryLimit > 0 && cg.MemoryLimit < info.Memory.Total {
+		info.Memory.Total = cg.MemoryLimit
+		info.Memory.Used = cg.MemoryUsage
+		info.Memory.Cached = cg.MemoryCache
+		info.Memory.Buffers = 0
+		info.Memory.Available = 0
+		if cg.MemoryUsage < cg.MemoryLimit {
+			info.Memory.Available = cg.MemoryLimit - cg.MemoryUsage
+		}
+		info.Memory.Free = info.Memory.Available
+		info.Memory.UsagePercent = cg.MemoryPercent
+	}
+}
+
+// cgroupCPULimit 可用核数，取cpu.max配额和cpuset中较小的值，0为不限制
+func cgroupCPULimit() float64 {
+	var limit float64
+	if data, err := os.ReadFile(filepath.Join(cgroupDir, "cpu.max")); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) == 2 && fields[0] != "max" {
+			quota, err1 := strconv.ParseFloat(fields[0], 64)
+			period, err2 := strconv.ParseFloat(fields[1], 64)
+			if err1 == nil && err2 == nil && period > 0 {
+				limit = quota / period
+			}
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(cgroupDir, "cpuset.cpus.effective")); err == nil {
+		if n := countCPUList(strings.TrimSpace(string(data))); n > 0 && n < runtime.NumCPU() && (limit == 0 || float64(n) < limit) {
+			limit = float64(n)
+		}
+	}
+	return limit
+}
+
+// countCPUList 统计 "0-3,6" 格式的CPU列表中的核数
+func countCPUList(list string) int {
+	count := 0
+	for _, part := range strings.Split(list, ",") {
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(lo)
+		if err != nil {
+			return 0
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(hi); err != nil {
+				return 0
+			}
+		}
+		count += end - start + 1
+	}
+	return count
+}
+
+// readCgroupKV 读取 "key value" 格式的cgroup文件
+func readCgroupKV(name string) map[string]uint64 {
+	values := make(map[string]uint64)
+	f, err := os.Open(filepath.Join(cgroupDir, name))
+	if err != nil {
+		return values
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = v
+		}
+	}
+	return values
+}
+
+// readCgroupUint 读取单个数值的cgroup文件，"max" 和读取失败均返回0
+func readCgroupUint(name string) uint64 {
+	data, err := os.ReadFile(filepath.Join(cgroupDir, name))
+	if err != nil {
+		return 0
+	}
+	v, _ := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	return v
+}
+
+// readPressure 解析PSI文件，如 "some avg10=0.00 avg60=0.00 avg300=0.00 total=0"
+func readPressure(name string) *PressureStat {
+	data, err := os.ReadFile(filepath.Join(cgroupDir, name))
+	if err != nil {
+		return nil
+	}
+	stat := &PressureStat{}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		values := make(map[string]float64)
+		for _, field := range fields[1:] {
+			if key, value, ok := strings.Cut(field, "="); ok {
+				values[key], _ = strconv.ParseFloat(value, 64)
+			}
+		}
+		switch fields[0] {
+		case "some":
+			stat.SomeAvg10, stat.SomeAvg60, stat.SomeAvg300 = values["avg10"], values["avg60"], values["avg300"]
+		case "full":
+			stat.FullAvg10, stat.FullAvg60, stat.FullAvg300 = values["avg10"], values["avg60"], values["avg300"]
+		}
+	}
+	return stat
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 检查类型
+const (
+	checkPing = "ping"
+	checkTCP  = "tcp"
+	checkHTTP = "http"
+	checkTLS  = "tls"
+)
+
+const (
+	defaultCheckInterval = 30 // 秒
+	defaultCheckTimeout  = 5  // 秒
+	defaultPingCount     = 3
+	defaultMinCertDays   = 14      // tls检查的证书剩余天数下限
+	maxCheckBodySize     = 1 << 20 // body_match最多读取的响应内容
+)
+
+// CheckConfig 代理侧主动检查，每个检查按自己的间隔在后台运行，上报时附带最近一次结果
+type CheckConfig struct {
+	Name         string `json:"name"`                    // 显示名称，默认为 type:target
+	Type         string `json:"type"`                    // ping / tcp / http / tls
+	Target       string `json:"target"`                  // ping为主机名或IP，tcp和tls为 host:port（tls默认443端口），http为URL
+	Interval     int    `json:"interval,omitempty"`      // 检查间隔（秒），默认30
+	Timeout      int    `json:"timeout,omitempty"`       // 超时（秒），默认5
+	Count        int    `json:"count,omitempty"`         // ping发送的包数，默认3
+	Method       string `json:"method,omitempty"`        // HTTP方法，默认GET
+	ExpectStatus []int  `json:"expect_status,omitempty"` // 期望的HTTP状态码，默认200-399
+	BodyMatch    string `json:"body_match,omitempty"`    // 响应内容需匹配的正则表达式
+	MinCertDays  int    `json:"min_cert_days,omitempty"` // 证书剩余天数少于该值视为失败，tls默认14，http默认不检查
+	Insecure     bool   `json:"insecure,omitempty"`      // 不校验证书链和主机名
+	Task         string `json:"task,omitempty"`          // 服务器下发的探测任务名，仅由服务器填写
+	Peer         string `json:"peer,omitempty"`          // 网格探测的对端服务器，仅由服务器填写
+}
+
+// checker 编译后的检查
+type checker struct {
+	CheckConfig
+	bodyRe *regexp.Regexp
+	client *http.Client
+	stop   chan struct{} // 服务器下发的探测任务被移除或修改时关闭，本地检查为nil
+}
+
+var (
+	checkResults   = make(map[string]CheckResult)
+	checkResultsMu sync.Mutex
+	checkers       []*checker
+
+	// 服务器下发的探测任务，受checkResultsMu保护
+	probeCheckers = make(map[string]*checker)
+	probeRejected = make(map[string]bool) // 已记录过日志的无效任务，避免每次上报重复输出
+)
+
+// startChecks 校验配置并为每个检查启动后台任务，启动时调用一次
+func startChecks() {
+	seen := make(map[string]bool)
+	for _, cfg := range config.Checks {
+		c, err := newChecker(cfg)
+		if err != nil {
+			log.Printf("检查 %q 配置无效，已忽略 | Invalid check %q ignored: %v", cfg.Name, cfg.Name, err)
+			continue
+		}
+		if seen[c.Name] {
+			log.Printf("检查名称 %q 重复，已忽略 | Duplicate check name %q ignored", c.Name, c.Name)
+			continue
+		}
+		seen[c.Name] = true
+		checkers = append(checkers, c)
+		go c.run()
+	}
+	if len(checkers) > 0 {
+		log.Printf("已启动 %d 个检查 | Started %d checks", len(checkers), len(checkers))
+	}
+}
+
+func newChecker(cfg CheckConfig) (*checker, error) {
+	cfg.Type = strings.ToLower(cfg.Type)
+	switch cfg.Type {
+	case checkPing, checkTCP, checkHTTP, checkTLS:
+	default:
+		return nil, fmt.Errorf("未知的检查类型 %q", cfg.Type)
+	}
+	if cfg.Target == "" {
+		return nil, fmt.Errorf("缺少target")
+	}
+	if cfg.Name == "" {
+		cfg.Name = cfg.Type + ":" + cfg.Target
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultCheckInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultCheckTimeout
+	}
+	if cfg.Count <= 0 {
+		cfg.Count = defaultPingCount
+	}
+	if cfg.Type == checkTLS && cfg.MinCertDays == 0 {
+		cfg.MinCertDays = defaultMinCertDays
+	}
+	if cfg.Type == checkTLS {
+		if _, _, err := net.SplitHostPort(cfg.Target); err != nil {
+			cfg.Target = net.JoinHostPort(cfg.Target, "443")
+		}
+	}
+
+	c := &checker{CheckConfig: cfg}
+	if cfg.BodyMatch != "" {
+		re, err := regexp.Compile(cfg.BodyMatch)
+		if err != nil {
+			return nil, fmt.Errorf("body_match无效: %v", err)
+		}
+		c.bodyRe = re
+	}
+	if cfg.Type == checkHTTP {
+		// 不复用连接，每次检查都包含建立连接和TLS握手的耗时
+		c.client = &http.Client{
+			Timeout: c.timeout(),
+			Transport: &http.Transport{
+				Proxy:             http.ProxyFromEnvironment,
+				DisableKeepAlives: true,
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: cfg.Insecure},
+			},
+		}
+	}
+	return c, nil
+}
+
+func (c *checker) timeout() time.Duration {
+	return time.Duration(c.Timeout) * time.Second
+}
+
+func (c *checker) run() {
+	ticker := time.NewTicker(time.Duration(c.Interval) * time.Second)
+	defer ticker.Stop()
+	for {
+		result := c.check()
+
+		checkResultsMu.Lock()
+		if c.stopped() {
+			checkResultsMu.Unlock()
+			return
+		}
+		if !result.Success {
+			result.Failures = checkResults[c.Name].Failures + 1
+		}
+		checkResults[c.Name] = result
+		checkResultsMu.Unlock()
+
+		select {
+		case <-ticker.C:
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+func (c *checker) stopped() bool {
+	select {
+	case <-c.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// syncProbes 按服务器下发的列表启动、重启或停止探测任务，配置未变的任务继续运行
+// 与本地检查重名的任务被忽略
+func syncProbes(probes []CheckConfig) {
+	checkResultsMu.Lock()
+	defer checkResultsMu.Unlock()
+
+	local := make(map[string]bool, len(checkers))
+	for _, c := range checkers {
+		local[c.Name] = true
+	}
+	wanted := make(map[string]*checker, len(probes))
+	for _, cfg := range probes {
+		c, err := newChecker(cfg)
+		if err == nil && (local[c.Name] || wanted[c.Name] != nil) {
+			err = fmt.Errorf("名称重复")
+		}
+		if err != nil {
+			if !probeRejected[cfg.Name] {
+				probeRejected[cfg.Name] = true
+				log.Printf("探测任务 %q 无效，已忽略 | Invalid probe %q ignored: %v", cfg.Name, cfg.Name, err)
+			}
+			continue
+		}
+		wanted[c.Name] = c
+	}
+
+	changed := false
+	for name, old := range probeCheckers {
+		if c := wanted[name]; c != nil && reflect.DeepEqual(c.CheckConfig, old.CheckConfig) {
+			wanted[name] = old
+			continue
+		}
+		close(old.stop)
+		delete(checkResults, name)
+		changed = true
+	}
+	for name, c := range wanted {
+		if probeCheckers[name] == c {
+			continue
+		}
+		c.stop = make(chan struct{})
+		go c.run()
+		changed = true
+	}
+	probeCheckers = wanted
+	if changed {
+		log.Printf("探测任务已更新: %d 个 | Probes updated: %d", len(wanted), len(wanted))
+	}
+}
+
+// check 执行一次检查
+func (c *checker) check() CheckResult {
+	result := CheckResult{
+		Name:      c.Name,
+		Type:      c.Type,
+		Target:    c.Target,
+		Task:      c.Task,
+		Peer:      c.Peer,
+		CheckedAt: time.Now(),
+	}
+
+	var err error
+	switch c.Type {
+	case checkPing:
+		var avg time.Duration
+		avg, result.PacketLoss, err = pingHost(c.Target, c.Count, c.timeout())
+		result.LatencyMs = durationMs(avg)
+	case checkTCP:
+		start := time.Now()
+		var conn net.Conn
+		if conn, err = net.DialTimeout("tcp", c.Target, c.timeout()); err == nil {
+			result.LatencyMs = durationMs(time.Since(start))
+			conn.Close()
+		}
+	case checkTLS:
+		err = c.checkTLS(&result)
+	case checkHTTP:
+		err = c.checkHTTP(&result)
+	}
+
+	if err == nil && c.MinCertDays > 0 && result.CertExpiry != nil && result.CertDaysLeft < float64(c.MinCertDays) {
+		err = fmt.Errorf("证书将在 %.1f 天后过期（%s）", result.CertDaysLeft, result.CertExpiry.Format("2006-01-02"))
+	}
+	result.Success = err == nil
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func (c *checker) checkTLS(result *CheckResult) error {
+	host, _, _ := net.SplitHostPort(c.Target)
+	dialer := &net.Dialer{Timeout: c.timeout()}
+	start := time.Now()
+	conn, err := tls.DialWithDialer(dialer, "tcp", c.Target, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: c.Insecure,
+	})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	result.LatencyMs = durationMs(time.Since(start))
+	setCertExpiry(result, conn.ConnectionState())
+	return nil
+}
+
+func (c *checker) checkHTTP(result *CheckResult) error {
+	method := c.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequest(method, c.Target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "ServerStatus-Monitor-Agent")
+
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCheckBodySize))
+	result.LatencyMs = durationMs(time.Since(start))
+	result.StatusCode = resp.StatusCode
+	if resp.TLS != nil {
+		setCertExpiry(result, *resp.TLS)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !c.statusOK(resp.StatusCode) {
+		return fmt.Errorf("HTTP状态码 %d", resp.StatusCode)
+	}
+	if c.bodyRe != nil && !c.bodyRe.Match(body) {
+		return fmt.Errorf("响应内容不匹配 %q", c.BodyMatch)
+	}
+	return nil
+}
+
+func (c *checker) statusOK(code int) bool {
+	if len(c.ExpectStatus) == 0 {
+		return code >= 200 && code < 400
+	}
+	for _, expected := range c.ExpectStatus {
+		if code == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// setCertExpiry 记录服务器证书（证书链第一个）的过期时间
+func setCertExpiry(result *CheckResult, state tls.ConnectionState) {
+	if len(state.PeerCertificates) == 0 {
+		return
+	}
+	expiry := state.PeerCertificates[0].NotAfter
+	result.CertExpiry = &expiry
+	result.CertDaysLeft = time.Until(expiry).Hours() / 24
+}
+
+// collectChecks 返回各检查最近一次的结果，本地检查按配置顺序在前，探测任务按名称排序在后
+// 尚未完成首次检查的不返回
+func collectChecks() []CheckResult {
+	checkResultsMu.Lock()
+	defer checkResultsMu.Unlock()
+	if len(checkers) == 0 && len(probeCheckers) == 0 {
+		return nil
+	}
+	results := make([]CheckResult, 0, len(checkers)+len(probeCheckers))
+	for _, c := range checkers {
+		if result, ok := checkResults[c.Name]; ok {
+			results = append(results, result)
+		}
+	}
+	names := make([]string, 0, len(probeCheckers))
+	for name := range probeCheckers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if result, ok := checkResults[name]; ok {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
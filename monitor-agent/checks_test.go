@@ -0,0 +1,224 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func mustChecker(t *testing.T, cfg CheckConfig) *checker {
+	t.Helper()
+	c, err := newChecker(cfg)
+	if err != nil {
+		t.Fatalf("newChecker(%+v): %v", cfg, err)
+	}
+	return c
+}
+
+func TestHTTPCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `{"status": "ok"}`)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		cfg     CheckConfig
+		success bool
+		status  int
+		err     string
+	}{
+		{"ok", CheckConfig{Type: "http", Target: srv.URL + "/health"}, true, 200, ""},
+		{"body match", CheckConfig{Type: "http", Target: srv.URL + "/health", BodyMatch: `"status": "ok"`}, true, 200, ""},
+		{"body mismatch", CheckConfig{Type: "http", Target: srv.URL + "/health", BodyMatch: "healthy"}, false, 200, "响应内容不匹配"},
+		{"bad status", CheckConfig{Type: "http", Target: srv.URL + "/down"}, false, 503, "HTTP状态码 503"},
+		{"expected status", CheckConfig{Type: "http", Target: srv.URL + "/down", ExpectStatus: []int{503}}, true, 503, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := mustChecker(t, tt.cfg).check()
+			if result.Success != tt.success || result.StatusCode != tt.status {
+				t.Fatalf("result = %+v, want success %v status %d", result, tt.success, tt.status)
+			}
+			if tt.err != "" && !strings.Contains(result.Error, tt.err) {
+				t.Errorf("error = %q, want %q", result.Error, tt.err)
+			}
+			if result.LatencyMs <= 0 {
+				t.Errorf("latency = %v", result.LatencyMs)
+			}
+		})
+	}
+}
+
+func TestHTTPSCheckCertificate(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	// 测试证书不受信任
+	if result := mustChecker(t, CheckConfig{Type: "http", Target: srv.URL}).check(); result.Success {
+		t.Errorf("untrusted certificate accepted: %+v", result)
+	}
+
+	result := mustChecker(t, CheckConfig{Type: "http", Target: srv.URL, Insecure: true}).check()
+	if !result.Success || result.CertExpiry == nil || result.CertDaysLeft <= 0 {
+		t.Fatalf("result = %+v, want success with certificate expiry", result)
+	}
+	if !result.CertExpiry.Equal(srv.Certificate().NotAfter) {
+		t.Errorf("cert expiry = %v, want %v", result.CertExpiry, srv.Certificate().NotAfter)
+	}
+
+	// 剩余天数低于下限视为失败
+	minDays := int(result.CertDaysLeft) + 1
+	result = mustChecker(t, CheckConfig{Type: "http", Target: srv.URL, Insecure: true, MinCertDays: minDays}).check()
+	if result.Success || !strings.Contains(result.Error, "证书将在") {
+		t.Errorf("result = %+v, want certificate expiry failure", result)
+	}
+}
+
+func TestTLSCheck(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	addr := srv.Listener.Addr().String()
+
+	result := mustChecker(t, CheckConfig{Type: "tls", Target: addr, Insecure: true}).check()
+	if !result.Success || result.CertExpiry == nil {
+		t.Fatalf("result = %+v, want success with certificate expiry", result)
+	}
+	if result.Target != addr {
+		t.Errorf("target = %q, want %q", result.Target, addr)
+	}
+
+	if result := mustChecker(t, CheckConfig{Type: "tls", Target: addr}).check(); result.Success {
+		t.Errorf("untrusted certificate accepted: %+v", result)
+	}
+}
+
+func TestTCPCheck(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	open := ln.Addr().String()
+
+	result := mustChecker(t, CheckConfig{Type: "tcp", Target: open}).check()
+	if !result.Success || result.Name != "tcp:"+open {
+		t.Fatalf("result = %+v, want success", result)
+	}
+
+	// 关闭监听后同一端口连接被拒绝
+	ln.Close()
+	result = mustChecker(t, CheckConfig{Type: "tcp", Target: open, Timeout: 1}).check()
+	if result.Success || result.Error == "" {
+		t.Errorf("result = %+v, want connection failure", result)
+	}
+}
+
+func TestNewCheckerDefaults(t *testing.T) {
+	c := mustChecker(t, CheckConfig{Type: "TLS", Target: "example.com"})
+	if c.Type != checkTLS || c.Target != "example.com:443" || c.MinCertDays != defaultMinCertDays {
+		t.Errorf("checker = %+v", c.CheckConfig)
+	}
+	if c.Interval != defaultCheckInterval || c.Timeout != defaultCheckTimeout {
+		t.Errorf("interval/timeout = %d/%d", c.Interval, c.Timeout)
+	}
+	for _, cfg := range []CheckConfig{{Type: "dns", Target: "example.com"}, {Type: "tcp"}, {Type: "http", Target: "http://x", BodyMatch: "("}} {
+		if _, err := newChecker(cfg); err == nil {
+			t.Errorf("newChecker(%+v) accepted invalid config", cfg)
+		}
+	}
+}
+
+func TestSyncProbes(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	addr := ln.Addr().String()
+
+	savedCheckers := checkers
+	checkers = []*checker{mustChecker(t, CheckConfig{Name: "local", Type: "tcp", Target: addr})}
+	t.Cleanup(func() {
+		syncProbes(nil)
+		checkers = savedCheckers
+	})
+
+	probe := func(name string, interval int) CheckConfig {
+		return CheckConfig{Name: name, Type: "tcp", Target: addr, Interval: interval, Task: name}
+	}
+	running := func() map[string]*checker {
+		checkResultsMu.Lock()
+		defer checkResultsMu.Unlock()
+		current := make(map[string]*checker, len(probeCheckers))
+		for name, c := range probeCheckers {
+			current[name] = c
+		}
+		return current
+	}
+
+	syncProbes([]CheckConfig{probe("api", 60), probe("db", 60), probe("local", 60), probe("api", 30), {Name: "bad", Type: "dns"}})
+	first := running()
+	if len(first) != 2 || first["api"] == nil || first["db"] == nil {
+		t.Fatalf("probes = %v, want api and db (local clash, duplicate and invalid ignored)", first)
+	}
+	if first["api"].Interval != 60 {
+		t.Errorf("api interval = %d, want the first definition", first["api"].Interval)
+	}
+
+	tests := []struct {
+		name    string
+		probes  []CheckConfig
+		kept    []string // 继续运行的任务
+		started []string // 重新启动的任务
+		stopped []string
+	}{
+		{"unchanged", []CheckConfig{probe("api", 60), probe("db", 60)}, []string{"api", "db"}, nil, nil},
+		{"changed interval", []CheckConfig{probe("api", 30), probe("db", 60)}, []string{"db"}, []string{"api"}, nil},
+		{"removed", []CheckConfig{probe("api", 30)}, []string{"api"}, nil, []string{"db"}},
+		{"all removed", nil, nil, nil, []string{"api"}},
+	}
+	for _, tt := range tests {
+		before := running()
+		syncProbes(tt.probes)
+		after := running()
+		for _, name := range tt.kept {
+			if after[name] == nil || after[name] != before[name] {
+				t.Errorf("%s: %s restarted", tt.name, name)
+			}
+		}
+		for _, name := range tt.started {
+			if after[name] == nil || after[name] == before[name] || !before[name].stopped() {
+				t.Errorf("%s: %s not restarted", tt.name, name)
+			}
+		}
+		for _, name := range tt.stopped {
+			checkResultsMu.Lock()
+			_, hasResult := checkResults[name]
+			checkResultsMu.Unlock()
+			if after[name] != nil || !before[name].stopped() || hasResult {
+				t.Errorf("%s: %s not stopped", tt.name, name)
+			}
+		}
+		if len(after) != len(tt.kept)+len(tt.started) {
+			t.Errorf("%s: probes = %v", tt.name, after)
+		}
+	}
+}
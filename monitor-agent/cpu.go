@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/load"
+)
+
+// cpuSnapshot 一次CPU计数采样，用于与下次采样做差
+type cpuSnapshot struct {
+	total      cpu.TimesStat
+	perCore    []cpu.TimesStat
+	ctxt       uint64
+	interrupts uint64
+	time       time.Time
+}
+
+// collectCPUDetail 根据 cpu.Times 的差值计算总使用率、各核使用率和各状态占比，首次采集时间隔1秒取样
+func collectCPUDetail(info *CPUInfo) {
+	current, err := takeCPUSnapshot()
+	if err != nil {
+		return
+	}
+	if lastCPUSnapshot == nil {
+		lastCPUSnapshot = current
+		time.Sleep(time.Second)
+		if current, err = takeCPUSnapshot(); err != nil {
+			return
+		}
+	}
+	last := lastCPUSnapshot
+	lastCPUSnapshot = current
+
+	info.UsagePercent = cpuBusyPercent(last.total, current.total)
+
+	if len(last.perCore) == len(current.perCore) {
+		info.PerCore = make([]float64, len(current.perCore))
+		for i := range current.perCore {
+			info.PerCore[i] = cpuBusyPercent(last.perCore[i], current.perCore[i])
+		}
+	}
+
+	t1, t2 := last.total, current.total
+	all := cpuTotal(t2) - cpuTotal(t1)
+	if all > 0 {
+		pct := func(a, b float64) float64 {
+			if b < a {
+				return 0
+			}
+			return (b - a) / all * 100
+		}
+		info.UserPercent = pct(t1.User, t2.User)
+		info.SystemPercent = pct(t1.System, t2.System)
+		info.IdlePercent = pct(t1.Idle, t2.Idle)
+		info.NicePercent = pct(t1.Nice, t2.Nice)
+		info.IOWaitPercent = pct(t1.Iowait, t2.Iowait)
+		info.IRQPercent = pct(t1.Irq, t2.Irq)
+		info.SoftIRQPercent = pct(t1.Softirq, t2.Softirq)
+		info.StealPercent = pct(t1.Steal, t2.Steal)
+		info.GuestPercent = pct(t1.Guest, t2.Guest)
+	}
+
+	if elapsed := current.time.Sub(last.time).Seconds(); elapsed > 0 {
+		if current.ctxt >= last.ctxt {
+			info.CtxSwitchRate = float64(current.ctxt-last.ctxt) / elapsed
+		}
+		if current.interrupts >= last.interrupts {
+			info.InterruptRate = float64(current.interrupts-last.interrupts) / elapsed
+		}
+	}
+
+	// 负载均值（Windows不支持）
+	if avg, err := load.Avg(); err == nil {
+		info.Load1 = avg.Load1
+		info.Load5 = avg.Load5
+		info.Load15 = avg.Load15
+	}
+}
+
+func takeCPUSnapshot() (*cpuSnapshot, error) {
+	total, err := cpu.Times(false)
+	if err != nil || len(total) == 0 {
+		return nil, err
+	}
+	snapshot := &cpuSnapshot{total: total[0], time: time.Now()}
+	if perCore, err := cpu.Times(true); err == nil {
+		snapshot.perCore = perCore
+	}
+	snapshot.ctxt, snapshot.interrupts = readProcStatCounters()
+	return snapshot, nil
+}
+
+// cpuTotal 总时间，Linux下guest时间已计入user，需要扣除（与gopsutil的cpu.Percent一致）
+func cpuTotal(t cpu.TimesStat) float64 {
+	total := t.Total()
+	if runtime.GOOS == "linux" {
+		total -= t.Guest + t.GuestNice
+	}
+	return total
+}
+
+func cpuBusyPercent(t1, t2 cpu.TimesStat) float64 {
+	all := cpuTotal(t2) - cpuTotal(t1)
+	busy := (cpuTotal(t2) - t2.Idle - t2.Iowait) - (cpuTotal(t1) - t1.Idle - t1.Iowait)
+	if all <= 0 || busy <= 0 {
+		return 0
+	}
+	if busy > all {
+		return 100
+	}
+	return busy / all * 100
+}
+
+// readProcStatCounters 读取 /proc/stat 中的上下文切换和中断累计次数（仅Linux）
+func readProcStatCounters() (ctxt, interrupts uint64) {
+	if runtime.GOOS != "linux" {
+		return 0, 0
+	}
+	f, err := os.Open(hostProc("stat"))
+	if err != nil {
+		return 0, 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024) // intr行可能很长
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "ctxt":
+			ctxt, _ = strconv.ParseUint(fields[1], 10, 64)
+		case "intr":
+			interrupts, _ = strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	return ctxt, interrupts
+}
+
+// hostProc 返回proc文件系统下的路径，容器中可通过 HOST_PROC 指向宿主机的 /proc
+func hostProc(parts ...string) string {
+	root := os.Getenv("HOST_PROC")
+	if root == "" {
+		root = "/proc"
+	}
+	return filepath.Join(append([]string{root}, parts...)...)
+}
+
+// hostSys 返回sys文件系统下的路径，容器中可通过 HOST_SYS 指向宿主机的 /sys
+func hostSys(parts ...string) string {
+	root := os.Getenv("HOST_SYS")
+	if root == "" {
+		root = "/sys"
+	}
+	return filepath.Join(append([]string{root}, parts...)...)
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+const diskUsageTimeout = 5 * time.Second // 单个挂载点的统计超时，防止失联的NFS阻塞采集
+
+// 默认排除的伪文件系统和系统挂载点（与node_exporter的默认规则类似）
+const (
+	defaultDiskFstypeExclude = `^(autofs|binfmt_misc|bpf|cgroup2?|configfs|debugfs|devfs|devpts|devtmpfs|efivarfs|fdescfs|fusectl|fuse\.lxcfs|hugetlbfs|iso9660|mqueue|nsfs|nullfs|overlay|proc|procfs|pstore|ramfs|rpc_pipefs|securityfs|selinuxfs|squashfs|sysfs|tmpfs|tracefs)$`
+	defaultDiskMountExclude  = `^/(dev|proc|sys|run)($|/)|^/var/lib/(docker|containers|kubelet)/.+|^/snap/`
+)
+
+// DiskFilter 挂载点过滤规则（正则表达式），include为空表示全部
+type DiskFilter struct {
+	FstypeInclude string `json:"fstype_include,omitempty"`
+	FstypeExclude string `json:"fstype_exclude,omitempty"`
+	MountInclude  string `json:"mount_include,omitempty"`
+	MountExclude  string `json:"mount_exclude,omitempty"`
+}
+
+// diskMatcher 编译后的过滤规则
+type diskMatcher struct {
+	fstypeInclude, fstypeExclude *regexp.Regexp
+	mountInclude, mountExclude   *regexp.Regexp
+}
+
+var (
+	diskFilter     *diskMatcher
+	diskFilterOnce sync.Once
+
+	// 统计超时仍未返回的挂载点，返回前不再重复统计
+	stuckMounts   = make(map[string]bool)
+	stuckMountsMu sync.Mutex
+)
+
+func compileDiskFilter(filter DiskFilter) *diskMatcher {
+	compile := func(name, expr, fallback string) *regexp.Regexp {
+		if expr == "" {
+			expr = fallback
+		}
+		if expr == "" {
+			return nil
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			log.Printf("磁盘过滤规则 %s 无效，使用默认规则 | Invalid disk filter %s, using default: %v", name, name, err)
+			if fallback == "" {
+				return nil
+			}
+			return regexp.MustCompile(fallback)
+		}
+		return re
+	}
+	return &diskMatcher{
+		fstypeInclude: compile("fstype_include", filter.FstypeInclude, ""),
+		fstypeExclude: compile("fstype_exclude", filter.FstypeExclude, defaultDiskFstypeExclude),
+		mountInclude:  compile("mount_include", filter.MountInclude, ""),
+		mountExclude:  compile("mount_exclude", filter.MountExclude, defaultDiskMountExclude),
+	}
+}
+
+func (m *diskMatcher) match(p disk.PartitionStat) bool {
+	if m.fstypeInclude != nil && !m.fstypeInclude.MatchString(p.Fstype) {
+		return false
+	}
+	if m.fstypeExclude != nil && m.fstypeExclude.MatchString(p.Fstype) {
+		return false
+	}
+	if m.mountInclude != nil && !m.mountInclude.MatchString(p.Mountpoint) {
+		return false
+	}
+	if m.mountExclude != nil && m.mountExclude.MatchString(p.Mountpoint) {
+		return false
+	}
+	return true
+}
+
+// collectDiskMounts 采集所有符合过滤规则的挂载点
+func collectDiskMounts() []DiskMount {
+	diskFilterOnce.Do(func() {
+		diskFilter = compileDiskFilter(config.DiskFilter)
+	})
+
+	// all=true 才能包含NFS等网络文件系统，伪文件系统由过滤规则排除
+	partitions, err := disk.Partitions(true)
+	if err != nil {
+		log.Printf("获取分区列表失败 | Failed to list partitions: %v", err)
+		return nil
+	}
+
+	var mounts []DiskMount
+	seen := make(map[string]bool)
+	for _, p := range partitions {
+		if seen[p.Mountpoint] || !diskFilter.match(p) {
+			continue
+		}
+		seen[p.Mountpoint] = true
+
+		usage, ok := diskUsageWithTimeout(p.Mountpoint)
+		if !ok || usage.Total == 0 {
+			continue
+		}
+		mounts = append(mounts, DiskMount{
+			Mountpoint:         p.Mountpoint,
+			Device:             p.Device,
+			Fstype:             p.Fstype,
+			Total:              usage.Total,
+			Used:               usage.Used,
+			Free:               usage.Free,
+			UsagePercent:       usage.UsedPercent,
+			InodesTotal:        usage.InodesTotal,
+			InodesUsed:         usage.InodesUsed,
+			InodesFree:         usage.InodesFree,
+			InodesUsagePercent: usage.InodesUsedPercent,
+		})
+	}
+
+	sort.Slice(mounts, func(i, j int) bool {
+		return mounts[i].Mountpoint < mounts[j].Mountpoint
+	})
+	return mounts
+}
+
+// diskUsageWithTimeout 统计挂载点使用情况，超时的挂载点在返回前被跳过
+func diskUsageWithTimeout(mountpoint string) (*disk.UsageStat, bool) {
+	stuckMountsMu.Lock()
+	if stuckMounts[mountpoint] {
+		stuckMountsMu.Unlock()
+		return nil, false
+	}
+	stuckMountsMu.Unlock()
+
+	type result struct {
+		usage *disk.UsageStat
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		usage, err := disk.Usage(mountpoint)
+		done <- result{usage, err}
+
+		stuckMountsMu.Lock()
+		delete(stuckMounts, mountpoint)
+		stuckMountsMu.Unlock()
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return nil, false
+		}
+		return r.usage, true
+	case <-time.After(diskUsageTimeout):
+		// 持锁标记，统计协程返回后会在同一把锁下清除标记
+		stuckMountsMu.Lock()
+		select {
+		case r := <-done:
+			stuckMountsMu.Unlock()
+			return r.usage, r.err == nil
+		default:
+			stuckMounts[mountpoint] = true
+		}
+		stuckMountsMu.Unlock()
+		log.Printf("挂载点 %s 统计超时，暂时跳过 | Disk usage for %s timed out, skipping", mountpoint, mountpoint)
+		return nil, false
+	}
+}
+
+// collectDiskIO 采集各磁盘设备的I/O计数，速率、IOPS、平均等待和利用率按与上次采集的差值计算
+func collectDiskIO() []DiskIOStat {
+	currentTime := time.Now()
+	counters, err := disk.IOCounters()
+	if err != nil {
+		return nil
+	}
+
+	if lastDiskIOStats == nil {
+		lastDiskIOStats = make(map[string]disk.IOCountersStat)
+	}
+	timeDiff := currentTime.Sub(lastDiskIOTime).Seconds()
+
+	var stats []DiskIOStat
+	for name, c := range counters {
+		// 跳过虚拟设备、分区和从未有过I/O的设备
+		if !isWholeDisk(name) || c.ReadCount+c.WriteCount == 0 {
+			continue
+		}
+
+		stat := DiskIOStat{
+			Name:       name,
+			ReadBytes:  c.ReadBytes,
+			WriteBytes: c.WriteBytes,
+			ReadCount:  c.ReadCount,
+			WriteCount: c.WriteCount,
+		}
+
+		last, exists := lastDiskIOStats[name]
+		// 计数回绕或重启后计数变小时跳过本次速率计算
+		if exists && !lastDiskIOTime.IsZero() && timeDiff > 0 &&
+			c.ReadBytes >= last.ReadBytes && c.WriteBytes >= last.WriteBytes &&
+			c.ReadCount >= last.ReadCount && c.WriteCount >= last.WriteCount {
+			reads := c.ReadCount - last.ReadCount
+			writes := c.WriteCount - last.WriteCount
+
+			stat.ReadSpeed = float64(c.ReadBytes-last.ReadBytes) / timeDiff / 1024
+			stat.WriteSpeed = float64(c.WriteBytes-last.WriteBytes) / timeDiff / 1024
+			stat.ReadIOPS = float64(reads) / timeDiff
+			stat.WriteIOPS = float64(writes) / timeDiff
+
+			// await: 每次I/O的平均耗时(ms)，包含排队时间
+			if reads+writes > 0 && c.ReadTime+c.WriteTime >= last.ReadTime+last.WriteTime {
+				stat.Await = float64(c.ReadTime+c.WriteTime-last.ReadTime-last.WriteTime) / float64(reads+writes)
+			}
+			// 利用率: 设备忙碌时间占比，IoTime单位为ms
+			if c.IoTime >= last.IoTime {
+				stat.UtilPercent = float64(c.IoTime-last.IoTime) / (timeDiff * 1000) * 100
+				if stat.UtilPercent > 100 {
+					stat.UtilPercent = 100
+				}
+			}
+		}
+
+		lastDiskIOStats[name] = c
+		stats = append(stats, stat)
+	}
+	lastDiskIOTime = currentTime
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+// isWholeDisk 判断是否为整块磁盘（Linux下 /sys/block 中的设备，排除loop/ram），其他系统不做区分
+func isWholeDisk(name string) bool {
+	if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") {
+		return false
+	}
+	if runtime.GOOS != "linux" {
+		return true
+	}
+	if _, err := os.Stat("/sys/block"); err != nil {
+		return true
+	}
+	_, err := os.Stat(filepath.Join("/sys/block", name))
+	return err == nil
+}
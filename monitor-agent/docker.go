@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDockerSocket = "/var/run/docker.sock"
+	dockerAPITimeout    = 5 * time.Second
+	dockerConcurrency   = 8 // 同时查询的容器数
+)
+
+// DockerConfig Docker容器采集配置
+type DockerConfig struct {
+	Enabled        bool   `json:"enabled"`
+	Socket         string `json:"socket,omitempty"`          // Docker Engine API 的unix socket，默认 /var/run/docker.sock
+	IncludeStopped bool   `json:"include_stopped,omitempty"` // 同时上报已停止的容器
+}
+
+// dockerCounters 容器的累计计数，用于与下次采集做差
+type dockerCounters struct {
+	cpuTotal, systemTotal uint64
+	netRx, netTx          uint64
+	blockRead, blockWrite uint64
+	onlineCPUs            int
+	time                  time.Time
+}
+
+var (
+	dockerClient     *http.Client
+	dockerClientOnce sync.Once
+	dockerWarned     bool // Docker不可用时只提示一次，恢复后重置
+)
+
+// Docker Engine API 响应中用到的字段
+type dockerContainer struct {
+	ID     string   `json:"Id"`
+	Names  []string `json:"Names"`
+	Image  string   `json:"Image"`
+	State  string   `json:"State"`
+	Status string   `json:"Status"`
+}
+
+type dockerInspect struct {
+	RestartCount int `json:"RestartCount"`
+	State        struct {
+		Health *struct {
+			Status string `json:"Status"`
+		} `json:"Health"`
+	} `json:"State"`
+}
+
+type dockerStats struct {
+	CPUStats struct {
+		CPUUsage struct {
+			TotalUsage  uint64   `json:"total_usage"`
+			PercpuUsage []uint64 `json:"percpu_usage"`
+		} `json:"cpu_usage"`
+		SystemCPUUsage uint64 `json:"system_cpu_usage"`
+		OnlineCPUs     int    `json:"online_cpus"`
+	} `json:"cpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Limit uint64            `json:"limit"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+	Networks map[string]struct {
+		RxBytes uint64 `json:"rx_bytes"`
+		TxBytes uint64 `json:"tx_bytes"`
+	} `json:"networks"`
+	BlkioStats struct {
+		IOServiceBytesRecursive []struct {
+			Op    string `json:"op"`
+			Value uint64 `json:"value"`
+		} `json:"io_service_bytes_recursive"`
+	} `json:"blkio_stats"`
+	PidsStats struct {
+		Current uint64 `json:"current"`
+	} `json:"pids_stats"`
+}
+
+func newDockerClient(socket string) *http.Client {
+	return &http.Client{
+		Timeout: dockerAPITimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+}
+
+// dockerGet 请求Docker Engine API并解析JSON响应
+func dockerGet(path string, v interface{}) error {
+	// 主机名部分会被忽略，实际连接unix socket
+	resp, err := dockerClient.Get("http://docker" + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Docker API %s 返回状态 %d", path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// collectContainers 采集容器状态和资源使用，CPU、网络和块设备速率按与上次采集的差值计算
+func collectContainers() []ContainerInfo {
+	dockerClientOnce.Do(func() {
+		socket := config.Docker.Socket
+		if socket == "" {
+			socket = defaultDockerSocket
+		}
+		dockerClient = newDockerClient(socket)
+	})
+
+	path := "/containers/json"
+	if config.Docker.IncludeStopped {
+		path += "?all=1"
+	}
+	var list []dockerContainer
+	if err := dockerGet(path, &list); err != nil {
+		if !dockerWarned {
+			log.Printf("获取容器列表失败 | Failed to list containers: %v", err)
+			dockerWarned = true
+		}
+		return nil
+	}
+	dockerWarned = false
+
+	containers := make([]ContainerInfo, len(list))
+	counters := make([]*dockerCounters, len(list))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, dockerConcurrency)
+	for i, c := range list {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, c dockerContainer) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			containers[i], counters[i] = collectContainer(c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	if lastContainerCounters == nil {
+		lastContainerCounters = make(map[string]*dockerCounters)
+	}
+	current := make(map[string]*dockerCounters)
+	for i := range containers {
+		if counters[i] == nil {
+			continue
+		}
+		applyContainerRates(&containers[i], lastContainerCounters[list[i].ID], counters[i])
+		current[list[i].ID] = counters[i]
+	}
+	lastContainerCounters = current
+
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].Name < containers[j].Name
+	})
+	return containers
+}
+
+// collectContainer 查询单个容器，未运行的容器只返回基本信息
+func collectContainer(c dockerContainer) (ContainerInfo, *dockerCounters) {
+	info := ContainerInfo{
+		ID:     shortContainerID(c.ID),
+		Name:   containerName(c),
+		Image:  c.Image,
+		State:  c.State,
+		Status: c.Status,
+	}
+
+	var inspect dockerInspect
+	if err := dockerGet("/containers/"+c.ID+"/json", &inspect); err == nil {
+		info.RestartCount = inspect.RestartCount
+		if inspect.State.Health != nil {
+			info.Health = inspect.State.Health.Status
+		}
+	}
+
+	if c.State != "running" {
+		return info, nil
+	}
+
+	// one-shot 不等待第二次采样，速率由代理自行按采集间隔计算
+	var stats dockerStats
+	if err := dockerGet("/containers/"+c.ID+"/stats?stream=false&one-shot=true", &stats); err != nil {
+		return info, nil
+	}
+
+	// 与 docker stats 一致，内存使用不含可回收的缓存（cgroup v1为total_inactive_file，v2为inactive_file）
+	info.MemoryUsage = stats.MemoryStats.Usage
+	cache := stats.MemoryStats.Stats["inactive_file"]
+	if v, ok := stats.MemoryStats.Stats["total_inactive_file"]; ok {
+		cache = v
+	}
+	if cache < info.MemoryUsage {
+		info.MemoryUsage -= cache
+	}
+	info.MemoryLimit = stats.MemoryStats.Limit
+	if info.MemoryLimit > 0 {
+		info.MemoryPercent = float64(info.MemoryUsage) / float64(info.MemoryLimit) * 100
+	}
+
+	for _, n := range stats.Networks {
+		info.NetRxBytes += n.RxBytes
+		info.NetTxBytes += n.TxBytes
+	}
+	for _, entry := range stats.BlkioStats.IOServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			info.BlockReadBytes += entry.Value
+		case "write":
+			info.BlockWriteBytes += entry.Value
+		}
+	}
+	info.PIDs = stats.PidsStats.Current
+
+	onlineCPUs := stats.CPUStats.OnlineCPUs
+	if onlineCPUs == 0 {
+		onlineCPUs = len(stats.CPUStats.CPUUsage.PercpuUsage)
+	}
+	counter := &dockerCounters{
+		cpuTotal:    stats.CPUStats.CPUUsage.TotalUsage,
+		systemTotal: stats.CPUStats.SystemCPUUsage,
+		netRx:       info.NetRxBytes,
+		netTx:       info.NetTxBytes,
+		blockRead:   info.BlockReadBytes,
+		blockWrite:  info.BlockWriteBytes,
+		onlineCPUs:  onlineCPUs,
+		time:        time.Now(),
+	}
+	return info, counter
+}
+
+// applyContainerRates 计算CPU使用率（100表示占满一个核，与docker stats一致）和I/O速率
+func applyContainerRates(info *ContainerInfo, last, current *dockerCounters) {
+	if last == nil {
+		return
+	}
+	if current.cpuTotal >= last.cpuTotal && current.systemTotal > last.systemTotal && current.onlineCPUs > 0 {
+		cpuDelta := float64(current.cpuTotal - last.cpuTotal)
+		systemDelta := float64(current.systemTotal - last.systemTotal)
+		info.CPUPercent = cpuDelta / systemDelta * float64(current.onlineCPUs) * 100
+	}
+
+	timeDiff := current.time.Sub(last.time).Seconds()
+	if timeDiff <= 0 {
+		return
+	}
+	rate := func(now, prev uint64) float64 {
+		if now < prev {
+			return 0 // 容器重启后计数清零
+		}
+		return float64(now-prev) / timeDiff / 1024
+	}
+	info.NetRxSpeed = rate(current.netRx, last.netRx)
+	info.NetTxSpeed = rate(current.netTx, last.netTx)
+	info.BlockReadSpeed = rate(current.blockRead, last.blockRead)
+	info.BlockWriteSpeed = rate(current.blockWrite, last.blockWrite)
+}
+
+func shortContainerID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+func containerName(c dockerContainer) string {
+	if len(c.Names) == 0 {
+		return shortContainerID(c.ID)
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
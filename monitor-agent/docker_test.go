@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 以下响应按Docker Engine API v1.43的输出裁剪，只保留代理用到的字段
+const dockerListBody = `[
+  {"Id": "3f4e8a9b2c1d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a6b7c8d9e0f", "Names": ["/web"], "Image": "nginx:1.25", "State": "running", "Status": "Up 2 hours"},
+  {"Id": "9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f1a0b9c8d7e6f5a4b3c2d1e0f9a8b", "Names": ["/job"], "Image": "busybox", "State": "exited", "Status": "Exited (0) 5 minutes ago"}
+]`
+
+const dockerInspectBody = `{"Id": "3f4e8a9b2c1d", "RestartCount": %d, "State": {"Status": "running", "Health": {"Status": "healthy"}}}`
+
+// 第一次和第二次采样之间容器用了0.2秒CPU，系统总CPU时间增加2秒（2核），即20%
+var dockerStatsBodies = []string{
+	`{
+  "read": "2024-05-01T10:00:00.000000000Z",
+  "pids_stats": {"current": 5},
+  "networks": {"eth0": {"rx_bytes": 1048576, "tx_bytes": 524288}},
+  "blkio_stats": {"io_service_bytes_recursive": [{"major": 8, "minor": 0, "op": "read", "value": 4096}, {"major": 8, "minor": 0, "op": "write", "value": 8192}]},
+  "cpu_stats": {"cpu_usage": {"total_usage": 1000000000}, "system_cpu_usage": 50000000000, "online_cpus": 2},
+  "memory_stats": {"usage": 104857600, "limit": 1073741824, "stats": {"inactive_file": 31457280, "anon": 62914560}}
+}`,
+	`{
+  "read": "2024-05-01T10:00:05.000000000Z",
+  "pids_stats": {"current": 6},
+  "networks": {"eth0": {"rx_bytes": 2097152, "tx_bytes": 1048576}},
+  "blkio_stats": {"io_service_bytes_recursive": [{"major": 8, "minor": 0, "op": "read", "value": 8192}, {"major": 8, "minor": 0, "op": "write", "value": 16384}]},
+  "cpu_stats": {"cpu_usage": {"total_usage": 1200000000}, "system_cpu_usage": 52000000000, "online_cpus": 2},
+  "memory_stats": {"usage": 125829120, "limit": 1073741824, "stats": {"inactive_file": 20971520, "anon": 83886080}}
+}`,
+	// 容器重启后计数器从0开始
+	`{
+  "read": "2024-05-01T10:00:10.000000000Z",
+  "pids_stats": {"current": 2},
+  "networks": {"eth0": {"rx_bytes": 2048, "tx_bytes": 1024}},
+  "blkio_stats": {"io_service_bytes_recursive": []},
+  "cpu_stats": {"cpu_usage": {"total_usage": 50000000}, "system_cpu_usage": 54000000000, "online_cpus": 2},
+  "memory_stats": {"usage": 10485760, "limit": 1073741824, "stats": {"inactive_file": 1048576}}
+}`,
+}
+
+// fakeDocker 在unix socket上回放录制的Docker API响应
+type fakeDocker struct {
+	mu       sync.Mutex
+	step     int
+	restarts int
+}
+
+func (f *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.URL.Path == "/containers/json":
+		if r.URL.Query().Get("all") != "1" {
+			http.Error(w, "expected all=1 with include_stopped", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(dockerListBody))
+	case strings.HasSuffix(r.URL.Path, "/json"):
+		fmt.Fprintf(w, dockerInspectBody, f.restarts)
+	case strings.HasSuffix(r.URL.Path, "/stats"):
+		if !strings.HasPrefix(r.URL.Path, "/containers/3f4e8a9b2c1d") {
+			http.Error(w, "container not running", http.StatusConflict)
+			return
+		}
+		w.Write([]byte(dockerStatsBodies[f.step]))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeDocker) advance(restarted bool) {
+	f.mu.Lock()
+	f.step++
+	if restarted {
+		f.restarts++
+	}
+	f.mu.Unlock()
+}
+
+func startFakeDocker(t *testing.T) *fakeDocker {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix socket unavailable: %v", err)
+	}
+	fake := &fakeDocker{}
+	srv := &http.Server{Handler: fake}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	saved := config
+	config.Docker = DockerConfig{Enabled: true, Socket: socket, IncludeStopped: true}
+	dockerClientOnce = sync.Once{}
+	lastContainerCounters = nil
+	t.Cleanup(func() {
+		config = saved
+		dockerClientOnce = sync.Once{}
+		lastContainerCounters = nil
+	})
+	return fake
+}
+
+func findContainer(t *testing.T, containers []ContainerInfo, name string) ContainerInfo {
+	t.Helper()
+	for _, c := range containers {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("container %q not found in %+v", name, containers)
+	return ContainerInfo{}
+}
+
+func TestCollectContainers(t *testing.T) {
+	fake := startFakeDocker(t)
+
+	// 第一次采集只有累计值，没有速率
+	containers := collectContainers()
+	if len(containers) != 2 {
+		t.Fatalf("got %d containers, want 2", len(containers))
+	}
+	web := findContainer(t, containers, "web")
+	if web.ID != "3f4e8a9b2c1d" || web.Image != "nginx:1.25" || web.Health != "healthy" {
+		t.Errorf("web = %+v", web)
+	}
+	if web.CPUPercent != 0 || web.NetRxSpeed != 0 {
+		t.Errorf("first sample has rates: cpu %v, rx %v", web.CPUPercent, web.NetRxSpeed)
+	}
+	// 100MiB使用量减去30MiB inactive_file
+	if web.MemoryUsage != 70<<20 {
+		t.Errorf("memory usage = %d, want %d", web.MemoryUsage, 70<<20)
+	}
+	if job := findContainer(t, containers, "job"); job.State != "exited" || job.MemoryUsage != 0 {
+		t.Errorf("job = %+v", job)
+	}
+
+	fake.advance(false)
+	web = findContainer(t, collectContainers(), "web")
+	if web.CPUPercent < 19.999 || web.CPUPercent > 20.001 {
+		t.Errorf("cpu percent = %v, want 20", web.CPUPercent)
+	}
+	if web.MemoryUsage != 100<<20 {
+		t.Errorf("memory usage = %d, want %d", web.MemoryUsage, 100<<20)
+	}
+	if web.MemoryPercent < 9.765 || web.MemoryPercent > 9.766 {
+		t.Errorf("memory percent = %v", web.MemoryPercent)
+	}
+	if web.NetRxSpeed <= 0 || web.NetTxSpeed <= 0 || web.BlockReadSpeed <= 0 || web.BlockWriteSpeed <= 0 {
+		t.Errorf("rates not computed: %+v", web)
+	}
+
+	// 重启后计数器变小，速率和CPU使用率归零而不是出现巨大的负差值
+	fake.advance(true)
+	web = findContainer(t, collectContainers(), "web")
+	if web.RestartCount != 1 {
+		t.Errorf("restart count = %d, want 1", web.RestartCount)
+	}
+	if web.CPUPercent != 0 {
+		t.Errorf("cpu percent after restart = %v, want 0", web.CPUPercent)
+	}
+	if web.NetRxSpeed != 0 || web.NetTxSpeed != 0 || web.BlockReadSpeed != 0 || web.BlockWriteSpeed != 0 {
+		t.Errorf("rates after restart = rx %v tx %v read %v write %v, want 0",
+			web.NetRxSpeed, web.NetTxSpeed, web.BlockReadSpeed, web.BlockWriteSpeed)
+	}
+	if web.NetRxBytes != 2048 || web.MemoryUsage != 9<<20 {
+		t.Errorf("counters after restart = rx %d mem %d", web.NetRxBytes, web.MemoryUsage)
+	}
+}
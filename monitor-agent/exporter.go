@@ -0,0 +1,57 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+
+	"serverstatus-monitor/internal/prom"
+)
+
+// metricsExporter 缓存最近一次采集结果，供Prometheus拉取
+type metricsExporter struct {
+	mu     sync.RWMutex
+	latest *SystemInfo
+}
+
+var exporter = &metricsExporter{}
+
+// update 保存最新采集结果（采集周期与上报间隔一致，网速按相邻两次采集计算）
+func (e *metricsExporter) update(info *SystemInfo) {
+	e.mu.Lock()
+	e.latest = info
+	e.mu.Unlock()
+}
+
+// startMetricsServer 启动本地 /metrics 端点
+func startMetricsServer(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", exporter.handleMetrics)
+
+	go func() {
+		log.Printf("指标端点 | Metrics endpoint: http://%s/metrics", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Fatalf("启动指标端点失败 | Failed to start metrics endpoint: %v", err)
+		}
+	}()
+}
+
+func (e *metricsExporter) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	e.mu.RLock()
+	info := e.latest
+	e.mu.RUnlock()
+
+	if info == nil {
+		http.Error(w, "尚未完成首次采集 | First collection not finished", http.StatusServiceUnavailable)
+		return
+	}
+
+	// 指标名与data-server的 /metrics 保持一致
+	p := prom.NewWriter()
+	prom.WriteSystem(p, info, []string{"hostname", info.Hostname})
+
+	w.Header().Set("Content-Type", prom.ContentType)
+	if _, err := w.Write(p.Bytes()); err != nil {
+		log.Printf("写入指标响应失败 | Failed to write metrics response: %v", err)
+	}
+}
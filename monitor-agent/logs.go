@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"runtime"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxLogReadBytes  = 16 << 20 // 每个周期每个文件最多读取的字节数，超出部分下个周期继续
+	maxLogLineLength = 64 << 10 // 超过该长度仍没有换行时按一行处理
+	maxLogKeepLines  = 100
+	maxLogLineReport = 512 // 上报的匹配行最多保留的字节数
+)
+
+// LogConfig 跟踪的日志文件，按正则统计每个上报周期的匹配行数
+type LogConfig struct {
+	Name      string       `json:"name"`
+	Path      string       `json:"path"`
+	Patterns  []LogPattern `json:"patterns"`
+	KeepLines int          `json:"keep_lines,omitempty"` // 保留最近的匹配行数，默认0不保留，最多100
+}
+
+// LogPattern 日志匹配规则
+type LogPattern struct {
+	Name  string `json:"name"`
+	Regex string `json:"regex"`
+}
+
+// logTailer 单个日志文件的读取状态
+type logTailer struct {
+	LogConfig
+	patterns []*regexp.Regexp
+	totals   []uint64
+
+	file    *os.File
+	info    os.FileInfo // 正在跟踪的文件，用于识别轮转
+	offset  int64
+	partial []byte
+	recent  []LogLine
+	lines   uint64
+	started bool // 首次读取时跳过文件已有的内容
+	lastErr string
+}
+
+var (
+	logTailers     []*logTailer
+	logLastCollect time.Time
+)
+
+// startLogs 校验配置并定位到各文件末尾，只统计启动之后写入的内容
+func startLogs() {
+	seen := make(map[string]bool)
+	for _, cfg := range config.Logs {
+		t, err := newLogTailer(cfg)
+		if err == nil && seen[t.Name] {
+			err = fmt.Errorf("名称重复")
+		}
+		if err != nil {
+			log.Printf("日志 %q 配置无效，已忽略 | Invalid log %q ignored: %v", cfg.Name, cfg.Name, err)
+			continue
+		}
+		seen[t.Name] = true
+		if err := t.poll(make([]int, len(t.patterns))); err != nil {
+			log.Printf("读取日志 %q 失败 | Failed to read log %q: %v", t.Name, t.Name, err)
+			t.lastErr = err.Error()
+		}
+		logTailers = append(logTailers, t)
+	}
+	logLastCollect = time.Now()
+	if len(logTailers) > 0 {
+		log.Printf("已跟踪 %d 个日志文件 | Tailing %d log files", len(logTailers), len(logTailers))
+	}
+}
+
+func newLogTailer(cfg LogConfig) (*logTailer, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("缺少path")
+	}
+	if cfg.Name == "" {
+		cfg.Name = cfg.Path
+	}
+	if len(cfg.Patterns) == 0 {
+		return nil, fmt.Errorf("缺少patterns")
+	}
+	if cfg.KeepLines > maxLogKeepLines {
+		cfg.KeepLines = maxLogKeepLines
+	}
+	t := &logTailer{LogConfig: cfg, totals: make([]uint64, len(cfg.Patterns))}
+	names := make(map[string]bool)
+	for _, p := range cfg.Patterns {
+		if p.Name == "" || names[p.Name] {
+			return nil, fmt.Errorf("规则名称为空或重复: %q", p.Name)
+		}
+		names[p.Name] = true
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("规则 %q 的正则无效: %v", p.Name, err)
+		}
+		t.patterns = append(t.patterns, re)
+	}
+	return t, nil
+}
+
+// collectLogs 读取各日志文件自上次采集以来新增的内容并统计匹配行数
+func collectLogs() []LogStats {
+	if len(logTailers) == 0 {
+		return nil
+	}
+	now := time.Now()
+	elapsed := now.Sub(logLastCollect).Seconds()
+	logLastCollect = now
+
+	results := make([]LogStats, 0, len(logTailers))
+	for _, t := range logTailers {
+		results = append(results, t.collect(elapsed))
+	}
+	return results
+}
+
+func (t *logTailer) collect(elapsed float64) LogStats {
+	stats := LogStats{Name: t.Name, Path: t.Path}
+	counts := make([]int, len(t.patterns))
+	linesBefore := t.lines
+
+	if err := t.poll(counts); err != nil {
+		stats.Error = err.Error()
+		if stats.Error != t.lastErr {
+			log.Printf("读取日志 %q 失败 | Failed to read log %q: %v", t.Name, t.Name, err)
+		}
+	}
+	t.lastErr = stats.Error
+
+	stats.Lines = int(t.lines - linesBefore)
+	for i, p := range t.Patterns {
+		t.totals[i] += uint64(counts[i])
+		ps := LogPatternStats{Name: p.Name, Count: counts[i], Total: t.totals[i]}
+		if elapsed > 0 {
+			ps.Rate = float64(counts[i]) / elapsed
+		}
+		stats.Patterns = append(stats.Patterns, ps)
+	}
+	if len(t.recent) > 0 {
+		stats.Recent = append([]LogLine(nil), t.recent...)
+	}
+	return stats
+}
+
+// poll 读取新增内容
+// 路径指向了新文件（轮转）时先读完旧文件剩余的内容，再从头读取新文件；文件变小（截断）时从头读取，
+// 截断后到下次读取前写入的内容已超过原来的偏移时无法识别
+func (t *logTailer) poll(counts []int) error {
+	current, statErr := os.Stat(t.Path)
+	if t.file != nil && (statErr != nil || !os.SameFile(current, t.info)) {
+		t.read(counts)
+		t.flushPartial(counts)
+		t.file.Close()
+		t.file = nil
+	}
+	started := t.started
+	t.started = true
+	if statErr != nil {
+		t.info = nil
+		return statErr
+	}
+
+	if t.info == nil || !os.SameFile(current, t.info) {
+		t.info, t.offset, t.partial = current, 0, nil
+		if !started {
+			t.offset = current.Size()
+		}
+	} else if current.Size() < t.offset {
+		t.offset, t.partial = 0, nil
+	}
+
+	if t.file == nil {
+		f, err := os.Open(t.Path)
+		if err != nil {
+			return err
+		}
+		t.file = f
+	}
+	err := t.read(counts)
+	// Windows上打开的文件无法被重命名，每次读取后关闭，轮转时旧文件未读的内容会丢失
+	if runtime.GOOS == "windows" {
+		t.file.Close()
+		t.file = nil
+	}
+	return err
+}
+
+// read 从当前偏移读取完整的行，末尾不完整的行留到下次
+func (t *logTailer) read(counts []int) error {
+	buf := make([]byte, 64<<10)
+	total := 0
+	for total < maxLogReadBytes {
+		n, err := t.file.ReadAt(buf, t.offset)
+		if n > 0 {
+			t.offset += int64(n)
+			total += n
+			t.consume(buf[:n], counts)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *logTailer) consume(data []byte, counts []int) {
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			t.partial = append(t.partial, data...)
+			if len(t.partial) >= maxLogLineLength {
+				t.flushPartial(counts)
+			}
+			return
+		}
+		line := data[:i]
+		if len(t.partial) > 0 {
+			line = append(t.partial, line...)
+			t.partial = nil
+		}
+		t.match(line, counts)
+		data = data[i+1:]
+	}
+}
+
+func (t *logTailer) flushPartial(counts []int) {
+	if len(t.partial) > 0 {
+		t.match(t.partial, counts)
+		t.partial = nil
+	}
+}
+
+func (t *logTailer) match(line []byte, counts []int) {
+	line = bytes.TrimSuffix(line, []byte{'\r'})
+	t.lines++
+	for i, re := range t.patterns {
+		if !re.Match(line) {
+			continue
+		}
+		counts[i]++
+		if t.KeepLines > 0 {
+			t.recent = append(t.recent, LogLine{Pattern: t.Patterns[i].Name, Line: truncateLine(line), Time: time.Now()})
+			if len(t.recent) > t.KeepLines {
+				t.recent = t.recent[len(t.recent)-t.KeepLines:]
+			}
+		}
+	}
+}
+
+// truncateLine 截断过长的行，不截断在多字节字符中间
+func truncateLine(line []byte) string {
+	if len(line) <= maxLogLineReport {
+		return string(line)
+	}
+	cut := maxLogLineReport
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return string(line[:cut]) + "…"
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func newTestTailer(t *testing.T, path string, keep int) *logTailer {
+	t.Helper()
+	tailer, err := newLogTailer(LogConfig{Name: "app", Path: path, KeepLines: keep, Patterns: []LogPattern{
+		{Name: "error", Regex: "ERROR"},
+		{Name: "warn", Regex: "WARN"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if tailer.file != nil {
+			tailer.file.Close()
+		}
+	})
+	return tailer
+}
+
+func appendLog(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// collectCounts 采集一次，返回读取的行数和error规则的匹配数
+func collectCounts(t *testing.T, tailer *logTailer) (lines, errors int) {
+	t.Helper()
+	stats := tailer.collect(1)
+	if stats.Error != "" {
+		t.Fatalf("collect: %s", stats.Error)
+	}
+	return stats.Lines, stats.Patterns[0].Count
+}
+
+func TestLogTailerSkipsExistingContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLog(t, path, "ERROR before start\nWARN before start\n")
+	tailer := newTestTailer(t, path, 0)
+
+	if lines, errors := collectCounts(t, tailer); lines != 0 || errors != 0 {
+		t.Fatalf("first poll read %d lines, %d errors, want existing content skipped", lines, errors)
+	}
+	appendLog(t, path, "ERROR after start\ninfo\n")
+	if lines, errors := collectCounts(t, tailer); lines != 2 || errors != 1 {
+		t.Errorf("lines %d errors %d, want 2/1", lines, errors)
+	}
+}
+
+func TestLogTailerMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	tailer := newTestTailer(t, path, 0)
+	if stats := tailer.collect(1); stats.Error == "" {
+		t.Fatal("missing file reported no error")
+	}
+	// 文件在启动之后创建，从头读取
+	appendLog(t, path, "ERROR created later\n")
+	if lines, errors := collectCounts(t, tailer); lines != 1 || errors != 1 {
+		t.Errorf("lines %d errors %d, want 1/1", lines, errors)
+	}
+}
+
+func TestLogTailerRotation(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("打开的文件在Windows上无法重命名")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendLog(t, path, "")
+	tailer := newTestTailer(t, path, 0)
+	collectCounts(t, tailer)
+
+	// 轮转前后旧文件写入的内容都要读完
+	appendLog(t, path, "ERROR before rotation\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendLog(t, path+".1", "ERROR written to the old file\nunterminated ERROR")
+	appendLog(t, path, "ERROR in the new file\nWARN in the new file\n")
+
+	if lines, errors := collectCounts(t, tailer); lines != 5 || errors != 4 {
+		t.Errorf("lines %d errors %d, want 5/4", lines, errors)
+	}
+	appendLog(t, path, "ERROR again\n")
+	if lines, errors := collectCounts(t, tailer); lines != 1 || errors != 1 {
+		t.Errorf("after rotation: lines %d errors %d, want 1/1", lines, errors)
+	}
+}
+
+func TestLogTailerTruncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLog(t, path, "")
+	tailer := newTestTailer(t, path, 0)
+	collectCounts(t, tailer)
+	appendLog(t, path, strings.Repeat("ERROR a long line before truncation\n", 10))
+	if _, errors := collectCounts(t, tailer); errors != 10 {
+		t.Fatalf("errors = %d, want 10", errors)
+	}
+
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendLog(t, path, "ERROR after truncation\n")
+	if lines, errors := collectCounts(t, tailer); lines != 1 || errors != 1 {
+		t.Errorf("lines %d errors %d, want 1/1", lines, errors)
+	}
+}
+
+func TestLogTailerPartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLog(t, path, "")
+	tailer := newTestTailer(t, path, 0)
+	collectCounts(t, tailer)
+
+	appendLog(t, path, "ERR")
+	if lines, _ := collectCounts(t, tailer); lines != 0 {
+		t.Fatalf("incomplete line counted: %d lines", lines)
+	}
+	appendLog(t, path, "OR split across polls\r\nWARN")
+	if lines, errors := collectCounts(t, tailer); lines != 1 || errors != 1 {
+		t.Errorf("lines %d errors %d, want 1/1", lines, errors)
+	}
+}
+
+func TestLogTailerLongLineFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLog(t, path, "")
+	tailer := newTestTailer(t, path, 1)
+	collectCounts(t, tailer)
+
+	// 没有换行的超长内容按一行处理，上报时截断
+	appendLog(t, path, "ERROR "+strings.Repeat("x", maxLogLineLength-len("ERROR ")))
+	stats := tailer.collect(1)
+	if stats.Lines != 1 || stats.Patterns[0].Count != 1 {
+		t.Fatalf("lines %d errors %d, want 1/1", stats.Lines, stats.Patterns[0].Count)
+	}
+	if len(stats.Recent) != 1 || !strings.HasSuffix(stats.Recent[0].Line, "…") || len(stats.Recent[0].Line) > maxLogLineReport+len("…") {
+		t.Errorf("recent = %d bytes", len(stats.Recent[0].Line))
+	}
+	if len(tailer.partial) != 0 {
+		t.Errorf("partial kept %d bytes", len(tailer.partial))
+	}
+}
+
+func TestLogTailerKeepLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLog(t, path, "")
+	tailer := newTestTailer(t, path, 2)
+	collectCounts(t, tailer)
+
+	appendLog(t, path, "ERROR one\nWARN two\ninfo\nERROR three\n")
+	stats := tailer.collect(2)
+	if len(stats.Recent) != 2 || stats.Recent[0].Line != "WARN two" || stats.Recent[1].Line != "ERROR three" {
+		t.Fatalf("recent = %+v, want the last two matches", stats.Recent)
+	}
+	if stats.Recent[0].Pattern != "warn" || stats.Patterns[0].Rate != 1 || stats.Patterns[0].Total != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	// 匹配行保留到被新的匹配替换
+	if stats := tailer.collect(1); len(stats.Recent) != 2 || stats.Patterns[0].Count != 0 || stats.Patterns[0].Total != 2 {
+		t.Errorf("idle stats = %+v", stats)
+	}
+
+	capped, err := newLogTailer(LogConfig{Path: path, KeepLines: 1000, Patterns: []LogPattern{{Name: "any", Regex: "."}}})
+	if err != nil || capped.KeepLines != maxLogKeepLines || capped.Name != path {
+		t.Errorf("capped = %+v, %v", capped, err)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"net"
 	"net/http"
 	"os"
 	"os/exec"
@@ -19,99 +18,64 @@ import (
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/disk"
 	"github.com/shirou/gopsutil/v3/host"
-	"github.com/shirou/gopsutil/v3/mem"
 	psnet "github.com/shirou/gopsutil/v3/net"
-)
-
-type SystemInfo struct {
-	Hostname    string    `json:"hostname"`
-	SessionID   string    `json:"session_id,omitempty"` // UUID session标识
-	Timestamp   time.Time `json:"timestamp"`
-	CPU         CPUInfo   `json:"cpu"`
-	Memory      MemInfo   `json:"memory"`
-	Disk        DiskInfo  `json:"disk"`
-	Network     NetInfo   `json:"network"`
-	GPU         GPUInfo   `json:"gpu"`  // 保持兼容性，主GPU信息
-	GPUs        []GPUInfo `json:"gpus"` // 所有GPU信息
-	OS          OSInfo    `json:"os"`
-	Temperature TempInfo  `json:"temperature"`
-	ProjectKey  string    `json:"project_key,omitempty"`
-}
-
-type CPUInfo struct {
-	UsagePercent float64 `json:"usage_percent"`
-	CoreCount    int     `json:"core_count"`
-	ModelName    string  `json:"model_name"`
-}
-
-type MemInfo struct {
-	Total        uint64  `json:"total"`
-	Used         uint64  `json:"used"`
-	Free         uint64  `json:"free"`
-	UsagePercent float64 `json:"usage_percent"`
-}
-
-type DiskInfo struct {
-	Total        uint64  `json:"total"`
-	Used         uint64  `json:"used"`
-	Free         uint64  `json:"free"`
-	UsagePercent float64 `json:"usage_percent"`
-}
-
-type NetInfo struct {
-	BytesSent    uint64        `json:"bytes_sent"`     // 总发送字节数
-	BytesRecv    uint64        `json:"bytes_recv"`     // 总接收字节数
-	PacketsSent  uint64        `json:"packets_sent"`   // 总发送包数
-	PacketsRecv  uint64        `json:"packets_recv"`   // 总接收包数
-	SpeedSent    float64       `json:"speed_sent"`     // 发送速率 (KB/s)
-	SpeedRecv    float64       `json:"speed_recv"`     // 接收速率 (KB/s)
-	Interfaces   []NetInterface `json:"interfaces"`     // 网卡详细信息
-}
-
-type NetInterface struct {
-	Name        string   `json:"name"`         // 网卡名称
-	BytesSent   uint64   `json:"bytes_sent"`   // 发送字节数
-	BytesRecv   uint64   `json:"bytes_recv"`   // 接收字节数
-	PacketsSent uint64   `json:"packets_sent"` // 发送包数
-	PacketsRecv uint64   `json:"packets_recv"` // 接收包数
-	SpeedSent   float64  `json:"speed_sent"`   // 发送速率 (KB/s)
-	SpeedRecv   float64  `json:"speed_recv"`   // 接收速率 (KB/s)
-	IsUp        bool     `json:"is_up"`        // 网卡状态
-	MTU         int      `json:"mtu"`          // MTU
-	Addrs       []string `json:"addrs"`        // IP地址列表
-}
-
-type GPUInfo struct {
-	Name          string  `json:"name"`
-	MemoryTotal   uint64  `json:"memory_total"`
-	MemoryUsed    uint64  `json:"memory_used"`
-	UsagePercent  float64 `json:"usage_percent"`
-	Temperature   float64 `json:"temperature"`
-	DriverVersion string  `json:"driver_version"`
-	CudaVersion   string  `json:"cuda_version"`
-}
+	"github.com/shirou/gopsutil/v3/process"
 
-type OSInfo struct {
-	Platform string `json:"platform"`
-	Version  string `json:"version"`
-	Arch     string `json:"arch"`
-	Uptime   uint64 `json:"uptime"`
-}
+	"serverstatus-monitor/internal/model"
+)
 
-type TempInfo struct {
-	CPUTemp float64            `json:"cpu_temp"`
-	GPUTemp float64            `json:"gpu_temp"`
-	Other   map[string]float64 `json:"other"`
-	MaxTemp float64            `json:"max_temp"`
-	AvgTemp float64            `json:"avg_temp"`
-}
+// 采集数据结构与data-server共用，定义见 internal/model
+type (
+	SystemInfo      = model.SystemInfo
+	CheckResult     = model.CheckResult
+	PluginResult    = model.PluginResult
+	CustomMetric    = model.CustomMetric
+	LogStats        = model.LogStats
+	LogPatternStats = model.LogPatternStats
+	LogLine         = model.LogLine
+	ProcessStats    = model.ProcessStats
+	ProcessInfo     = model.ProcessInfo
+	ServiceStatus   = model.ServiceStatus
+	ContainerInfo   = model.ContainerInfo
+	CgroupInfo      = model.CgroupInfo
+	PressureStat    = model.PressureStat
+	CPUInfo         = model.CPUInfo
+	MemInfo         = model.MemInfo
+	DiskInfo        = model.DiskInfo
+	DiskMount       = model.DiskMount
+	DiskIOStat      = model.DiskIOStat
+	NetInfo         = model.NetInfo
+	SocketStats     = model.SocketStats
+	ListenPort      = model.ListenPort
+	ConntrackStats  = model.ConntrackStats
+	NetInterface    = model.NetInterface
+	GPUInfo         = model.GPUInfo
+	OSInfo          = model.OSInfo
+	TempInfo        = model.TempInfo
+)
 
 type Config struct {
-	ServerURL      string        `json:"server_url"`
-	ProjectKey     string        `json:"project_key"`
-	ServerKey      string        `json:"server_key"`
-	ReportInterval time.Duration `json:"report_interval"`
-	Timeout        time.Duration `json:"timeout"`
+	ServerURL      string         `json:"server_url"`
+	ProjectKey     string         `json:"project_key"`
+	ServerKey      string         `json:"server_key"`
+	ReportInterval time.Duration  `json:"report_interval"`
+	Timeout        time.Duration  `json:"timeout"`
+	MetricsListen  string         `json:"metrics_listen,omitempty"` // 本地Prometheus指标监听地址，如 :9101，空为不启用
+	DisablePush    bool           `json:"disable_push,omitempty"`   // 不向服务器上报，仅作为本地指标导出器
+	SpoolDir       string         `json:"spool_dir,omitempty"`      // 上报失败时的离线缓存目录，相对路径以配置文件所在目录为基准
+	SpoolMax       int            `json:"spool_max,omitempty"`      // 离线缓存最多条数，负数为不缓存
+	DiskFilter     DiskFilter     `json:"disk_filter,omitempty"`    // 挂载点过滤规则
+	NetFilter      NetFilter      `json:"net_filter,omitempty"`     // 网卡过滤规则
+	TopProcesses   int            `json:"top_processes,omitempty"`  // 上报占用最高的进程数，负数为不采集
+	Watch          WatchConfig    `json:"watch,omitempty"`          // 检查运行状态的进程和systemd服务
+	Docker         DockerConfig   `json:"docker,omitempty"`         // Docker容器采集
+	View           string         `json:"view,omitempty"`           // 采集视图: auto / host / container
+	HostProc       string         `json:"host_proc,omitempty"`      // 主机视图下宿主机proc的挂载点，默认 /host/proc
+	Checks         []CheckConfig  `json:"checks,omitempty"`         // ping、TCP、HTTP和TLS证书检查
+	Tags           []string       `json:"tags,omitempty"`           // 标签，服务器按标签下发探测任务
+	Plugins        []PluginConfig `json:"plugins,omitempty"`        // 自定义指标插件
+	StatsD         StatsDConfig   `json:"statsd,omitempty"`         // 本地StatsD监听
+	Logs           []LogConfig    `json:"logs,omitempty"`           // 跟踪的日志文件和匹配规则
 }
 
 var (
@@ -121,24 +85,58 @@ var (
 		ServerKey:      "serverstatus.ltd",
 		ReportInterval: 5 * time.Second,
 		Timeout:        10 * time.Second,
+		SpoolDir:       defaultSpoolSubdir,
+		SpoolMax:       defaultSpoolMax,
+		TopProcesses:   defaultTopProcesses,
+		View:           viewAuto,
 	}
 	sessionID string // 全局session ID
 	
 	// 网络速率计算相关
 	lastNetworkStats map[string]psnet.IOCountersStat
 	lastStatsTime    time.Time
+
+	// 磁盘I/O速率计算相关
+	lastDiskIOStats map[string]disk.IOCountersStat
+	lastDiskIOTime  time.Time
+
+	// CPU使用率、上下文切换和中断速率计算相关
+	lastCPUSnapshot *cpuSnapshot
+
+	// 交换分区换入换出速率计算相关
+	lastSwapIn   uint64
+	lastSwapOut  uint64
+	lastSwapTime time.Time
+
+	// 进程CPU使用率计算相关
+	lastProcesses map[int32]*process.Process
+
+	// 进程检查的重启判断相关
+	lastWatchPIDs map[string]map[int32]bool
+	watchRestarts map[string]int
+
+	// 容器CPU使用率和I/O速率计算相关
+	lastContainerCounters map[string]*dockerCounters
+
+	// 容器视图下cgroup使用率和限流占比计算相关
+	lastCgroupCounters *cgroupCounters
+
+	// 监听套接字所属进程，按inode缓存
+	listenOwners map[uint64]socketOwner
 )
 
 // SessionRegisterRequest session注册请求结构
 type SessionRegisterRequest struct {
-	Hostname   string `json:"hostname"`
-	ProjectKey string `json:"project_key"`
+	Hostname   string   `json:"hostname"`
+	ProjectKey string   `json:"project_key"`
+	Tags       []string `json:"tags,omitempty"`
 }
 
 // SessionRegisterResponse session注册响应结构
 type SessionRegisterResponse struct {
-	SessionID string `json:"session_id"`
-	Hostname  string `json:"hostname"`
+	SessionID string        `json:"session_id"`
+	Hostname  string        `json:"hostname"`
+	Probes    []CheckConfig `json:"probes"` // 服务器下发的探测任务，旧版服务器没有该字段
 }
 
 var (
@@ -148,6 +146,9 @@ var (
 	serverKey  = flag.String("server-key", "", "服务器密钥 (Server Key) - 双密钥认证必需")
 	configFile = flag.String("config", "config.json", "配置文件路径")
 	silentMode = flag.Bool("silent", false, "静默模式 - 第一次上报成功后不再打印上报信息")
+	metricsListen = flag.String("metrics-listen", "", "本地Prometheus指标监听地址，如 :9101")
+	noPush        = flag.Bool("no-push", false, "不向服务器上报，仅导出本地指标")
+	viewMode      = flag.String("view", "", "采集视图: auto / host / container")
 	showHelp   = flag.Bool("help", false, "显示帮助信息")
 
 	// 静默模式状态
@@ -175,11 +176,39 @@ func main() {
 	if *serverKey != "" {
 		config.ServerKey = *serverKey
 	}
+	if *metricsListen != "" {
+		config.MetricsListen = *metricsListen
+	}
+	if *noPush {
+		config.DisablePush = true
+	}
+	if *viewMode != "" {
+		config.View = *viewMode
+	}
+	setupView()
+	startChecks()
+	startPlugins()
+	startStatsD()
+	startLogs()
 
 	log.Println("启动 ServerStatus Monitor Agent...")
 	log.Println("📦 项目地址 | Project Repository: https://github.com/MyDailyCloud/ServerStatus")
 	log.Println("⭐ 如果觉得有用，请给个Star支持一下 | If you find it useful, please give us a Star!")
 
+	if config.MetricsListen != "" {
+		startMetricsServer(config.MetricsListen)
+	}
+
+	if config.DisablePush {
+		if config.MetricsListen == "" {
+			log.Println("❌ 错误: 关闭上报时必须设置指标监听地址 | -no-push requires -metrics-listen")
+			os.Exit(1)
+		}
+		log.Println("仅导出模式，不向服务器上报 | Exporter-only mode, not pushing to server")
+		runCollectLoop()
+		return
+	}
+
 	// 强制要求双密钥认证
 	if config.ProjectKey == "" || config.ServerKey == "" {
 		log.Println("❌ 错误: 双密钥认证要求同时提供主密钥和团队密钥")
@@ -196,6 +225,19 @@ func main() {
 	log.Printf("使用项目密钥 | Using project key: %s...", config.ProjectKey[:min(8, len(config.ProjectKey))])
 	log.Printf("使用服务器密钥 | Using server key: %s...", config.ServerKey[:min(8, len(config.ServerKey))])
 
+	// 离线缓存，网络中断期间的数据在恢复后补传
+	if config.SpoolMax > 0 {
+		s, err := openSpool(spoolPath(config.SpoolDir), config.SpoolMax)
+		if err != nil {
+			log.Printf("打开离线缓存失败，上报失败的数据将丢失 | Failed to open spool, unsent samples will be lost: %v", err)
+		} else {
+			spool = s
+			if n := spool.pending(); n > 0 {
+				log.Printf("离线缓存中有 %d 条待补传数据 | %d spooled samples pending", n, n)
+			}
+		}
+	}
+
 	// 自动生成访问链接
 	generateAccessLinks()
 
@@ -205,6 +247,11 @@ func main() {
 		sessionID = "" // 清空sessionID，使用hostname作为fallback
 	}
 
+	runCollectLoop()
+}
+
+// runCollectLoop 按上报间隔循环采集
+func runCollectLoop() {
 	log.Printf("上报间隔 | Report interval: %v", config.ReportInterval)
 
 	ticker := time.NewTicker(config.ReportInterval)
@@ -225,10 +272,25 @@ func collectAndReport() {
 		return
 	}
 
+	exporter.update(info)
+	if config.DisablePush {
+		return
+	}
+
 	err = reportToServer(info)
 	if err != nil {
 		log.Printf("上报数据失败 | Failed to report data: %v", err)
+		if spool != nil {
+			if err := spool.add(info); err != nil {
+				log.Printf("写入离线缓存失败 | Failed to spool sample: %v", err)
+			}
+		}
 	} else {
+		// 服务器可达，补传离线期间缓存的数据
+		if spool != nil {
+			go spool.replay()
+		}
+
 		// 静默模式逻辑
 		if *silentMode {
 			if !firstReportSuccess {
@@ -262,6 +324,7 @@ func registerSession() error {
 	req := SessionRegisterRequest{
 		Hostname:   hostname,
 		ProjectKey: config.ProjectKey,
+		Tags:       config.Tags,
 	}
 
 	jsonData, err := json.Marshal(req)
@@ -292,6 +355,9 @@ func registerSession() error {
 
 	sessionID = response.SessionID
 	log.Printf("Session注册成功 | Session registered successfully: %s", sessionID)
+	if response.Probes != nil {
+		syncProbes(response.Probes)
+	}
 	return nil
 }
 
@@ -302,14 +368,12 @@ func collectSystemInfo() (*SystemInfo, error) {
 		Hostname:   hostname,
 		SessionID:  sessionID,
 		Timestamp:  time.Now(),
+		Tags:       config.Tags,
 		ProjectKey: config.ProjectKey,
 	}
 
 	// CPU信息
-	cpuPercent, err := cpu.Percent(time.Second, false)
-	if err == nil && len(cpuPercent) > 0 {
-		info.CPU.UsagePercent = cpuPercent[0]
-	}
+	collectCPUDetail(&info.CPU)
 	info.CPU.CoreCount = runtime.NumCPU()
 
 	cpuInfos, err := cpu.Info()
@@ -318,13 +382,7 @@ func collectSystemInfo() (*SystemInfo, error) {
 	}
 
 	// 内存信息
-	memStat, err := mem.VirtualMemory()
-	if err == nil {
-		info.Memory.Total = memStat.Total
-		info.Memory.Used = memStat.Used
-		info.Memory.Free = memStat.Free
-		info.Memory.UsagePercent = memStat.UsedPercent
-	}
+	collectMemory(&info.Memory)
 
 	// 磁盘信息
 	diskStat, err := disk.Usage("/")
@@ -337,9 +395,12 @@ func collectSystemInfo() (*SystemInfo, error) {
 		info.Disk.Free = diskStat.Free
 		info.Disk.UsagePercent = diskStat.UsedPercent
 	}
+	info.Disks = collectDiskMounts()
+	info.DiskIO = collectDiskIO()
 
 	// 网络信息
 	info.Network = collectNetworkInfo()
+	collectSockets(&info.Network)
 
 	// GPU信息
 	gpuInfos := collectGPUInfo()
@@ -368,6 +429,28 @@ func collectSystemInfo() (*SystemInfo, error) {
 	// 温度信息
 	info.Temperature = collectTemperatureInfo()
 
+	// 进程信息
+	if config.TopProcesses > 0 {
+		info.Processes = collectProcesses(config.TopProcesses, info.Memory.Total)
+	}
+	info.Services = collectServices()
+	if config.Docker.Enabled {
+		info.Containers = collectContainers()
+	}
+
+	info.Checks = collectChecks()
+	info.Custom = collectPlugins()
+	if sd := collectStatsD(); sd != nil {
+		info.Custom = append(info.Custom, *sd)
+	}
+	info.Logs = collectLogs()
+
+	// 容器视图
+	if cg := collectCgroup(); cg != nil {
+		info.Cgroup = cg
+		applyContainerView(info, cg)
+	}
+
 	return info, nil
 }
 
@@ -401,7 +484,15 @@ func reportToServer(info *SystemInfo) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("服务器返回错误状态: %d", resp.StatusCode)
+		return &statusError{status: resp.StatusCode}
+	}
+
+	// 旧版服务器的响应为空，不影响已有的探测任务
+	var probeResp struct {
+		Probes []CheckConfig `json:"probes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&probeResp); err == nil && probeResp.Probes != nil {
+		syncProbes(probeResp.Probes)
 	}
 
 	return nil
@@ -410,7 +501,7 @@ func reportToServer(info *SystemInfo) error {
 // collectTemperatureInfo 收集温度信息
 func collectTemperatureInfo() TempInfo {
 	tempInfo := TempInfo{
-		Other: make(map[string]float64),
+		Sensors: make(map[string]float64),
 	}
 
 	// 在Linux系统上尝试使用sensors命令
@@ -422,7 +513,7 @@ func collectTemperatureInfo() TempInfo {
 
 			for name, temp := range temps {
 				allTemps = append(allTemps, temp)
-				tempInfo.Other[name] = temp
+				tempInfo.Sensors[name] = temp
 
 				// 判断是否为CPU温度
 				if strings.Contains(strings.ToLower(name), "cpu") ||
@@ -716,6 +807,36 @@ func loadConfig() {
 	if fileConfig.Timeout > 0 {
 		config.Timeout = fileConfig.Timeout
 	}
+	if fileConfig.MetricsListen != "" {
+		config.MetricsListen = fileConfig.MetricsListen
+	}
+	if fileConfig.DisablePush {
+		config.DisablePush = true
+	}
+	if fileConfig.SpoolDir != "" {
+		config.SpoolDir = fileConfig.SpoolDir
+	}
+	if fileConfig.SpoolMax != 0 {
+		config.SpoolMax = fileConfig.SpoolMax
+	}
+	config.DiskFilter = fileConfig.DiskFilter
+	config.NetFilter = fileConfig.NetFilter
+	if fileConfig.TopProcesses != 0 {
+		config.TopProcesses = fileConfig.TopProcesses
+	}
+	config.Watch = fileConfig.Watch
+	config.Docker = fileConfig.Docker
+	if fileConfig.View != "" {
+		config.View = fileConfig.View
+	}
+	if fileConfig.HostProc != "" {
+		config.HostProc = fileConfig.HostProc
+	}
+	config.Checks = fileConfig.Checks
+	config.Tags = fileConfig.Tags
+	config.Plugins = fileConfig.Plugins
+	config.StatsD = fileConfig.StatsD
+	config.Logs = fileConfig.Logs
 
 	log.Printf("加载配置文件 | Loading config file: %s", *configFile)
 
@@ -764,6 +885,12 @@ func printUsage() {
 	fmt.Println("        配置文件路径 | Config file path (默认 | default: config.json)")
 	fmt.Println("  -silent")
 	fmt.Println("        静默模式 | Silent mode - 第一次上报成功后不再打印上报信息 | Stop printing report details after first successful report")
+	fmt.Println("  -metrics-listen string")
+	fmt.Println("        本地Prometheus指标监听地址 | Local Prometheus metrics listen address (例如 | e.g.: :9101)")
+	fmt.Println("  -no-push")
+	fmt.Println("        不向服务器上报，仅导出本地指标 | Exporter-only mode, do not push to server (需要 | requires -metrics-listen)")
+	fmt.Println("  -view string")
+	fmt.Println("        采集视图 | Collection view: auto / host / container (默认 | default: auto)")
 	fmt.Println("  -help")
 	fmt.Println("        显示此帮助信息 | Show this help message")
 	fmt.Println()
@@ -783,6 +910,12 @@ func printUsage() {
 	fmt.Println("  export SERVER_KEY=your-server-secret")
 	fmt.Println("  monitor-agent -url http://192.168.1.100:8080/api/data -key project-alpha -server-key $SERVER_KEY")
 	fmt.Println()
+	fmt.Println("  # 仅作为Prometheus导出器 | Prometheus exporter only (scrape http://host:9101/metrics)")
+	fmt.Println("  monitor-agent -metrics-listen :9101 -no-push")
+	fmt.Println()
+	fmt.Println("  # 在容器中采集宿主机 | Monitor the host from a container")
+	fmt.Println("  docker run -v /proc:/host/proc:ro -v /sys:/host/sys:ro --network host --uts host ... monitor-agent -view host")
+	fmt.Println()
 	fmt.Println("  # 使用自定义配置文件 | Use custom config file")
 	fmt.Println("  monitor-agent -config /path/to/config.json")
 	fmt.Println()
@@ -792,7 +925,43 @@ func printUsage() {
 	fmt.Println(`    "project_key": "project-alpha",`)
 	fmt.Println(`    "server_key": "your-server-secret",`)
 	fmt.Println(`    "report_interval": "1s",`)
-	fmt.Println(`    "timeout": "10s"`)
+	fmt.Println(`    "timeout": "10s",`)
+	fmt.Println(`    "metrics_listen": ":9101",`)
+	fmt.Println(`    "disable_push": false,`)
+	fmt.Println(`    "spool_dir": "spool",`)
+	fmt.Println(`    "spool_max": 17280,`)
+	fmt.Println(`    "top_processes": 5,`)
+	fmt.Println(`    "watch": {`)
+	fmt.Println(`      "processes": [{"name": "nginx", "pattern": "^nginx$"}, {"name": "app", "pattern": "java .*app.jar", "cmdline": true, "min_count": 2}],`)
+	fmt.Println(`      "systemd_units": ["postgresql.service", "docker.service"]`)
+	fmt.Println(`    },`)
+	fmt.Println(`    "docker": {"enabled": true, "socket": "/var/run/docker.sock", "include_stopped": false},`)
+	fmt.Println(`    "view": "auto",`)
+	fmt.Println(`    "host_proc": "/host/proc",`)
+	fmt.Println(`    "checks": [`)
+	fmt.Println(`      {"name": "gateway", "type": "ping", "target": "192.168.1.1", "count": 3},`)
+	fmt.Println(`      {"name": "db", "type": "tcp", "target": "10.0.0.5:5432", "interval": 10},`)
+	fmt.Println(`      {"name": "api", "type": "http", "target": "https://api.example.com/health", "expect_status": [200], "body_match": "ok", "timeout": 3},`)
+	fmt.Println(`      {"name": "cert", "type": "tls", "target": "example.com:443", "interval": 3600, "min_cert_days": 14}`)
+	fmt.Println(`    ],`)
+	fmt.Println(`    "tags": ["edge", "cn-east"],`)
+	fmt.Println(`    "plugins": [`)
+	fmt.Println(`      {"name": "queue", "command": "/opt/scripts/queue-depth.sh", "format": "json", "interval": 30, "timeout": 5},`)
+	fmt.Println(`      {"name": "app", "command": "/usr/bin/curl", "args": ["-s", "http://127.0.0.1:8080/metrics"], "format": "prometheus"}`)
+	fmt.Println(`    ],`)
+	fmt.Println(`    "statsd": {"listen": "127.0.0.1:8125", "percentiles": [50, 90, 99], "max_metrics": 1000},`)
+	fmt.Println(`    "logs": [`)
+	fmt.Println(`      {"name": "app", "path": "/var/log/app/app.log", "patterns": [{"name": "error", "regex": "\\bERROR\\b"}], "keep_lines": 5},`)
+	fmt.Println(`      {"name": "kernel", "path": "/var/log/kern.log", "patterns": [{"name": "oom", "regex": "Out of memory|oom-kill"}]}`)
+	fmt.Println(`    ],`)
+	fmt.Println(`    "disk_filter": {`)
+	fmt.Println(`      "fstype_exclude": "^(tmpfs|overlay|squashfs)$",`)
+	fmt.Println(`      "mount_exclude": "^/(dev|proc|sys|run)($|/)"`)
+	fmt.Println(`    },`)
+	fmt.Println(`    "net_filter": {`)
+	fmt.Println(`      "exclude_kinds": ["loopback", "veth", "bridge", "tun"],`)
+	fmt.Println(`      "name_exclude": "^(cali|flannel)"`)
+	fmt.Println(`    }`)
 	fmt.Println(`  }`)
 	fmt.Println()
 	fmt.Println("前后端分离架构说明 | Frontend-Backend Separation Architecture:")
@@ -986,93 +1155,6 @@ func generateAccessKey(serverBaseURL string) string {
 	return accessKeyResponse.AccessKey
 }
 
-// collectNetworkInfo 收集详细的网络信息
-func collectNetworkInfo() NetInfo {
-	var netInfo NetInfo
-	currentTime := time.Now()
-	
-	// 获取总的网络统计信息
-	allStats, err := psnet.IOCounters(false)
-	if err == nil && len(allStats) > 0 {
-		netInfo.BytesSent = allStats[0].BytesSent
-		netInfo.BytesRecv = allStats[0].BytesRecv
-		netInfo.PacketsSent = allStats[0].PacketsSent
-		netInfo.PacketsRecv = allStats[0].PacketsRecv
-	}
-	
-	// 获取各个网卡的详细信息
-	perInterfaceStats, err := psnet.IOCounters(true)
-	if err == nil {
-		// 初始化lastNetworkStats映射
-		if lastNetworkStats == nil {
-			lastNetworkStats = make(map[string]psnet.IOCountersStat)
-		}
-		
-		for _, stat := range perInterfaceStats {
-			// 跳过回环接口和无流量的接口
-			if stat.Name == "lo" || stat.Name == "Loopback" ||
-				(stat.BytesSent == 0 && stat.BytesRecv == 0) {
-				continue
-			}
-			
-			netInterface := NetInterface{
-				Name:        stat.Name,
-				BytesSent:   stat.BytesSent,
-				BytesRecv:   stat.BytesRecv,
-				PacketsSent: stat.PacketsSent,
-				PacketsRecv: stat.PacketsRecv,
-				IsUp:        true, // gopsutil不直接提供状态，默认为true
-			}
-			
-			// 计算网速（如果有之前的数据）
-			if lastStat, exists := lastNetworkStats[stat.Name]; exists && !lastStatsTime.IsZero() {
-				timeDiff := currentTime.Sub(lastStatsTime).Seconds()
-				if timeDiff > 0 {
-					bytesSentDiff := stat.BytesSent - lastStat.BytesSent
-					bytesRecvDiff := stat.BytesRecv - lastStat.BytesRecv
-					
-					// 计算速率 (KB/s)
-					netInterface.SpeedSent = float64(bytesSentDiff) / timeDiff / 1024
-					netInterface.SpeedRecv = float64(bytesRecvDiff) / timeDiff / 1024
-					
-					// 累加到总速率
-					netInfo.SpeedSent += netInterface.SpeedSent
-					netInfo.SpeedRecv += netInterface.SpeedRecv
-				}
-			}
-			
-			// 获取IP地址和其他接口信息
-			interfaces, err := net.Interfaces()
-			if err == nil {
-				for _, iface := range interfaces {
-					if iface.Name == stat.Name {
-						netInterface.MTU = iface.MTU
-						
-						// 获取IP地址
-						addrs, err := iface.Addrs()
-						if err == nil {
-							for _, addr := range addrs {
-								netInterface.Addrs = append(netInterface.Addrs, addr.String())
-							}
-						}
-						break
-					}
-				}
-			}
-			
-			netInfo.Interfaces = append(netInfo.Interfaces, netInterface)
-			
-			// 更新缓存
-			lastNetworkStats[stat.Name] = stat
-		}
-	}
-	
-	// 更新时间戳
-	lastStatsTime = currentTime
-	
-	return netInfo
-}
-
 // min 返回两个整数中的较小值
 func min(a, b int) int {
 	if a < b {
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"time"
+
+	"github.com/shirou/gopsutil/v3/mem"
+)
+
+// collectMemory 采集物理内存、交换分区和大页信息，换入换出速率按与上次采集的差值计算
+func collectMemory(info *MemInfo) {
+	vm, err := mem.VirtualMemory()
+	if err == nil {
+		info.Total = vm.Total
+		info.Used = vm.Used
+		info.Free = vm.Free
+		info.UsagePercent = vm.UsedPercent
+		info.Available = vm.Available
+		info.Buffers = vm.Buffers
+		info.Cached = vm.Cached
+		info.Shared = vm.Shared
+		info.HugePagesTotal = vm.HugePagesTotal
+		info.HugePagesFree = vm.HugePagesFree
+		info.HugePageSize = vm.HugePageSize
+	}
+
+	swap, err := mem.SwapMemory()
+	if err != nil {
+		return
+	}
+	info.SwapTotal = swap.Total
+	info.SwapUsed = swap.Used
+	info.SwapFree = swap.Free
+	info.SwapPercent = swap.UsedPercent
+
+	currentTime := time.Now()
+	if !lastSwapTime.IsZero() {
+		if timeDiff := currentTime.Sub(lastSwapTime).Seconds(); timeDiff > 0 {
+			// Sin/Sout 为累计换入换出字节数，计数变小（重启）时跳过本次计算
+			if swap.Sin >= lastSwapIn {
+				info.SwapInSpeed = float64(swap.Sin-lastSwapIn) / timeDiff / 1024
+			}
+			if swap.Sout >= lastSwapOut {
+				info.SwapOutSpeed = float64(swap.Sout-lastSwapOut) / timeDiff / 1024
+			}
+		}
+	}
+	lastSwapIn, lastSwapOut, lastSwapTime = swap.Sin, swap.Sout, currentTime
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"log"
+	"net"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	psnet "github.com/shirou/gopsutil/v3/net"
+)
+
+// 网卡类型
+const (
+	ifaceKindPhysical = "physical"
+	ifaceKindLoopback = "loopback"
+	ifaceKindBridge   = "bridge"
+	ifaceKindBond     = "bond"
+	ifaceKindVLAN     = "vlan"
+	ifaceKindVeth     = "veth"
+	ifaceKindTun      = "tun"
+	ifaceKindVirtual  = "virtual"
+)
+
+// defaultNetExcludeKinds 未配置时排除回环网卡和veth
+// Docker主机上每启动一个容器就多一个随机名称的veth，其流量已经计入网桥和物理网卡
+var defaultNetExcludeKinds = []string{ifaceKindLoopback, ifaceKindVeth}
+
+// NetFilter 网卡过滤规则，被排除的网卡不上报明细也不计入总速率
+type NetFilter struct {
+	ExcludeKinds []string `json:"exclude_kinds,omitempty"` // 排除的网卡类型，如 ["loopback", "veth", "bridge", "tun"]
+	NameInclude  string   `json:"name_include,omitempty"`  // 网卡名正则，为空表示全部
+	NameExclude  string   `json:"name_exclude,omitempty"`  // 排除的网卡名正则
+}
+
+// netMatcher 编译后的过滤规则
+type netMatcher struct {
+	excludeKinds             map[string]bool
+	nameInclude, nameExclude *regexp.Regexp
+}
+
+var (
+	netFilter     *netMatcher
+	netFilterOnce sync.Once
+)
+
+func compileNetFilter(filter NetFilter) *netMatcher {
+	compile := func(name, expr string) *regexp.Regexp {
+		if expr == "" {
+			return nil
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			log.Printf("网卡过滤规则 %s 无效，已忽略 | Invalid network filter %s ignored: %v", name, name, err)
+			return nil
+		}
+		return re
+	}
+
+	kinds := filter.ExcludeKinds
+	if kinds == nil {
+		kinds = defaultNetExcludeKinds
+	}
+	m := &netMatcher{
+		excludeKinds: make(map[string]bool),
+		nameInclude:  compile("name_include", filter.NameInclude),
+		nameExclude:  compile("name_exclude", filter.NameExclude),
+	}
+	for _, kind := range kinds {
+		m.excludeKinds[kind] = true
+	}
+	return m
+}
+
+func (m *netMatcher) match(name, kind string) bool {
+	if m.excludeKinds[kind] {
+		return false
+	}
+	if m.nameInclude != nil && !m.nameInclude.MatchString(name) {
+		return false
+	}
+	if m.nameExclude != nil && m.nameExclude.MatchString(name) {
+		return false
+	}
+	return true
+}
+
+// collectNetworkInfo 收集详细的网络信息，每次采集只读取一次计数器和网卡列表
+// 总字节数和包数包含所有网卡，速率只累加未被过滤的网卡
+func collectNetworkInfo() NetInfo {
+	netFilterOnce.Do(func() {
+		netFilter = compileNetFilter(config.NetFilter)
+	})
+
+	var netInfo NetInfo
+	currentTime := time.Now()
+
+	perInterfaceStats, err := psnet.IOCounters(true)
+	if err != nil {
+		return netInfo
+	}
+
+	interfaces := make(map[string]net.Interface)
+	if list, err := net.Interfaces(); err == nil {
+		for _, iface := range list {
+			interfaces[iface.Name] = iface
+		}
+	}
+
+	if lastNetworkStats == nil {
+		lastNetworkStats = make(map[string]psnet.IOCountersStat)
+	}
+	timeDiff := 0.0
+	if !lastStatsTime.IsZero() {
+		timeDiff = currentTime.Sub(lastStatsTime).Seconds()
+	}
+	current := make(map[string]psnet.IOCountersStat, len(perInterfaceStats))
+
+	for _, stat := range perInterfaceStats {
+		netInfo.BytesSent += stat.BytesSent
+		netInfo.BytesRecv += stat.BytesRecv
+		netInfo.PacketsSent += stat.PacketsSent
+		netInfo.PacketsRecv += stat.PacketsRecv
+		current[stat.Name] = stat
+
+		iface, known := interfaces[stat.Name]
+		kind := interfaceKind(stat.Name, iface)
+		// 跳过被过滤和从未有过流量的网卡
+		if !netFilter.match(stat.Name, kind) || (stat.BytesSent == 0 && stat.BytesRecv == 0) {
+			continue
+		}
+
+		netInterface := NetInterface{
+			Name:        stat.Name,
+			BytesSent:   stat.BytesSent,
+			BytesRecv:   stat.BytesRecv,
+			PacketsSent: stat.PacketsSent,
+			PacketsRecv: stat.PacketsRecv,
+			Kind:        kind,
+			ErrIn:       stat.Errin,
+			ErrOut:      stat.Errout,
+			DropIn:      stat.Dropin,
+			DropOut:     stat.Dropout,
+		}
+		if known {
+			netInterface.MTU = iface.MTU
+			netInterface.IsUp = iface.Flags&net.FlagUp != 0
+			if addrs, err := iface.Addrs(); err == nil {
+				for _, addr := range addrs {
+					netInterface.Addrs = append(netInterface.Addrs, addr.String())
+				}
+			}
+		}
+		readLinkState(&netInterface)
+
+		// 计算速率（如果有之前的数据），计数器回绕或网卡重建时为0
+		if lastStat, exists := lastNetworkStats[stat.Name]; exists && timeDiff > 0 {
+			rate := func(now, prev uint64) float64 {
+				if now < prev {
+					return 0
+				}
+				return float64(now-prev) / timeDiff
+			}
+			netInterface.SpeedSent = rate(stat.BytesSent, lastStat.BytesSent) / 1024
+			netInterface.SpeedRecv = rate(stat.BytesRecv, lastStat.BytesRecv) / 1024
+			netInterface.ErrInRate = rate(stat.Errin, lastStat.Errin)
+			netInterface.ErrOutRate = rate(stat.Errout, lastStat.Errout)
+			netInterface.DropInRate = rate(stat.Dropin, lastStat.Dropin)
+			netInterface.DropOutRate = rate(stat.Dropout, lastStat.Dropout)
+
+			// 累加到总速率
+			netInfo.SpeedSent += netInterface.SpeedSent
+			netInfo.SpeedRecv += netInterface.SpeedRecv
+		}
+
+		netInfo.Interfaces = append(netInfo.Interfaces, netInterface)
+	}
+
+	lastNetworkStats = current
+	lastStatsTime = currentTime
+	return netInfo
+}
+
+// interfaceKind 根据 /sys/class/net 判断网卡类型，非Linux系统只区分回环网卡
+func interfaceKind(name string, iface net.Interface) string {
+	if iface.Flags&net.FlagLoopback != 0 || name == "lo" {
+		return ifaceKindLoopback
+	}
+	if runtime.GOOS != "linux" {
+		return ifaceKindPhysical
+	}
+
+	exists := func(file string) bool {
+		_, err := os.Stat(hostSys("class", "net", name, file))
+		return err == nil
+	}
+	switch {
+	case exists("bridge"):
+		return ifaceKindBridge
+	case exists("bonding"):
+		return ifaceKindBond
+	case exists("tun_flags"):
+		return ifaceKindTun
+	}
+	if devType := readUevent(name)["DEVTYPE"]; devType == "vlan" {
+		return ifaceKindVLAN
+	} else if devType == "wireguard" {
+		return ifaceKindTun
+	}
+	if exists("device") {
+		return ifaceKindPhysical
+	}
+	// veth的对端在其他网络命名空间，iflink与ifindex不同
+	ifindex, _ := readSysNet(name, "ifindex")
+	iflink, _ := readSysNet(name, "iflink")
+	if strings.HasPrefix(name, "veth") || (ifindex != "" && iflink != "" && ifindex != iflink) {
+		return ifaceKindVeth
+	}
+	return ifaceKindVirtual
+}
+
+// readLinkState 读取网卡的运行状态、协商速率和双工模式（仅Linux）
+// operstate为unknown（如回环和部分虚拟网卡）时以网卡的UP标志为准
+func readLinkState(iface *NetInterface) {
+	if runtime.GOOS != "linux" {
+		return
+	}
+	if state, err := readSysNet(iface.Name, "operstate"); err == nil {
+		iface.OperState = state
+		if state != "unknown" {
+			iface.IsUp = state == "up"
+		}
+	}
+	// 网卡未连接时读取speed会返回错误或-1
+	if speed, err := readSysNet(iface.Name, "speed"); err == nil {
+		if v, err := strconv.Atoi(speed); err == nil && v > 0 {
+			iface.LinkSpeed = v
+		}
+	}
+	if duplex, err := readSysNet(iface.Name, "duplex"); err == nil && duplex != "unknown" {
+		iface.Duplex = duplex
+	}
+}
+
+func readSysNet(name, file string) (string, error) {
+	data, err := os.ReadFile(hostSys("class", "net", name, file))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func readUevent(name string) map[string]string {
+	values := make(map[string]string)
+	data, err := readSysNet(name, "uevent")
+	if err != nil {
+		return values
+	}
+	for _, line := range strings.Split(data, "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			values[key] = value
+		}
+	}
+	return values
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNetFilter(t *testing.T) {
+	decode := func(raw string) NetFilter {
+		var filter NetFilter
+		if err := json.Unmarshal([]byte(raw), &filter); err != nil {
+			t.Fatal(err)
+		}
+		return filter
+	}
+
+	type iface struct{ name, kind string }
+	tests := []struct {
+		name     string
+		filter   string
+		included []iface
+		excluded []iface
+	}{
+		{"default", `{}`,
+			[]iface{{"eth0", ifaceKindPhysical}, {"docker0", ifaceKindBridge}, {"wg0", ifaceKindTun}},
+			[]iface{{"lo", ifaceKindLoopback}, {"veth1a2b3c", ifaceKindVeth}}},
+		{"nothing excluded", `{"exclude_kinds": []}`,
+			[]iface{{"lo", ifaceKindLoopback}, {"veth1a2b3c", ifaceKindVeth}}, nil},
+		{"by kind and name", `{"exclude_kinds": ["bridge"], "name_include": "^(eth|en|veth)", "name_exclude": "^eth1$"}`,
+			[]iface{{"eth0", ifaceKindPhysical}, {"veth1a2b3c", ifaceKindVeth}},
+			[]iface{{"docker0", ifaceKindBridge}, {"eth1", ifaceKindPhysical}, {"wg0", ifaceKindTun}}},
+		{"invalid regex ignored", `{"name_include": "("}`, []iface{{"eth0", ifaceKindPhysical}}, nil},
+	}
+	for _, tt := range tests {
+		m := compileNetFilter(decode(tt.filter))
+		for _, i := range tt.included {
+			if !m.match(i.name, i.kind) {
+				t.Errorf("%s: %s (%s) excluded", tt.name, i.name, i.kind)
+			}
+		}
+		for _, i := range tt.excluded {
+			if m.match(i.name, i.kind) {
+				t.Errorf("%s: %s (%s) included", tt.name, i.name, i.kind)
+			}
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// ICMP报文类型
+const (
+	icmpv4EchoRequest = 8
+	icmpv4EchoReply   = 0
+	icmpv6EchoRequest = 128
+	icmpv6EchoReply   = 129
+)
+
+// pingID 每次ping使用不同的标识，避免并发的ping检查互相收到对方的回复
+var pingID uint32
+
+// 系统ping命令输出中的回复行和往返时间，如 "ttl=64 time=0.045 ms"、"时间<1ms TTL=128"
+// 只看带TTL的回复行，Windows的统计行 "平均 = 1ms" 不计入
+var (
+	pingReplyLine = regexp.MustCompile(`(?im)^.*ttl=.*$`)
+	pingTimeValue = regexp.MustCompile(`[=<]\s*([\d.]+)\s*ms`)
+)
+
+// pingHost 发送count个ICMP echo请求，返回平均往返时间和丢包率（%）
+// 优先使用原始套接字（需要root或CAP_NET_RAW），无权限时调用系统的ping命令
+func pingHost(host string, count int, timeout time.Duration) (time.Duration, float64, error) {
+	addr, err := net.ResolveIPAddr("ip", host)
+	if err != nil {
+		return 0, 100, err
+	}
+
+	network, request, reply := "ip4:icmp", icmpv4EchoRequest, icmpv4EchoReply
+	if addr.IP.To4() == nil {
+		network, request, reply = "ip6:ipv6-icmp", icmpv6EchoRequest, icmpv6EchoReply
+	}
+	conn, err := net.ListenPacket(network, "")
+	if err != nil {
+		return pingCommand(addr.String(), count, timeout)
+	}
+	defer conn.Close()
+
+	id := uint16(os.Getpid()) ^ uint16(atomic.AddUint32(&pingID, 1))
+	wait := timeout / time.Duration(count)
+	var total time.Duration
+	received := 0
+	for seq := 0; seq < count; seq++ {
+		if rtt, err := pingOnce(conn, addr, request, reply, id, uint16(seq), wait); err == nil {
+			total += rtt
+			received++
+		}
+	}
+
+	loss := float64(count-received) / float64(count) * 100
+	if received == 0 {
+		return 0, loss, fmt.Errorf("%s 无响应", host)
+	}
+	return total / time.Duration(received), loss, nil
+}
+
+// pingOnce 发送一个echo请求并等待对应的回复，原始套接字会收到所有ICMP报文，按标识和序号过滤
+func pingOnce(conn net.PacketConn, addr *net.IPAddr, request, reply int, id, seq uint16, wait time.Duration) (time.Duration, error) {
+	msg := make([]byte, 16)
+	msg[0] = byte(request)
+	binary.BigEndian.PutUint16(msg[4:], id)
+	binary.BigEndian.PutUint16(msg[6:], seq)
+	binary.BigEndian.PutUint64(msg[8:], uint64(time.Now().UnixNano()))
+	if request == icmpv4EchoRequest {
+		// ICMPv6的校验和由内核计算
+		binary.BigEndian.PutUint16(msg[2:], icmpChecksum(msg))
+	}
+
+	start := time.Now()
+	if _, err := conn.WriteTo(msg, addr); err != nil {
+		return 0, err
+	}
+	conn.SetReadDeadline(start.Add(wait))
+
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return 0, err
+		}
+		if n >= 8 && int(buf[0]) == reply &&
+			binary.BigEndian.Uint16(buf[4:]) == id && binary.BigEndian.Uint16(buf[6:]) == seq {
+			return time.Since(start), nil
+		}
+	}
+}
+
+func icmpChecksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+// pingCommand 调用系统的ping命令（通常带有setuid或网络权限），解析每个回复的往返时间
+func pingCommand(host string, count int, timeout time.Duration) (time.Duration, float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout+time.Second)
+	defer cancel()
+
+	countFlag := "-c"
+	if runtime.GOOS == "windows" {
+		countFlag = "-n"
+	}
+	output, err := exec.CommandContext(ctx, "ping", countFlag, strconv.Itoa(count), host).CombinedOutput()
+	if err != nil && len(output) == 0 {
+		return 0, 100, fmt.Errorf("执行ping失败: %v", err)
+	}
+
+	avg, received := parsePingOutput(output, count)
+	loss := float64(count-received) / float64(count) * 100
+	if received == 0 {
+		return 0, loss, fmt.Errorf("%s 无响应", host)
+	}
+	return avg, loss, nil
+}
+
+// parsePingOutput 从ping命令的输出中解析最多count个回复，返回平均往返时间和回复数
+// 只依赖ASCII的 ttl= 和 ms，与系统语言和编码（如中文Windows的GBK输出）无关
+func parsePingOutput(output []byte, count int) (time.Duration, int) {
+	var total time.Duration
+	received := 0
+	for _, line := range pingReplyLine.FindAll(output, -1) {
+		match := pingTimeValue.FindSubmatch(line)
+		if match == nil {
+			continue
+		}
+		if ms, err := strconv.ParseFloat(string(match[1]), 64); err == nil && received < count {
+			total += time.Duration(ms * float64(time.Millisecond))
+			received++
+		}
+	}
+	if received == 0 {
+		return 0, 0
+	}
+	return total / time.Duration(received), received
+}
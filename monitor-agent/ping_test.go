@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePingOutput(t *testing.T) {
+	// gbk 将测试中的中文转为中文Windows控制台输出的GBK编码
+	gbk := strings.NewReplacer("来自", "\xc0\xb4\xd7\xd4", "的回复", "\xb5\xc4\xbb\xd8\xb8\xb4", "字节", "\xd7\xd6\xbd\xda",
+		"时间", "\xca\xb1\xbc\xe4", "请求超时。", "\xc7\xeb\xc7\xf3\xb3\xac\xca\xb1\xa1\xa3", "平均", "\xc6\xbd\xbe\xf9",
+		"最短", "\xd7\xee\xb6\xcc", "最长", "\xd7\xee\xb3\xa4")
+
+	tests := []struct {
+		name     string
+		output   string
+		count    int
+		avg      time.Duration
+		received int
+	}{
+		{"linux iputils", `PING 127.0.0.1 (127.0.0.1) 56(84) bytes of data.
+64 bytes from 127.0.0.1: icmp_seq=1 ttl=64 time=0.040 ms
+64 bytes from 127.0.0.1: icmp_seq=2 ttl=64 time=0.060 ms
+64 bytes from 127.0.0.1: icmp_seq=3 ttl=64 time=0.050 ms
+
+--- 127.0.0.1 ping statistics ---
+3 packets transmitted, 3 received, 0% packet loss, time 2035ms
+rtt min/avg/max/mdev = 0.040/0.050/0.060/0.008 ms
+`, 3, 50 * time.Microsecond, 3},
+		{"linux partial loss", `PING 10.0.0.9 (10.0.0.9) 56(84) bytes of data.
+64 bytes from 10.0.0.9: icmp_seq=2 ttl=63 time=31.4 ms
+From 10.0.0.1 icmp_seq=3 Destination Host Unreachable
+
+--- 10.0.0.9 ping statistics ---
+3 packets transmitted, 1 received, +1 errors, 66.6667% packet loss, time 2003ms
+rtt min/avg/max/mdev = 31.400/31.400/31.400/0.000 ms
+`, 3, 31400 * time.Microsecond, 1},
+		{"busybox", `PING 127.0.0.1 (127.0.0.1): 56 data bytes
+64 bytes from 127.0.0.1: seq=0 ttl=64 time=0.071 ms
+64 bytes from 127.0.0.1: seq=1 ttl=64 time=0.129 ms
+`, 2, 100 * time.Microsecond, 2},
+		{"windows english", `
+Pinging 10.0.0.9 with 32 bytes of data:
+Reply from 10.0.0.9: bytes=32 time=12ms TTL=128
+Reply from 10.0.0.9: bytes=32 time<1ms TTL=128
+Request timed out.
+
+Ping statistics for 10.0.0.9:
+    Packets: Sent = 3, Received = 2, Lost = 1 (33% loss),
+Approximate round trip times in milli-seconds:
+    Minimum = 0ms, Maximum = 12ms, Average = 6ms
+`, 3, 6500 * time.Microsecond, 2},
+		{"windows chinese", gbk.Replace(`
+正在 Ping 127.0.0.1 具有 32 字节的数据:
+来自 127.0.0.1 的回复: 字节=32 时间=2ms TTL=128
+来自 127.0.0.1 的回复: 字节=32 时间<1ms TTL=128
+请求超时。
+
+    最短 = 1ms，最长 = 2ms，平均 = 1ms
+`), 3, 1500 * time.Microsecond, 2},
+		{"windows unreachable", `Reply from 10.0.0.1: Destination host unreachable.
+Request timed out.
+`, 2, 0, 0},
+		{"more replies than requested", `64 bytes from 127.0.0.1: icmp_seq=1 ttl=64 time=1 ms
+64 bytes from 127.0.0.1: icmp_seq=1 ttl=64 time=3 ms (DUP!)
+`, 1, time.Millisecond, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Windows输出为CRLF，行尾的\r不能影响解析
+			avg, received := parsePingOutput([]byte(strings.ReplaceAll(tt.output, "\n", "\r\n")), tt.count)
+			if avg != tt.avg || received != tt.received {
+				t.Errorf("avg %v received %d, want %v %d", avg, received, tt.avg, tt.received)
+			}
+		})
+	}
+}
+
+func TestPingLoopback(t *testing.T) {
+	t.Run("raw socket", func(t *testing.T) {
+		conn, err := net.ListenPacket("ip4:icmp", "")
+		if err != nil {
+			t.Skipf("原始套接字不可用: %v", err)
+		}
+		conn.Close()
+		avg, loss, err := pingHost("127.0.0.1", 2, 2*time.Second)
+		if err != nil || loss != 0 || avg <= 0 {
+			t.Errorf("pingHost = %v, %v, %v", avg, loss, err)
+		}
+	})
+	t.Run("ping command", func(t *testing.T) {
+		if out, err := exec.Command("ping", "-c", "1", "127.0.0.1").CombinedOutput(); err != nil {
+			t.Skipf("系统ping不可用: %v %s", err, out)
+		}
+		avg, loss, err := pingCommand("127.0.0.1", 2, 3*time.Second)
+		if err != nil || loss != 0 || avg <= 0 {
+			t.Errorf("pingCommand = %v, %v, %v", avg, loss, err)
+		}
+	})
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 插件输出格式
+const (
+	pluginFormatJSON       = "json"
+	pluginFormatPrometheus = "prometheus"
+)
+
+const (
+	defaultPluginTimeout = 10      // 秒
+	maxPluginOutput      = 1 << 20 // 最多读取的标准输出
+	maxPluginMetrics     = 500     // 每个插件最多上报的指标数
+)
+
+// PluginConfig 自定义指标插件，按间隔执行外部程序，从标准输出解析指标
+type PluginConfig struct {
+	Name     string   `json:"name"`
+	Command  string   `json:"command"`            // 可执行文件路径，不经过shell
+	Args     []string `json:"args,omitempty"`     // 命令参数
+	Format   string   `json:"format,omitempty"`   // json / prometheus，为空时按输出内容判断
+	Interval int      `json:"interval,omitempty"` // 执行间隔（秒），默认与上报间隔相同
+	Timeout  int      `json:"timeout,omitempty"`  // 超时（秒），默认10，超时后终止进程
+}
+
+var (
+	pluginResults   = make(map[string]PluginResult)
+	pluginResultsMu sync.Mutex
+	plugins         []PluginConfig
+)
+
+// startPlugins 校验配置并为每个插件启动后台任务，启动时调用一次
+func startPlugins() {
+	seen := make(map[string]bool)
+	for _, cfg := range config.Plugins {
+		if cfg.Name == "" || cfg.Command == "" {
+			log.Printf("插件缺少name或command，已忽略 | Plugin without name or command ignored: %q", cfg.Name)
+			continue
+		}
+		cfg.Format = strings.ToLower(cfg.Format)
+		if cfg.Format != "" && cfg.Format != pluginFormatJSON && cfg.Format != pluginFormatPrometheus {
+			log.Printf("插件 %q 的输出格式 %q 无效，已忽略 | Plugin %q has invalid format %q", cfg.Name, cfg.Format, cfg.Name, cfg.Format)
+			continue
+		}
+		if seen[cfg.Name] {
+			log.Printf("插件名称 %q 重复，已忽略 | Duplicate plugin name %q ignored", cfg.Name, cfg.Name)
+			continue
+		}
+		if cfg.Name == statsdPluginName && config.StatsD.Listen != "" {
+			log.Printf("插件名称 %q 已用于StatsD，已忽略 | Plugin name %q is reserved for StatsD", cfg.Name, cfg.Name)
+			continue
+		}
+		seen[cfg.Name] = true
+		if cfg.Interval <= 0 {
+			cfg.Interval = int(math.Max(1, config.ReportInterval.Seconds()))
+		}
+		if cfg.Timeout <= 0 {
+			cfg.Timeout = defaultPluginTimeout
+		}
+		plugins = append(plugins, cfg)
+		go runPlugin(cfg)
+	}
+	if len(plugins) > 0 {
+		log.Printf("已启动 %d 个插件 | Started %d plugins", len(plugins), len(plugins))
+	}
+}
+
+func runPlugin(cfg PluginConfig) {
+	ticker := time.NewTicker(time.Duration(cfg.Interval) * time.Second)
+	defer ticker.Stop()
+	lastErr := ""
+	for {
+		result := execPlugin(cfg)
+
+		// 错误只在变化时记录，避免每个周期重复输出
+		if result.Error != lastErr && result.Error != "" {
+			log.Printf("插件 %q 执行失败 | Plugin %q failed: %s", cfg.Name, cfg.Name, result.Error)
+		}
+		lastErr = result.Error
+
+		pluginResultsMu.Lock()
+		pluginResults[cfg.Name] = result
+		pluginResultsMu.Unlock()
+
+		<-ticker.C
+	}
+}
+
+// execPlugin 执行一次插件并解析输出，非零退出码视为失败
+func execPlugin(cfg PluginConfig) PluginResult {
+	result := PluginResult{Name: cfg.Name, CollectedAt: time.Now()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Timeout)*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, cfg.Command, cfg.Args...)
+	stdout := &limitedBuffer{limit: maxPluginOutput}
+	stderr := &limitedBuffer{limit: 4096}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// 插件派生的子进程继续持有输出管道时，不无限等待
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err := cmd.Run()
+	result.DurationMs = durationMs(time.Since(start))
+	if ctx.Err() == context.DeadlineExceeded {
+		result.Error = fmt.Sprintf("执行超时（%d 秒）", cfg.Timeout)
+		return result
+	}
+	// 插件已正常退出，只是后台子进程仍持有输出管道
+	if errors.Is(err, exec.ErrWaitDelay) {
+		err = nil
+	}
+	if err != nil {
+		result.Error = err.Error()
+		if msg := firstLine(stderr.String()); msg != "" {
+			result.Error += ": " + msg
+		}
+		return result
+	}
+	if stdout.truncated {
+		result.Error = fmt.Sprintf("输出超过 %d 字节", maxPluginOutput)
+		return result
+	}
+
+	format := cfg.Format
+	if format == "" {
+		format = pluginFormatPrometheus
+		if strings.HasPrefix(strings.TrimSpace(stdout.String()), "{") {
+			format = pluginFormatJSON
+		}
+	}
+	var metrics []CustomMetric
+	if format == pluginFormatJSON {
+		metrics, err = parseJSONMetrics(stdout.Bytes())
+	} else {
+		metrics, err = parsePrometheusMetrics(stdout.String())
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if len(metrics) > maxPluginMetrics {
+		result.Error = fmt.Sprintf("指标数超过 %d，只保留前 %d 个", maxPluginMetrics, maxPluginMetrics)
+		metrics = metrics[:maxPluginMetrics]
+	}
+	result.Metrics = metrics
+	return result
+}
+
+// parseJSONMetrics 解析JSON对象，数值和布尔值为指标，嵌套对象的键用 . 连接，其他类型忽略
+// 如 {"queue": {"depth": 12, "workers": 4}, "healthy": true}
+func parseJSONMetrics(data []byte) ([]CustomMetric, error) {
+	var root map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&root); err != nil {
+		return nil, fmt.Errorf("解析JSON输出失败: %v", err)
+	}
+
+	var metrics []CustomMetric
+	var walk func(prefix string, obj map[string]interface{})
+	walk = func(prefix string, obj map[string]interface{}) {
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			name := prefix + key
+			switch v := obj[key].(type) {
+			case json.Number:
+				if f, err := v.Float64(); err == nil {
+					metrics = append(metrics, CustomMetric{Name: name, Value: f})
+				}
+			case bool:
+				value := 0.0
+				if v {
+					value = 1
+				}
+				metrics = append(metrics, CustomMetric{Name: name, Value: value})
+			case map[string]interface{}:
+				walk(name+".", v)
+			}
+		}
+	}
+	walk("", root)
+	return metrics, nil
+}
+
+// parsePrometheusMetrics 解析Prometheus文本格式，保留标签和TYPE声明的类型，忽略时间戳和非有限值
+func parsePrometheusMetrics(text string) ([]CustomMetric, error) {
+	types := make(map[string]string)
+	var metrics []CustomMetric
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			if fields := strings.Fields(line); len(fields) >= 4 && fields[1] == "TYPE" {
+				types[fields[2]] = fields[3]
+			}
+			continue
+		}
+
+		metric, err := parsePrometheusSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行格式错误: %v", i+1, err)
+		}
+		// NaN和Inf无法编码为JSON
+		if math.IsNaN(metric.Value) || math.IsInf(metric.Value, 0) {
+			continue
+		}
+		switch types[metric.Name] {
+		case "counter", "gauge":
+			metric.Type = types[metric.Name]
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics, nil
+}
+
+// parsePrometheusSample 解析一行样本，如 `queue_depth{queue="orders"} 12 1700000000000`
+func parsePrometheusSample(line string) (CustomMetric, error) {
+	var metric CustomMetric
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return metric, fmt.Errorf("缺少数值")
+	}
+	metric.Name = line[:end]
+	rest := line[end:]
+
+	if rest[0] == '{' {
+		labels, n, err := parsePrometheusLabels(rest)
+		if err != nil {
+			return metric, err
+		}
+		if len(labels) > 0 {
+			metric.Labels = labels
+		}
+		rest = rest[n:]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return metric, fmt.Errorf("缺少数值")
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return metric, fmt.Errorf("无效的数值 %q", fields[0])
+	}
+	metric.Value = value
+	return metric, nil
+}
+
+// parsePrometheusLabels 解析 {name="value",...}，返回标签和消耗的字节数
+func parsePrometheusLabels(s string) (map[string]string, int, error) {
+	labels := make(map[string]string)
+	i := 1
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == ',') {
+			i++
+		}
+		if i >= len(s) {
+			return nil, 0, fmt.Errorf("标签未闭合")
+		}
+		if s[i] == '}' {
+			return labels, i + 1, nil
+		}
+
+		eq := strings.IndexByte(s[i:], '=')
+		if eq <= 0 || i+eq+1 >= len(s) || s[i+eq+1] != '"' {
+			return nil, 0, fmt.Errorf("标签格式错误")
+		}
+		name := strings.TrimSpace(s[i : i+eq])
+		i += eq + 2
+
+		var value strings.Builder
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				if s[i] == 'n' {
+					value.WriteByte('\n')
+					continue
+				}
+			}
+			value.WriteByte(s[i])
+		}
+		if i >= len(s) {
+			return nil, 0, fmt.Errorf("标签值未闭合")
+		}
+		i++
+		labels[name] = value.String()
+	}
+}
+
+// collectPlugins 返回各插件最近一次的结果，顺序与配置一致，尚未完成首次执行的不返回
+func collectPlugins() []PluginResult {
+	if len(plugins) == 0 {
+		return nil
+	}
+	pluginResultsMu.Lock()
+	defer pluginResultsMu.Unlock()
+	results := make([]PluginResult, 0, len(plugins))
+	for _, cfg := range plugins {
+		if result, ok := pluginResults[cfg.Name]; ok {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// limitedBuffer 超过上限后丢弃后续输出，避免插件输出过多占用内存
+// 不嵌入bytes.Buffer：io.Copy会优先使用其ReadFrom，绕过Write中的上限
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) Bytes() []byte  { return b.buf.Bytes() }
+func (b *limitedBuffer) String() string { return b.buf.String() }
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(line)
+}
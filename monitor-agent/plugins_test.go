@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseJSONMetrics(t *testing.T) {
+	metrics, err := parseJSONMetrics([]byte(`{"queue": {"depth": 12, "workers": {"busy": 3}}, "healthy": true, "down": false, "version": "1.2", "tags": [1, 2], "ratio": 1e-3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "down=0 healthy=1 queue.depth=12 queue.workers.busy=3 ratio=0.001"
+	if got := formatMetrics(metrics); got != want {
+		t.Errorf("metrics = %s, want %s", got, want)
+	}
+
+	for _, input := range []string{"", "[1, 2]", `{"a": 1`} {
+		if _, err := parseJSONMetrics([]byte(input)); err == nil {
+			t.Errorf("parseJSONMetrics(%q) accepted invalid input", input)
+		}
+	}
+}
+
+func formatMetrics(metrics []CustomMetric) string {
+	parts := make([]string, len(metrics))
+	for i, m := range metrics {
+		parts[i] = fmt.Sprintf("%s=%v", m.Name, m.Value)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParsePrometheusMetrics(t *testing.T) {
+	text := strings.Join([]string{
+		"# HELP requests_total Requests.",
+		"# TYPE requests_total counter",
+		`requests_total{code="200",method="GET"} 1027 1700000000000`,
+		`requests_total{code="500",} 3`,
+		"# TYPE queue_depth gauge",
+		"queue_depth 12",
+		"# TYPE latency summary",
+		`latency{quantile="0.99"} 0.25`,
+		`errors{msg="say \"hi\"\nbye",path="C:\\tmp"} 1`,
+		"temperature NaN",
+		"overflow +Inf",
+		"underflow -Inf",
+		"",
+	}, "\n")
+	metrics, err := parsePrometheusMetrics(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 5 {
+		t.Fatalf("metrics = %+v, want 5 (non-finite values skipped)", metrics)
+	}
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		value  float64
+		typ    string
+	}{
+		{"requests_total", map[string]string{"code": "200", "method": "GET"}, 1027, "counter"},
+		{"requests_total", map[string]string{"code": "500"}, 3, "counter"},
+		{"queue_depth", nil, 12, "gauge"},
+		{"latency", map[string]string{"quantile": "0.99"}, 0.25, ""},
+		{"errors", map[string]string{"msg": "say \"hi\"\nbye", "path": `C:\tmp`}, 1, ""},
+	}
+	for i, tt := range tests {
+		m := metrics[i]
+		if m.Name != tt.name || m.Value != tt.value || m.Type != tt.typ || fmt.Sprint(m.Labels) != fmt.Sprint(tt.labels) {
+			t.Errorf("metric %d = %+v, want %+v", i, m, tt)
+		}
+	}
+
+	for _, line := range []string{"no_value", `bad{code="200} 1`, `bad{code=200} 1`, "bad{", "bad abc", "bad 1 2 3"} {
+		if _, err := parsePrometheusMetrics(line); err == nil {
+			t.Errorf("parsePrometheusMetrics(%q) accepted invalid input", line)
+		}
+	}
+}
+
+// testPlugin 通过测试脚本执行插件
+func testPlugin(t *testing.T, mode string, timeout int) PluginResult {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("测试脚本需要 /bin/sh")
+	}
+	return execPlugin(PluginConfig{Name: mode, Command: "/bin/sh", Args: []string{"testdata/plugin.sh", mode}, Timeout: timeout})
+}
+
+func TestExecPlugin(t *testing.T) {
+	tests := []struct {
+		mode    string
+		metrics string
+		err     string
+	}{
+		{"json", "healthy=1 queue.depth=12 queue.workers=4", ""},
+		{"prometheus", "requests_total=1027", ""},
+		{"large", "", fmt.Sprintf("输出超过 %d 字节", maxPluginOutput)},
+		{"fail", "", "exit status 3: connection refused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			result := testPlugin(t, tt.mode, 5)
+			if result.Error != tt.err {
+				t.Errorf("error = %q, want %q", result.Error, tt.err)
+			}
+			if got := formatMetrics(result.Metrics); got != tt.metrics {
+				t.Errorf("metrics = %s, want %s", got, tt.metrics)
+			}
+			if result.Name != tt.mode || result.DurationMs <= 0 {
+				t.Errorf("result = %+v", result)
+			}
+		})
+	}
+
+	result := testPlugin(t, "prometheus", 5)
+	if m := result.Metrics[0]; m.Type != "counter" || m.Labels["path"] != `/a"b` {
+		t.Errorf("metric = %+v", m)
+	}
+}
+
+func TestExecPluginTimeout(t *testing.T) {
+	start := time.Now()
+	result := testPlugin(t, "slow", 1)
+	if result.Error != "执行超时（1 秒）" || len(result.Metrics) != 0 {
+		t.Errorf("result = %+v, want timeout", result)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("timed out plugin not killed: took %v", elapsed)
+	}
+}
+
+// 插件退出后后台子进程仍持有输出管道，等待WaitDelay后按正常退出处理
+func TestExecPluginBackgroundChild(t *testing.T) {
+	start := time.Now()
+	result := testPlugin(t, "background", 5)
+	if result.Error != "" || formatMetrics(result.Metrics) != "up=1" {
+		t.Errorf("result = %+v, want up=1", result)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("waited %v for the background child", elapsed)
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	b := &limitedBuffer{limit: 4}
+	b.Write([]byte("ab"))
+	if n, err := b.Write([]byte("cdef")); n != 4 || err != nil {
+		t.Errorf("Write = %d, %v, want all bytes accepted", n, err)
+	}
+	if b.String() != "abcd" || !b.truncated {
+		t.Errorf("buffer = %q truncated %v", b.String(), b.truncated)
+	}
+
+	// exec通过io.Copy写入输出，上限同样生效
+	b = &limitedBuffer{limit: 4}
+	if _, err := io.Copy(b, strings.NewReader("abcdef")); err != nil || b.String() != "abcd" || !b.truncated {
+		t.Errorf("io.Copy buffer = %q truncated %v, %v", b.String(), b.truncated, err)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+const (
+	defaultTopProcesses = 5   // 默认上报CPU和内存占用最高的进程数
+	maxCmdlineLength    = 256 // 命令行截断长度（字符）
+)
+
+// processSample 一次采集中单个进程的排序依据
+type processSample struct {
+	proc       *process.Process
+	cpuPercent float64
+	rss        uint64
+}
+
+// collectProcesses 采集进程总数以及CPU、内存占用最高的前n个进程
+// CPU使用率按与上次采集的差值计算（100表示占满一个核），进程首次出现时为0
+func collectProcesses(n int, memTotal uint64) *ProcessStats {
+	pids, err := process.Pids()
+	if err != nil {
+		return nil
+	}
+
+	// 进程对象中保存了上次的CPU时间，需要跨采集周期复用
+	current := make(map[int32]*process.Process, len(pids))
+	samples := make([]processSample, 0, len(pids))
+	for _, pid := range pids {
+		proc := lastProcesses[pid]
+		if proc == nil {
+			if proc, err = process.NewProcess(pid); err != nil {
+				continue
+			}
+		}
+		cpuPercent, err := proc.Percent(0)
+		if err != nil {
+			continue // 进程已退出
+		}
+		if cpuPercent < 0 {
+			cpuPercent = 0 // PID被复用时CPU时间会变小
+		}
+		var rss uint64
+		if memInfo, err := proc.MemoryInfo(); err == nil {
+			rss = memInfo.RSS
+		}
+		current[pid] = proc
+		samples = append(samples, processSample{proc: proc, cpuPercent: cpuPercent, rss: rss})
+	}
+	lastProcesses = current
+
+	stats := &ProcessStats{Total: len(samples)}
+	details := make(map[int32]ProcessInfo)
+	top := func(less func(a, b processSample) bool) []ProcessInfo {
+		sort.SliceStable(samples, func(i, j int) bool { return less(samples[i], samples[j]) })
+		var list []ProcessInfo
+		for i := 0; i < len(samples) && i < n; i++ {
+			s := samples[i]
+			detail, ok := details[s.proc.Pid]
+			if !ok {
+				detail = processDetail(s, memTotal)
+				details[s.proc.Pid] = detail
+			}
+			list = append(list, detail)
+		}
+		return list
+	}
+	stats.TopCPU = top(func(a, b processSample) bool { return a.cpuPercent > b.cpuPercent })
+	stats.TopMemory = top(func(a, b processSample) bool { return a.rss > b.rss })
+	return stats
+}
+
+// processDetail 读取进程的详细信息，只对排名靠前的进程调用，无权限读取的字段留空
+func processDetail(s processSample, memTotal uint64) ProcessInfo {
+	info := ProcessInfo{
+		PID:        s.proc.Pid,
+		CPUPercent: s.cpuPercent,
+		RSS:        s.rss,
+	}
+	if memTotal > 0 {
+		info.MemPercent = float64(s.rss) / float64(memTotal) * 100
+	}
+	info.Name, _ = s.proc.Name()
+	info.User, _ = s.proc.Username()
+	if cmdline, err := s.proc.Cmdline(); err == nil {
+		if runes := []rune(cmdline); len(runes) > maxCmdlineLength {
+			cmdline = string(runes[:maxCmdlineLength]) + "..."
+		}
+		info.Cmdline = cmdline
+	}
+	info.Threads, _ = s.proc.NumThreads()
+	info.OpenFDs, _ = s.proc.NumFDs()
+	return info
+}
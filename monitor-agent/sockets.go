@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"net"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	psnet "github.com/shirou/gopsutil/v3/net"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// socketOwner 监听套接字所属的进程
+type socketOwner struct {
+	pid  int32
+	name string
+}
+
+// collectSockets 统计TCP连接状态、UDP套接字数、监听端口和连接跟踪表
+// Linux下直接读取 /proc/net，不遍历进程的文件描述符，只有出现新的监听端口时才查找所属进程
+func collectSockets(info *NetInfo) {
+	if runtime.GOOS != "linux" {
+		collectSocketsPortable(info)
+		return
+	}
+
+	stats := &SocketStats{}
+	listening := make(map[string]ListenPort)
+	inodes := make(map[uint64]string)
+	ok := false
+	for _, proto := range []string{"tcp", "tcp6"} {
+		err := scanProcNet(proto, func(fields []string) {
+			stats.TCP++
+			switch fields[3] {
+			case "01":
+				stats.Established++
+			case "02":
+				stats.SynSent++
+			case "03":
+				stats.SynRecv++
+			case "04", "05":
+				stats.FinWait++
+			case "06":
+				stats.TimeWait++
+			case "08":
+				stats.CloseWait++
+			case "09":
+				stats.LastAck++
+			case "0A":
+				stats.Listen++
+				address, port, valid := parseHexAddr(fields[1])
+				if !valid {
+					return
+				}
+				// SO_REUSEPORT时同一地址有多个套接字，只记一次
+				key := proto + " " + net.JoinHostPort(address, strconv.Itoa(port))
+				if _, exists := listening[key]; !exists {
+					listening[key] = ListenPort{Protocol: proto, Address: address, Port: port}
+					if inode, err := strconv.ParseUint(fields[9], 10, 64); err == nil && inode > 0 {
+						inodes[inode] = key
+					}
+				}
+			}
+		})
+		ok = ok || err == nil
+	}
+	for _, proto := range []string{"udp", "udp6"} {
+		if scanProcNet(proto, func([]string) { stats.UDP++ }) == nil {
+			ok = true
+		}
+	}
+	if !ok {
+		return
+	}
+	info.Sockets = stats
+
+	resolveSocketOwners(inodes)
+	for inode, key := range inodes {
+		if owner := listenOwners[inode]; owner.pid > 0 {
+			port := listening[key]
+			port.PID, port.Process = owner.pid, owner.name
+			listening[key] = port
+		}
+	}
+	info.Listening = sortListenPorts(listening)
+	info.Conntrack = collectConntrack()
+}
+
+// scanProcNet 逐行解析 /proc/net/tcp 等文件，跳过表头
+func scanProcNet(name string, fn func(fields []string)) error {
+	f, err := os.Open(hostProc("net", name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan()
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) >= 10 {
+			fn(fields)
+		}
+	}
+	return scanner.Err()
+}
+
+// parseHexAddr 解析 "0100007F:0050" 格式的地址，IP按32位分组以主机字节序（小端）存放
+func parseHexAddr(s string) (string, int, bool) {
+	host, portHex, found := strings.Cut(s, ":")
+	if !found {
+		return "", 0, false
+	}
+	ip, err := hex.DecodeString(host)
+	if err != nil || (len(ip) != net.IPv4len && len(ip) != net.IPv6len) {
+		return "", 0, false
+	}
+	port, err := strconv.ParseUint(portHex, 16, 16)
+	if err != nil {
+		return "", 0, false
+	}
+	for i := 0; i+4 <= len(ip); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = ip[i+3], ip[i+2], ip[i+1], ip[i]
+	}
+	return net.IP(ip).String(), int(port), true
+}
+
+// resolveSocketOwners 扫描 /proc/<pid>/fd 查找监听套接字所属的进程
+// 结果按inode缓存，找不到的（无权限读取其他用户的进程）也记入缓存，避免每次重复扫描
+func resolveSocketOwners(inodes map[uint64]string) {
+	owners := make(map[uint64]socketOwner, len(inodes))
+	unknown := 0
+	for inode := range inodes {
+		if owner, ok := listenOwners[inode]; ok {
+			owners[inode] = owner
+		} else {
+			unknown++
+		}
+	}
+
+	if unknown > 0 {
+		entries, _ := os.ReadDir(hostProc())
+	scan:
+		for _, entry := range entries {
+			pid, err := strconv.ParseInt(entry.Name(), 10, 32)
+			if err != nil {
+				continue
+			}
+			fds, err := os.ReadDir(hostProc(entry.Name(), "fd"))
+			if err != nil {
+				continue
+			}
+			for _, fd := range fds {
+				link, err := os.Readlink(hostProc(entry.Name(), "fd", fd.Name()))
+				if err != nil || !strings.HasPrefix(link, "socket:[") {
+					continue
+				}
+				inode, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]"), 10, 64)
+				if err != nil {
+					continue
+				}
+				if _, wanted := inodes[inode]; !wanted {
+					continue
+				}
+				if _, found := owners[inode]; found {
+					continue
+				}
+				comm, _ := os.ReadFile(hostProc(entry.Name(), "comm"))
+				owners[inode] = socketOwner{pid: int32(pid), name: strings.TrimSpace(string(comm))}
+				if unknown--; unknown == 0 {
+					break scan
+				}
+			}
+		}
+		for inode := range inodes {
+			if _, found := owners[inode]; !found {
+				owners[inode] = socketOwner{}
+			}
+		}
+	}
+	listenOwners = owners
+}
+
+// collectConntrack 读取连接跟踪表的条目数和上限，未加载nf_conntrack时返回nil
+func collectConntrack() *ConntrackStats {
+	read := func(name string) (uint64, bool) {
+		data, err := os.ReadFile(hostProc("sys", "net", "netfilter", name))
+		if err != nil {
+			return 0, false
+		}
+		v, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		return v, err == nil
+	}
+	count, ok1 := read("nf_conntrack_count")
+	limit, ok2 := read("nf_conntrack_max")
+	if !ok1 || !ok2 {
+		return nil
+	}
+	stats := &ConntrackStats{Count: count, Max: limit}
+	if limit > 0 {
+		stats.Percent = float64(count) / float64(limit) * 100
+	}
+	return stats
+}
+
+// collectSocketsPortable 非Linux系统通过gopsutil获取连接列表
+func collectSocketsPortable(info *NetInfo) {
+	conns, err := psnet.Connections("inet")
+	if err != nil {
+		return
+	}
+
+	stats := &SocketStats{}
+	listening := make(map[string]ListenPort)
+	names := make(map[int32]string)
+	for _, c := range conns {
+		if c.Type != syscall.SOCK_STREAM {
+			stats.UDP++
+			continue
+		}
+		stats.TCP++
+		switch strings.ReplaceAll(c.Status, "_", "") {
+		case "ESTABLISHED":
+			stats.Established++
+		case "SYNSENT":
+			stats.SynSent++
+		case "SYNRECV", "SYNRECEIVED":
+			stats.SynRecv++
+		case "FINWAIT1", "FINWAIT2":
+			stats.FinWait++
+		case "TIMEWAIT":
+			stats.TimeWait++
+		case "CLOSEWAIT":
+			stats.CloseWait++
+		case "LASTACK":
+			stats.LastAck++
+		case "LISTEN":
+			stats.Listen++
+			proto := "tcp"
+			if c.Family == syscall.AF_INET6 {
+				proto = "tcp6"
+			}
+			key := proto + " " + net.JoinHostPort(c.Laddr.IP, strconv.Itoa(int(c.Laddr.Port)))
+			if _, exists := listening[key]; exists {
+				continue
+			}
+			port := ListenPort{Protocol: proto, Address: c.Laddr.IP, Port: int(c.Laddr.Port), PID: c.Pid}
+			if c.Pid > 0 {
+				name, cached := names[c.Pid]
+				if !cached {
+					if proc, err := process.NewProcess(c.Pid); err == nil {
+						name, _ = proc.Name()
+					}
+					names[c.Pid] = name
+				}
+				port.Process = name
+			}
+			listening[key] = port
+		}
+	}
+	info.Sockets = stats
+	info.Listening = sortListenPorts(listening)
+}
+
+func sortListenPorts(ports map[string]ListenPort) []ListenPort {
+	list := make([]ListenPort, 0, len(ports))
+	for _, p := range ports {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Port != list[j].Port {
+			return list[i].Port < list[j].Port
+		}
+		if list[i].Protocol != list[j].Protocol {
+			return list[i].Protocol < list[j].Protocol
+		}
+		return list[i].Address < list[j].Address
+	})
+	return list
+}
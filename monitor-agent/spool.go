@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	spoolSegmentSize   = 100 // 每个缓存文件的样本数，也是补传时单次批量上报的条数
+	spoolFileExt       = ".jsonl"
+	spoolBadExt        = ".bad" // 无法读取的缓存文件改名后保留
+	defaultSpoolMax    = 17280  // 默认最多缓存条数（5秒间隔约24小时）
+	defaultSpoolSubdir = "spool"
+)
+
+// spoolSegment 一个缓存文件
+type spoolSegment struct {
+	path  string
+	count int
+}
+
+// diskSpool 上报失败时将样本按顺序写入磁盘，服务器恢复后批量补传
+// 数据分段存储，超过上限时整段丢弃最旧的数据
+type diskSpool struct {
+	mu         sync.Mutex
+	dir        string
+	maxSamples int
+	segments   []*spoolSegment // 按时间升序，最后一个为当前写入段
+	current    *os.File
+	total      int
+	replaying  bool
+}
+
+var spool *diskSpool
+
+// spoolPath 相对路径按配置文件所在目录解析，不随启动时的工作目录变化
+func spoolPath(dir string) string {
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(filepath.Dir(*configFile), dir)
+}
+
+// openSpool 打开缓存目录并统计遗留的未发送数据
+func openSpool(dir string, maxSamples int) (*diskSpool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &diskSpool{dir: dir, maxSamples: maxSamples}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+spoolFileExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		count, err := countLines(path)
+		if err != nil {
+			log.Printf("读取缓存文件失败 | Failed to read spool file %s: %v", path, err)
+			continue
+		}
+		if count == 0 {
+			os.Remove(path)
+			continue
+		}
+		s.segments = append(s.segments, &spoolSegment{path: path, count: count})
+		s.total += count
+	}
+	return s, nil
+}
+
+func countLines(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) > 0 {
+			count++
+		}
+	}
+	return count, scanner.Err()
+}
+
+// pending 返回待补传的样本数
+func (s *diskSpool) pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.total
+}
+
+// add 追加一条未发送的样本
+func (s *diskSpool) add(info *SystemInfo) error {
+	line, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last := s.lastSegment()
+	if s.current == nil || last == nil || last.count >= spoolSegmentSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+		last = s.lastSegment()
+	}
+	if _, err := s.current.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	last.count++
+	s.total++
+
+	// 超过上限时丢弃最旧的整段（保留当前写入段）
+	for s.total > s.maxSamples && len(s.segments) > 1 {
+		oldest := s.segments[0]
+		os.Remove(oldest.path)
+		s.segments = s.segments[1:]
+		s.total -= oldest.count
+		log.Printf("缓存已满，丢弃最旧的 %d 条数据 | Spool full, dropped %d oldest samples", oldest.count, oldest.count)
+	}
+	return nil
+}
+
+func (s *diskSpool) lastSegment() *spoolSegment {
+	if len(s.segments) == 0 {
+		return nil
+	}
+	return s.segments[len(s.segments)-1]
+}
+
+// rotate 关闭当前写入段并新建一段，文件名按时间排序
+func (s *diskSpool) rotate() error {
+	s.closeCurrent()
+	path := filepath.Join(s.dir, fmt.Sprintf("%020d%s", time.Now().UnixNano(), spoolFileExt))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	s.current = f
+	s.segments = append(s.segments, &spoolSegment{path: path})
+	return nil
+}
+
+func (s *diskSpool) closeCurrent() {
+	if s.current != nil {
+		s.current.Close()
+		s.current = nil
+	}
+}
+
+// replay 按顺序补传缓存数据，遇到发送失败时停止，等待下次上报成功后继续
+func (s *diskSpool) replay() {
+	s.mu.Lock()
+	if s.replaying || s.total == 0 {
+		s.mu.Unlock()
+		return
+	}
+	s.replaying = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.replaying = false
+		s.mu.Unlock()
+	}()
+
+	sent := 0
+	batch := true // 服务器不支持批量接口时改为逐条补传
+	for {
+		s.mu.Lock()
+		if len(s.segments) == 0 {
+			s.mu.Unlock()
+			break
+		}
+		segment := s.segments[0]
+		// 正在写入的段先关闭，之后的样本写入新段
+		if len(s.segments) == 1 {
+			s.closeCurrent()
+		}
+		s.mu.Unlock()
+
+		samples, err := readSpoolSegment(segment.path)
+		if err != nil {
+			// 读取失败的文件改名隔离，不再补传也不删除，便于人工检查
+			log.Printf("读取缓存文件失败，已隔离 | Failed to read spool file %s, quarantined: %v", segment.path, err)
+			if err := os.Rename(segment.path, segment.path+spoolBadExt); err != nil {
+				log.Printf("隔离缓存文件失败 | Failed to quarantine spool file %s: %v", segment.path, err)
+				return
+			}
+		} else if len(samples) > 0 {
+			done, err := sendSpooled(samples, &batch)
+			sent += done
+			if err != nil && !isRejected(err) {
+				log.Printf("补传缓存数据失败，稍后重试 | Failed to replay spooled samples, will retry: %v", err)
+				if done > 0 {
+					s.keepUnsent(segment, samples[done:])
+				}
+				return
+			}
+			if err != nil {
+				log.Printf("服务器拒绝缓存数据，已丢弃 | Server rejected spooled samples, dropped: %v", err)
+			}
+			os.Remove(segment.path)
+		} else {
+			os.Remove(segment.path)
+		}
+
+		s.mu.Lock()
+		if len(s.segments) > 0 && s.segments[0] == segment {
+			s.segments = s.segments[1:]
+			s.total -= segment.count
+		}
+		s.mu.Unlock()
+	}
+
+	if sent > 0 {
+		log.Printf("已补传 %d 条离线数据 | Replayed %d spooled samples", sent, sent)
+	}
+}
+
+// sendSpooled 补传一段样本，返回已处理（发送成功或被拒绝丢弃）的条数
+// 批量接口返回404/405（旧版服务器）时改为逐条调用 /api/data，逐条发送时被拒绝的样本直接丢弃
+func sendSpooled(samples []*SystemInfo, batch *bool) (int, error) {
+	if *batch {
+		err := reportBatch(samples)
+		if err == nil {
+			return len(samples), nil
+		}
+		if !isBatchUnsupported(err) {
+			return 0, err
+		}
+		*batch = false
+		log.Printf("服务器不支持批量接口，改为逐条补传 | Server does not support batch ingest, replaying samples one by one")
+	}
+
+	for i, info := range samples {
+		if err := reportToServer(info); err != nil {
+			if !isRejected(err) {
+				return i, err
+			}
+			log.Printf("服务器拒绝缓存数据，已丢弃 | Server rejected spooled sample, dropped: %v", err)
+		}
+	}
+	return len(samples), nil
+}
+
+// keepUnsent 逐条补传中途失败时只保留未发送的样本，避免下次重复补传
+func (s *diskSpool) keepUnsent(segment *spoolSegment, samples []*SystemInfo) {
+	var buf bytes.Buffer
+	for _, info := range samples {
+		line, err := json.Marshal(info)
+		if err != nil {
+			continue
+		}
+		buf.Write(append(line, '\n'))
+	}
+	tmp := segment.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		log.Printf("写入缓存文件失败 | Failed to write spool file %s: %v", tmp, err)
+		return
+	}
+	if err := os.Rename(tmp, segment.path); err != nil {
+		os.Remove(tmp)
+		log.Printf("写入缓存文件失败 | Failed to write spool file %s: %v", segment.path, err)
+		return
+	}
+
+	s.mu.Lock()
+	s.total -= segment.count - len(samples)
+	segment.count = len(samples)
+	s.mu.Unlock()
+}
+
+func readSpoolSegment(path string) ([]*SystemInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var samples []*SystemInfo
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var info SystemInfo
+		if err := json.Unmarshal(line, &info); err != nil {
+			// 进程中断时可能留下不完整的最后一行
+			continue
+		}
+		samples = append(samples, &info)
+	}
+	return samples, scanner.Err()
+}
+
+// statusError 上报返回的非200状态
+type statusError struct {
+	status int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("服务器返回错误状态: %d", e.status)
+}
+
+// isRejected 判断服务器是否明确拒绝了数据（重试也不会成功）
+func isRejected(err error) bool {
+	statusErr, ok := err.(*statusError)
+	if !ok {
+		return false
+	}
+	return statusErr.status == http.StatusBadRequest || statusErr.status == http.StatusRequestEntityTooLarge
+}
+
+// isBatchUnsupported 判断服务器是否没有批量接口（旧版data-server）
+func isBatchUnsupported(err error) bool {
+	statusErr, ok := err.(*statusError)
+	if !ok {
+		return false
+	}
+	return statusErr.status == http.StatusNotFound || statusErr.status == http.StatusMethodNotAllowed
+}
+
+// reportBatch 通过批量接口上报多条样本
+func reportBatch(samples []*SystemInfo) error {
+	data, err := json.Marshal(samples)
+	if err != nil {
+		return fmt.Errorf("序列化数据失败: %v", err)
+	}
+
+	client := &http.Client{
+		Timeout: config.Timeout,
+	}
+
+	baseURL := strings.Replace(config.ServerURL, "/api/data", "", 1)
+	req, err := http.NewRequest("POST", baseURL+"/api/data/batch", bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if config.ProjectKey != "" {
+		req.Header.Set("X-Project-Key", config.ProjectKey)
+	}
+	if config.ServerKey != "" {
+		req.Header.Set("X-Server-Key", config.ServerKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &statusError{status: resp.StatusCode}
+	}
+	return nil
+}
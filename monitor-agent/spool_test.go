@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeIngest 模拟不带批量接口的旧版服务器，failAfter 条之后 /api/data 返回503
+type fakeIngest struct {
+	mu        sync.Mutex
+	hosts     []string
+	batches   int
+	failAfter int
+}
+
+func (f *fakeIngest) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.URL.Path {
+	case "/api/data/batch":
+		f.batches++
+		http.NotFound(w, r)
+	case "/api/data":
+		if f.failAfter >= 0 && len(f.hosts) >= f.failAfter {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var info SystemInfo
+		json.NewDecoder(r.Body).Decode(&info)
+		f.hosts = append(f.hosts, info.Hostname)
+		w.Write([]byte("{}"))
+	}
+}
+
+func useTestServer(t *testing.T, handler http.Handler) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	saved := config
+	config.ServerURL = srv.URL + "/api/data"
+	config.Timeout = 2 * time.Second
+	t.Cleanup(func() { config = saved })
+}
+
+func spoolSamples(t *testing.T, s *diskSpool, hosts ...string) {
+	t.Helper()
+	for _, host := range hosts {
+		if err := s.add(&SystemInfo{Hostname: host, Timestamp: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSpoolReplayFallsBackToSingleSamples(t *testing.T) {
+	ingest := &fakeIngest{failAfter: 2}
+	useTestServer(t, ingest)
+
+	s, err := openSpool(t.TempDir(), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spoolSamples(t, s, "a", "b", "c", "d")
+
+	// 发送两条后服务器不可用，剩余的样本保留在缓存中
+	s.replay()
+	if ingest.batches != 1 {
+		t.Errorf("batch requests = %d, want 1", ingest.batches)
+	}
+	if len(ingest.hosts) != 2 || s.pending() != 2 {
+		t.Fatalf("sent %v, pending %d; want 2 sent and 2 pending", ingest.hosts, s.pending())
+	}
+
+	// 恢复后只补传未发送的样本，不重复发送
+	ingest.mu.Lock()
+	ingest.failAfter = -1
+	ingest.mu.Unlock()
+	s.replay()
+	want := []string{"a", "b", "c", "d"}
+	if len(ingest.hosts) != len(want) {
+		t.Fatalf("sent %v, want %v", ingest.hosts, want)
+	}
+	for i := range want {
+		if ingest.hosts[i] != want[i] {
+			t.Fatalf("sent %v, want %v", ingest.hosts, want)
+		}
+	}
+	if s.pending() != 0 {
+		t.Errorf("pending = %d after replay", s.pending())
+	}
+}
+
+func TestSpoolQuarantinesUnreadableSegment(t *testing.T) {
+	ingest := &fakeIngest{failAfter: -1}
+	useTestServer(t, ingest)
+
+	dir := t.TempDir()
+	// 目录无法按文件读取，模拟读取失败的缓存段
+	bad := filepath.Join(dir, "00000000000000000001"+spoolFileExt)
+	if err := os.Mkdir(bad, 0755); err != nil {
+		t.Fatal(err)
+	}
+	s := &diskSpool{dir: dir, maxSamples: 100, segments: []*spoolSegment{{path: bad, count: 1}}, total: 1}
+	spoolSamples(t, s, "a")
+
+	s.replay()
+	if _, err := os.Stat(bad + spoolBadExt); err != nil {
+		t.Errorf("unreadable segment not quarantined: %v", err)
+	}
+	if len(ingest.hosts) != 1 || s.pending() != 0 {
+		t.Errorf("sent %v, pending %d; want the readable segment replayed", ingest.hosts, s.pending())
+	}
+}
+
+func TestSpoolPathRelativeToConfig(t *testing.T) {
+	saved := *configFile
+	t.Cleanup(func() { *configFile = saved })
+	*configFile = filepath.Join("etc", "agent", "config.json")
+
+	if got, want := spoolPath("spool"), filepath.Join("etc", "agent", "spool"); got != want {
+		t.Errorf("spoolPath(spool) = %q, want %q", got, want)
+	}
+	abs := filepath.Join(t.TempDir(), "spool")
+	if got := spoolPath(abs); got != abs {
+		t.Errorf("spoolPath(%q) = %q", abs, got)
+	}
+}
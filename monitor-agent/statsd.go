@@ -0,0 +1,386 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// statsdPluginName StatsD指标在custom中的名称，插件不能使用
+const statsdPluginName = "statsd"
+
+const (
+	defaultStatsDMaxMetrics = 1000
+	maxTimerSamples         = 10000           // 每个计时器每个周期最多保留的样本，超出后按蓄水池抽样保留，百分位为近似值
+	statsdIdleExpiry        = 5 * time.Minute // 计数器和gauge超过该时长没有更新后不再上报
+)
+
+var defaultStatsDPercentiles = []float64{50, 90, 99}
+
+// StatsDConfig 本地StatsD监听，按上报间隔聚合应用发送的计数器、gauge、计时器和集合
+type StatsDConfig struct {
+	Listen      string    `json:"listen,omitempty"`      // UDP监听地址，如 127.0.0.1:8125，空为不启用
+	Percentiles []float64 `json:"percentiles,omitempty"` // 计时器的百分位，默认 [50, 90, 99]
+	MaxMetrics  int       `json:"max_metrics,omitempty"` // 最多保留的指标数（按名称和标签区分），默认1000
+}
+
+// statsdKey 指标名和排序后的标签
+type statsdKey struct {
+	name string
+	tags string
+}
+
+type statsdCounter struct {
+	value   float64
+	updated time.Time
+}
+
+type statsdGauge struct {
+	value   float64
+	updated time.Time
+}
+
+type statsdTimer struct {
+	count         float64 // 按采样率换算后的次数
+	received      int     // 实际收到的样本数
+	sum, min, max float64
+	samples       []float64
+}
+
+// statsdAggregator 一个上报周期内的聚合状态
+type statsdAggregator struct {
+	mu        sync.Mutex
+	counters  map[statsdKey]*statsdCounter
+	gauges    map[statsdKey]*statsdGauge
+	timers    map[statsdKey]*statsdTimer
+	sets      map[statsdKey]map[string]bool
+	labels    map[statsdKey]map[string]string
+	invalid   int // 本周期无法解析的行数
+	dropped   int // 本周期因超过指标数上限丢弃的行数
+	lastFlush time.Time
+}
+
+var statsd *statsdAggregator
+
+// startStatsD 启动UDP监听，未配置listen时不启用
+func startStatsD() {
+	if config.StatsD.Listen == "" {
+		return
+	}
+	if config.StatsD.MaxMetrics <= 0 {
+		config.StatsD.MaxMetrics = defaultStatsDMaxMetrics
+	}
+	var percentiles []float64
+	for _, p := range config.StatsD.Percentiles {
+		if p <= 0 || p > 100 {
+			log.Printf("StatsD百分位 %v 无效，已忽略 | Invalid StatsD percentile %v ignored", p, p)
+			continue
+		}
+		percentiles = append(percentiles, p)
+	}
+	if len(percentiles) == 0 {
+		percentiles = defaultStatsDPercentiles
+	}
+	config.StatsD.Percentiles = percentiles
+
+	conn, err := net.ListenPacket("udp", config.StatsD.Listen)
+	if err != nil {
+		log.Printf("启动StatsD监听失败 | Failed to start StatsD listener: %v", err)
+		return
+	}
+	statsd = &statsdAggregator{
+		counters:  make(map[statsdKey]*statsdCounter),
+		gauges:    make(map[statsdKey]*statsdGauge),
+		timers:    make(map[statsdKey]*statsdTimer),
+		sets:      make(map[statsdKey]map[string]bool),
+		labels:    make(map[statsdKey]map[string]string),
+		lastFlush: time.Now(),
+	}
+	log.Printf("StatsD监听 | StatsD listening on udp://%s", conn.LocalAddr())
+
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				log.Printf("读取StatsD数据失败 | Failed to read StatsD packet: %v", err)
+				continue
+			}
+			statsd.handlePacket(string(buf[:n]))
+		}
+	}()
+}
+
+func (a *statsdAggregator) handlePacket(packet string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, line := range strings.Split(packet, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if err := a.handleLine(line); err != nil {
+			a.invalid++
+		}
+	}
+}
+
+// handleLine 解析并聚合一行，格式为 name:value|type[|@sample_rate][|#tag:value,...]
+// type为 c（计数器）、g（gauge，值带 +/- 时为增减）、ms / h / d（计时器）、s（集合）
+func (a *statsdAggregator) handleLine(line string) error {
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || name == "" {
+		return fmt.Errorf("缺少指标名")
+	}
+	parts := strings.Split(rest, "|")
+	if len(parts) < 2 {
+		return fmt.Errorf("缺少类型")
+	}
+	rawValue, typ := parts[0], parts[1]
+
+	rate := 1.0
+	var tags map[string]string
+	for _, field := range parts[2:] {
+		switch {
+		case strings.HasPrefix(field, "@"):
+			r, err := strconv.ParseFloat(field[1:], 64)
+			if err != nil || r <= 0 || r > 1 {
+				return fmt.Errorf("无效的采样率")
+			}
+			rate = r
+		case strings.HasPrefix(field, "#"):
+			tags = parseStatsDTags(field[1:])
+		}
+	}
+
+	key := statsdKey{name: name, tags: joinStatsDTags(tags)}
+	if !a.known(key, typ) && a.size() >= config.StatsD.MaxMetrics {
+		a.dropped++
+		return nil
+	}
+
+	if typ == "s" {
+		if a.sets[key] == nil {
+			a.sets[key] = make(map[string]bool)
+		}
+		a.sets[key][rawValue] = true
+		a.labels[key] = tags
+		return nil
+	}
+
+	value, err := strconv.ParseFloat(rawValue, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("无效的数值")
+	}
+	now := time.Now()
+	switch typ {
+	case "c":
+		c := a.counters[key]
+		if c == nil {
+			c = &statsdCounter{}
+			a.counters[key] = c
+		}
+		c.value += value / rate
+		c.updated = now
+	case "g":
+		g := a.gauges[key]
+		if g == nil {
+			g = &statsdGauge{}
+			a.gauges[key] = g
+		}
+		if rawValue[0] == '+' || rawValue[0] == '-' {
+			g.value += value
+		} else {
+			g.value = value
+		}
+		g.updated = now
+	case "ms", "h", "d":
+		t := a.timers[key]
+		if t == nil {
+			t = &statsdTimer{min: value, max: value}
+			a.timers[key] = t
+		}
+		t.count += 1 / rate
+		t.received++
+		t.sum += value
+		t.min = math.Min(t.min, value)
+		t.max = math.Max(t.max, value)
+		// 蓄水池抽样：超出上限后每个样本以相同概率留在样本集中，百分位不偏向周期开始的样本
+		if len(t.samples) < maxTimerSamples {
+			t.samples = append(t.samples, value)
+		} else if i := rand.IntN(t.received); i < maxTimerSamples {
+			t.samples[i] = value
+		}
+	default:
+		return fmt.Errorf("未知的类型 %q", typ)
+	}
+	a.labels[key] = tags
+	return nil
+}
+
+func (a *statsdAggregator) known(key statsdKey, typ string) bool {
+	switch typ {
+	case "c":
+		return a.counters[key] != nil
+	case "g":
+		return a.gauges[key] != nil
+	case "s":
+		return a.sets[key] != nil
+	default:
+		return a.timers[key] != nil
+	}
+}
+
+func (a *statsdAggregator) size() int {
+	return len(a.counters) + len(a.gauges) + len(a.timers) + len(a.sets)
+}
+
+// parseStatsDTags 解析DogStatsD格式的标签 env:prod,region，没有值的标签值为空
+func parseStatsDTags(s string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(tag, ":")
+		tags[name] = value
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
+
+func joinStatsDTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for name, value := range tags {
+		pairs = append(pairs, name+":"+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// collectStatsD 输出本周期的聚合结果并开始新的周期，未启用时返回nil
+// 计数器输出 .count 和 .rate（每秒）；计时器输出 .count .rate .sum .mean .min .max 和各百分位（如 .p99）；
+// 集合输出 .unique；gauge保持最近一次的值。计数器在空闲期间上报0，超过5分钟未更新后不再上报
+func collectStatsD() *PluginResult {
+	if statsd == nil {
+		return nil
+	}
+	a := statsd
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(a.lastFlush).Seconds()
+	a.lastFlush = now
+	result := &PluginResult{Name: statsdPluginName, CollectedAt: now}
+	add := func(key statsdKey, suffix string, value float64) {
+		name := key.name
+		if suffix != "" {
+			name += "." + suffix
+		}
+		result.Metrics = append(result.Metrics, CustomMetric{Name: name, Labels: a.labels[key], Value: value, Type: "gauge"})
+	}
+	perSecond := func(v float64) float64 {
+		if elapsed <= 0 {
+			return 0
+		}
+		return v / elapsed
+	}
+
+	for key, c := range a.counters {
+		if now.Sub(c.updated) > statsdIdleExpiry {
+			delete(a.counters, key)
+			continue
+		}
+		add(key, "count", c.value)
+		add(key, "rate", perSecond(c.value))
+		c.value = 0
+	}
+	for key, g := range a.gauges {
+		if now.Sub(g.updated) > statsdIdleExpiry {
+			delete(a.gauges, key)
+			continue
+		}
+		add(key, "", g.value)
+	}
+	sampled := 0
+	for key, t := range a.timers {
+		if t.received > len(t.samples) {
+			sampled++
+		}
+		add(key, "count", t.count)
+		add(key, "rate", perSecond(t.count))
+		add(key, "sum", t.sum)
+		add(key, "mean", t.sum/float64(t.received))
+		add(key, "min", t.min)
+		add(key, "max", t.max)
+		sort.Float64s(t.samples)
+		for _, p := range config.StatsD.Percentiles {
+			add(key, percentileSuffix(p), percentile(t.samples, p))
+		}
+		delete(a.timers, key)
+	}
+	for key, set := range a.sets {
+		add(key, "unique", float64(len(set)))
+		delete(a.sets, key)
+	}
+	for key := range a.labels {
+		if a.counters[key] == nil && a.gauges[key] == nil {
+			delete(a.labels, key)
+		}
+	}
+
+	var problems []string
+	if a.invalid > 0 {
+		problems = append(problems, fmt.Sprintf("%d 行格式错误", a.invalid))
+	}
+	if a.dropped > 0 {
+		problems = append(problems, fmt.Sprintf("超过 %d 个指标，丢弃 %d 行", config.StatsD.MaxMetrics, a.dropped))
+	}
+	if sampled > 0 {
+		problems = append(problems, fmt.Sprintf("%d 个计时器样本超过 %d 个，百分位按抽样计算", sampled, maxTimerSamples))
+	}
+	result.Error = strings.Join(problems, "；")
+	a.invalid, a.dropped = 0, 0
+
+	sort.Slice(result.Metrics, func(i, j int) bool {
+		return customMetricLess(result.Metrics[i], result.Metrics[j])
+	})
+	return result
+}
+
+// percentile 按最近秩法计算已排序样本的百分位
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// percentileSuffix 百分位的指标后缀，如 99 为 p99，99.9 为 p99_9
+func percentileSuffix(p float64) string {
+	return "p" + strings.ReplaceAll(strconv.FormatFloat(p, 'f', -1, 64), ".", "_")
+}
+
+func customMetricLess(a, b CustomMetric) bool {
+	if a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	return joinStatsDTags(a.Labels) < joinStatsDTags(b.Labels)
+}
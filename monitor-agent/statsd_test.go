@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStatsD(t *testing.T) *statsdAggregator {
+	t.Helper()
+	saved, savedCfg := statsd, config.StatsD
+	t.Cleanup(func() { statsd, config.StatsD = saved, savedCfg })
+	config.StatsD = StatsDConfig{Percentiles: []float64{50, 99}, MaxMetrics: defaultStatsDMaxMetrics}
+	statsd = &statsdAggregator{
+		counters:  make(map[statsdKey]*statsdCounter),
+		gauges:    make(map[statsdKey]*statsdGauge),
+		timers:    make(map[statsdKey]*statsdTimer),
+		sets:      make(map[statsdKey]map[string]bool),
+		labels:    make(map[statsdKey]map[string]string),
+		lastFlush: time.Now(),
+	}
+	return statsd
+}
+
+func statsdValues(result *PluginResult) map[string]float64 {
+	values := make(map[string]float64)
+	for _, m := range result.Metrics {
+		values[m.Name] = m.Value
+	}
+	return values
+}
+
+func TestStatsDTimerPercentiles(t *testing.T) {
+	a := newTestStatsD(t)
+	for i := 1; i <= 100; i++ {
+		a.handlePacket(fmt.Sprintf("api.latency:%d|ms", i))
+	}
+	result := collectStatsD()
+	values := statsdValues(result)
+	if values["api.latency.p50"] != 50 || values["api.latency.p99"] != 99 || values["api.latency.count"] != 100 {
+		t.Errorf("values = %v", values)
+	}
+	if result.Error != "" {
+		t.Errorf("error = %q, want none", result.Error)
+	}
+}
+
+// 样本按时间递增，只保留前面的样本时p99会停在周期开始的位置
+func TestStatsDTimerReservoirSampling(t *testing.T) {
+	a := newTestStatsD(t)
+	total := maxTimerSamples * 10
+	for i := 1; i <= total; i++ {
+		a.handlePacket(fmt.Sprintf("api.latency:%d|ms", i))
+	}
+	if got := len(a.timers[statsdKey{name: "api.latency"}].samples); got != maxTimerSamples {
+		t.Fatalf("kept %d samples, want %d", got, maxTimerSamples)
+	}
+
+	result := collectStatsD()
+	values := statsdValues(result)
+	if values["api.latency.count"] != float64(total) || values["api.latency.max"] != float64(total) {
+		t.Errorf("count/max = %v/%v", values["api.latency.count"], values["api.latency.max"])
+	}
+	for name, want := range map[string]float64{"api.latency.p50": 0.5, "api.latency.p99": 0.99} {
+		if got := values[name] / float64(total); got < want-0.03 || got > want+0.03 {
+			t.Errorf("%s = %v, want about %v", name, values[name], want*float64(total))
+		}
+	}
+	if !strings.Contains(result.Error, "按抽样计算") {
+		t.Errorf("error = %q, want sampling note", result.Error)
+	}
+}
+
+func TestStatsDCountersAndGauges(t *testing.T) {
+	a := newTestStatsD(t)
+	a.handlePacket("jobs.done:1|c|@0.1\njobs.done:2|c\nqueue:10|g\nqueue:+5|g\nqueue:-3|g\nusers:alice|s\nusers:bob|s\nusers:alice|s")
+	values := statsdValues(collectStatsD())
+	want := map[string]float64{"jobs.done.count": 12, "queue": 12, "users.unique": 2}
+	for name, v := range want {
+		if values[name] != v {
+			t.Errorf("%s = %v, want %v", name, values[name], v)
+		}
+	}
+	if values["jobs.done.rate"] <= 0 {
+		t.Errorf("rate = %v", values["jobs.done.rate"])
+	}
+
+	// 下个周期计数器清零，gauge保持，集合不再上报
+	a.handlePacket("queue:-2|g")
+	values = statsdValues(collectStatsD())
+	if values["jobs.done.count"] != 0 || values["queue"] != 10 {
+		t.Errorf("values = %v", values)
+	}
+	if _, ok := values["users.unique"]; ok {
+		t.Error("set reported in an idle interval")
+	}
+}
+
+func TestStatsDTags(t *testing.T) {
+	a := newTestStatsD(t)
+	a.handlePacket("requests:1|c|#env:prod,region:eu\nrequests:2|c|#region:eu,env:prod\nrequests:5|c|#env:staging\nrequests:7|c\nrequests:1|c|#canary")
+	result := collectStatsD()
+
+	series := make(map[string]float64)
+	for _, m := range result.Metrics {
+		if m.Name == "requests.count" {
+			series[joinStatsDTags(m.Labels)] = m.Value
+		}
+	}
+	// 标签顺序不同的同一组标签合并为一个序列，没有值的标签值为空
+	want := map[string]float64{"env:prod,region:eu": 3, "env:staging": 5, "": 7, "canary:": 1}
+	if len(series) != len(want) {
+		t.Fatalf("series = %v, want %v", series, want)
+	}
+	for tags, v := range want {
+		if series[tags] != v {
+			t.Errorf("requests{%s} = %v, want %v", tags, series[tags], v)
+		}
+	}
+}
+
+func TestStatsDMaxMetricsAndInvalidLines(t *testing.T) {
+	a := newTestStatsD(t)
+	config.StatsD.MaxMetrics = 2
+	a.handlePacket("a:1|c\nb:1|g\nc:1|c\na:2|c\nb:3|g|#env:prod\nbroken\na:x|c\nb:+x|g\nf:1|c|@2")
+	result := collectStatsD()
+	values := statsdValues(result)
+
+	// 已有的指标继续更新，新指标（包括已有名称的新标签组合）被丢弃
+	if values["a.count"] != 3 || values["b"] != 1 || len(values) != 3 {
+		t.Errorf("values = %v", values)
+	}
+	for _, note := range []string{"4 行格式错误", "超过 2 个指标，丢弃 2 行"} {
+		if !strings.Contains(result.Error, note) {
+			t.Errorf("error = %q, want %q", result.Error, note)
+		}
+	}
+
+	// 问题计数每个周期重置
+	if result := collectStatsD(); result.Error != "" {
+		t.Errorf("error = %q after reset", result.Error)
+	}
+}
+
+func TestStatsDIdleExpiry(t *testing.T) {
+	a := newTestStatsD(t)
+	a.handlePacket("jobs:1|c\nqueue:4|g\nfresh:1|c")
+	collectStatsD()
+
+	// 超过5分钟没有更新的计数器和gauge不再上报
+	idle := time.Now().Add(-statsdIdleExpiry - time.Second)
+	a.counters[statsdKey{name: "jobs"}].updated = idle
+	a.gauges[statsdKey{name: "queue"}].updated = idle
+	values := statsdValues(collectStatsD())
+	if _, ok := values["jobs.count"]; ok {
+		t.Error("expired counter reported")
+	}
+	if _, ok := values["queue"]; ok {
+		t.Error("expired gauge reported")
+	}
+	if v, ok := values["fresh.count"]; !ok || v != 0 {
+		t.Errorf("idle counter = %v, %v, want 0", v, ok)
+	}
+	if len(a.labels) != 1 || a.size() != 1 {
+		t.Errorf("expired metrics still tracked: labels %d size %d", len(a.labels), a.size())
+	}
+}
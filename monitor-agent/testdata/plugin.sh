@@ -0,0 +1,29 @@
+#!/bin/sh
+# 插件测试用的脚本，第一个参数选择行为
+case "$1" in
+json)
+	echo '{"queue": {"depth": 12, "workers": 4}, "healthy": true, "version": "1.2"}'
+	;;
+prometheus)
+	echo '# TYPE requests_total counter'
+	echo 'requests_total{code="200",path="/a\"b"} 1027 1700000000000'
+	echo 'temperature NaN'
+	;;
+large)
+	# 超过1MiB的输出
+	head -c 1100000 /dev/zero | tr '\0' 'x'
+	;;
+fail)
+	echo "connection refused" >&2
+	echo "details" >&2
+	exit 3
+	;;
+slow)
+	exec sleep 10
+	;;
+background)
+	# 后台子进程继续持有标准输出
+	sleep 10 &
+	echo '{"up": 1}'
+	;;
+esac
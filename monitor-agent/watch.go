@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+const systemctlTimeout = 5 * time.Second
+
+// WatchConfig 需要检查运行状态的进程和systemd服务
+type WatchConfig struct {
+	Processes    []ProcessWatch `json:"processes,omitempty"`
+	SystemdUnits []string       `json:"systemd_units,omitempty"`
+}
+
+// ProcessWatch 按进程名（或命令行）匹配的进程检查
+type ProcessWatch struct {
+	Name     string `json:"name"`                // 显示名称，默认为pattern
+	Pattern  string `json:"pattern"`             // 正则表达式，默认匹配进程名
+	Cmdline  bool   `json:"cmdline,omitempty"`   // 匹配完整命令行
+	MinCount int    `json:"min_count,omitempty"` // 匹配的进程数少于该值视为故障，默认1
+}
+
+// processWatcher 编译后的进程检查规则
+type processWatcher struct {
+	ProcessWatch
+	re *regexp.Regexp
+}
+
+var (
+	processWatchers     []processWatcher
+	processWatchersOnce sync.Once
+	systemctlWarned     bool // systemctl查询失败时只提示一次，恢复后重置
+)
+
+func compileProcessWatchers(watches []ProcessWatch) []processWatcher {
+	var watchers []processWatcher
+	for _, w := range watches {
+		re, err := regexp.Compile(w.Pattern)
+		if err != nil || w.Pattern == "" {
+			log.Printf("进程检查规则 %q 无效，已忽略 | Invalid process watch %q ignored: %v", w.Pattern, w.Pattern, err)
+			continue
+		}
+		if w.Name == "" {
+			w.Name = w.Pattern
+		}
+		if w.MinCount <= 0 {
+			w.MinCount = 1
+		}
+		watchers = append(watchers, processWatcher{ProcessWatch: w, re: re})
+	}
+	return watchers
+}
+
+// collectServices 检查配置中的进程和systemd服务
+func collectServices() []ServiceStatus {
+	processWatchersOnce.Do(func() {
+		processWatchers = compileProcessWatchers(config.Watch.Processes)
+	})
+
+	var services []ServiceStatus
+	if len(processWatchers) > 0 {
+		services = append(services, checkProcesses(processWatchers)...)
+	}
+	if len(config.Watch.SystemdUnits) > 0 {
+		services = append(services, checkSystemdUnits(config.Watch.SystemdUnits)...)
+	}
+	return services
+}
+
+// checkProcesses 统计匹配的进程数，匹配的PID全部更换时记为一次重启
+func checkProcesses(watchers []processWatcher) []ServiceStatus {
+	var procs []*process.Process
+	if lastProcesses != nil {
+		// 进程列表已在本轮采集中获取，复用其中缓存的进程名
+		for _, proc := range lastProcesses {
+			procs = append(procs, proc)
+		}
+	} else {
+		var err error
+		if procs, err = process.Processes(); err != nil {
+			log.Printf("获取进程列表失败 | Failed to list processes: %v", err)
+			return nil
+		}
+	}
+
+	if lastWatchPIDs == nil {
+		lastWatchPIDs = make(map[string]map[int32]bool)
+		watchRestarts = make(map[string]int)
+	}
+
+	services := make([]ServiceStatus, 0, len(watchers))
+	for _, w := range watchers {
+		pids := make(map[int32]bool)
+		for _, proc := range procs {
+			var target string
+			var err error
+			if w.Cmdline {
+				target, err = proc.Cmdline()
+			} else {
+				target, err = proc.Name()
+			}
+			if err == nil && w.re.MatchString(target) {
+				pids[proc.Pid] = true
+			}
+		}
+
+		if prev, known := lastWatchPIDs[w.Name]; known && len(pids) > 0 && !sharesPID(prev, pids) {
+			watchRestarts[w.Name]++
+		}
+		lastWatchPIDs[w.Name] = pids
+
+		services = append(services, ServiceStatus{
+			Name:     w.Name,
+			Type:     "process",
+			Running:  len(pids) > 0,
+			Count:    len(pids),
+			Restarts: watchRestarts[w.Name],
+			Failed:   len(pids) < w.MinCount,
+		})
+	}
+	return services
+}
+
+func sharesPID(a, b map[int32]bool) bool {
+	for pid := range a {
+		if b[pid] {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSystemdUnits 通过 systemctl show 一次查询所有服务的状态
+func checkSystemdUnits(units []string) []ServiceStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), systemctlTimeout)
+	defer cancel()
+	args := append([]string{"show", "--property=Id,LoadState,ActiveState,SubState,NRestarts,MainPID", "--"}, units...)
+	output, err := exec.CommandContext(ctx, "systemctl", args...).Output()
+	if err != nil {
+		if !systemctlWarned {
+			log.Printf("查询systemd服务失败，跳过检查 | Failed to query systemd units, skipping: %v", err)
+			systemctlWarned = true
+		}
+		return nil
+	}
+	systemctlWarned = false
+
+	// 每个服务的属性以空行分隔，顺序与参数一致
+	var services []ServiceStatus
+	props := make(map[string]string)
+	flush := func() {
+		if len(props) == 0 || len(services) >= len(units) {
+			return
+		}
+		services = append(services, systemdStatus(units[len(services)], props))
+		props = make(map[string]string)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			flush()
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			props[key] = value
+		}
+	}
+	flush()
+	return services
+}
+
+func systemdStatus(unit string, props map[string]string) ServiceStatus {
+	status := ServiceStatus{
+		Name:    unit,
+		Type:    "systemd",
+		Running: props["ActiveState"] == "active",
+		State:   props["ActiveState"] + "/" + props["SubState"],
+	}
+	if props["LoadState"] == "not-found" {
+		status.State = "not-found"
+	}
+	status.Failed = props["ActiveState"] == "failed" || props["LoadState"] == "not-found"
+	if pid, err := strconv.Atoi(props["MainPID"]); err == nil && pid > 0 {
+		status.Count = 1
+	}
+	status.Restarts, _ = strconv.Atoi(props["NRestarts"])
+	return status
+}